@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	thrift_dyn "github.com/ii64/go-thrift-dyn"
+	"os"
+)
+
+func runCompat(e *env, args []string) (err error) {
+	fs := newFlagSet(e, "compat", "old.thrift new.thrift")
+	strict := fs.Bool("source", false, "fail on source-breaking changes too")
+	if err = fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	var schemas [2]*thrift_dyn.Schema
+	for i, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if schemas[i], err = thrift_dyn.ParseIDL(string(src)); err != nil {
+			return fmt.Errorf("%s:%w", path, err)
+		}
+	}
+	report := thrift_dyn.CheckCompatibility(schemas[0], schemas[1])
+	for _, issue := range report {
+		fmt.Fprintln(e.stdout, issue)
+	}
+	wire, source := report.Count(thrift_dyn.CompatWire), report.Count(thrift_dyn.CompatSource)
+	if wire > 0 || *strict && source > 0 {
+		return fmt.Errorf("%d wire-breaking, %d source-breaking changes", wire, source)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	thrift_dyn "github.com/ii64/go-thrift-dyn"
+	"os"
+	"path/filepath"
+)
+
+func runConvert(e *env, args []string) (err error) {
+	fs := newFlagSet(e, "convert", "[file...]")
+	from := fs.String("from", "binary", "protocol of input")
+	to := fs.String("to", "compact", "protocol of output")
+	message := fs.Bool("message", false, "payload is a message with header")
+	isHex := fs.Bool("hex", false, "input is hex encoded, output is hex encoded too")
+	out := fs.String("o", "", "output file, or output directory when converting many files")
+	if err = fs.Parse(args); err != nil {
+		return errUsage
+	}
+	fromType, err := protocolType(*from)
+	if err != nil {
+		return
+	}
+	toType, err := protocolType(*to)
+	if err != nil {
+		return
+	}
+	convert := func(src []byte) (bb []byte, err error) {
+		if *message {
+			bb, err = thrift_dyn.TranscodeMessage(src, fromType, toType)
+		} else {
+			bb, err = thrift_dyn.Transcode(src, fromType, toType)
+		}
+		if err == nil && *isHex {
+			bb = []byte(hex.EncodeToString(bb) + "\n")
+		}
+		return
+	}
+
+	files := fs.Args()
+	if len(files) <= 1 {
+		src, err := readInput(e, files, *isHex)
+		if err != nil {
+			return err
+		}
+		bb, err := convert(src)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			_, err = e.stdout.Write(bb)
+			return err
+		}
+		return os.WriteFile(*out, bb, 0o644)
+	}
+
+	// bulk conversion, each file is written to the output directory under its own name.
+	if *out == "" {
+		return fmt.Errorf("-o directory is required to convert %d files", len(files))
+	}
+	if err = os.MkdirAll(*out, 0o755); err != nil {
+		return
+	}
+	for _, file := range files {
+		src, err := readInput(e, []string{file}, *isHex)
+		if err != nil {
+			return err
+		}
+		bb, err := convert(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err = os.WriteFile(filepath.Join(*out, filepath.Base(file)), bb, 0o644); err != nil {
+			return err
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	thrift_dyn "github.com/ii64/go-thrift-dyn"
+	"io"
+	"strings"
+)
+
+const hexdumpWidth = 16 // bytes per line
+
+func runHexdump(e *env, args []string) (err error) {
+	fs := newFlagSet(e, "hexdump", "[file]")
+	proto := fs.String("protocol", "binary", "protocol of payload, binary or compact")
+	message := fs.Bool("message", false, "payload is a message with header")
+	isHex := fs.Bool("hex", false, "payload is hex encoded")
+	if err = fs.Parse(args); err != nil {
+		return errUsage
+	}
+	ptype, err := protocolType(*proto)
+	if err != nil {
+		return
+	}
+	if ptype != thrift_dyn.ProtocolType_Binary && ptype != thrift_dyn.ProtocolType_Compact {
+		// byte offsets of other protocols are not exact, see Decoder.SetWireMap.
+		return fmt.Errorf("%w %q, hexdump reads binary or compact", thrift_dyn.ErrUnsupportedProtocol, *proto)
+	}
+	pf, err := thrift_dyn.NewProtocolFactory(ptype, &thrift.TConfiguration{})
+	if err != nil {
+		return
+	}
+	src, err := readInput(e, fs.Args(), *isHex)
+	if err != nil {
+		return
+	}
+
+	dec := thrift_dyn.NewDecoder(pf).SetWireMap(true).SetBestEffort(true)
+	var st thrift_dyn.RPCStruct
+	if *message {
+		_, err = dec.DecodeMessage(src, &st)
+	} else {
+		err = dec.Decode(src, &st)
+	}
+	writeHexdump(e.stdout, src, dec.WireMap())
+
+	var diags thrift_dyn.Diagnostics
+	if errors.As(err, &diags) {
+		for _, d := range diags {
+			fmt.Fprintf(e.stdout, "error: %s\n", d)
+		}
+		return fmt.Errorf("malformed payload")
+	}
+	return
+}
+
+// writeHexdump write src side by side with entries of wm, bytes not covered
+// by wm are listed as undecoded.
+func writeHexdump(w io.Writer, src []byte, wm thrift_dyn.WireMap) {
+	var off int64
+	for _, entry := range wm {
+		switch {
+		case entry.Leaf() && entry.End >= off:
+			if entry.Start > off {
+				writeHexdumpLines(w, src, off, entry.Start, 0, "undecoded")
+			}
+			writeHexdumpLines(w, src, entry.Start, entry.End, entry.Depth, entry.String())
+			off = entry.End
+		case entry.Kind != thrift_dyn.WireField && entry.Type == thrift.STRUCT:
+			// heading of struct element, its fields follow.
+			writeHexdumpLines(w, src, entry.Start, entry.Start, entry.Depth, entry.String())
+		}
+	}
+	if n := int64(len(src)); off < n {
+		writeHexdumpLines(w, src, off, n, 0, "undecoded")
+	}
+}
+
+// writeHexdumpLines write bytes [start, end) of src, desc is written on the first line.
+func writeHexdumpLines(w io.Writer, src []byte, start, end int64, depth int, desc string) {
+	indent := strings.Repeat("  ", depth)
+	for {
+		n := end - start
+		if n > hexdumpWidth {
+			n = hexdumpWidth
+		}
+		line := fmt.Sprintf("%08x  %-*s  %s%s", start, hexdumpWidth*3-1, hexBytes(src[start:start+n]), indent, desc)
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+		start += n
+		if start >= end {
+			return
+		}
+		desc = ""
+	}
+}
+
+func hexBytes(bb []byte) string {
+	var sb strings.Builder
+	for i, b := range bb {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%02x", b)
+	}
+	return sb.String()
+}
@@ -0,0 +1,120 @@
+// Command thrift-dyn inspects thrift payloads without generated code.
+//
+//	thrift-dyn <command> [flags] [file]
+//
+// Payload is read from file, or from stdin when file is omitted or `-`.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	thrift_dyn "github.com/ii64/go-thrift-dyn"
+	"io"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+var commands = []command{
+	{"hexdump", "print payload bytes annotated with the field each byte belongs to", runHexdump},
+	{"convert", "convert payload between protocols", runConvert},
+	{"compat", "report breaking changes between two IDL versions", runCompat},
+}
+
+// env is I/O of a command run.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// errUsage is returned by commands when flag parsing fails, usage is already printed.
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(&env{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:]))
+}
+
+func run(e *env, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage(e.stderr)
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		if err := cmd.run(e, args[1:]); err == errUsage {
+			return 2
+		} else if err != nil {
+			fmt.Fprintf(e.stderr, "thrift-dyn %s: %v\n", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(e.stderr, "thrift-dyn: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: thrift-dyn <command> [flags] [file]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// newFlagSet get flag set of command, its usage names the command.
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: thrift-dyn %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+var protocolAliases = map[string]thrift_dyn.ProtocolType{
+	"binary":     thrift_dyn.ProtocolType_Binary,
+	"compact":    thrift_dyn.ProtocolType_Compact,
+	"simplejson": thrift_dyn.ProtocolType_SimpleJSON,
+	"json":       thrift_dyn.ProtocolType_JSON,
+	"text":       thrift_dyn.ProtocolType_Text,
+}
+
+// protocolType get protocol type by name, e.g. `binary` or `tbinary`.
+func protocolType(name string) (thrift_dyn.ProtocolType, error) {
+	if ptype, ok := protocolAliases[name]; ok {
+		return ptype, nil
+	}
+	for _, ptype := range thrift_dyn.ProtocolType_VALUES {
+		if ptype == name {
+			return ptype, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", thrift_dyn.ErrUnsupportedProtocol, name)
+}
+
+// readInput read payload from file or stdin, hex encoded payload may contain whitespace.
+func readInput(e *env, args []string, isHex bool) (bb []byte, err error) {
+	switch {
+	case len(args) > 1:
+		return nil, fmt.Errorf("too many arguments")
+	case len(args) == 0 || args[0] == "-":
+		bb, err = io.ReadAll(e.stdin)
+	default:
+		bb, err = os.ReadFile(args[0])
+	}
+	if err != nil || !isHex {
+		return
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(string(bb)), ""))
+}
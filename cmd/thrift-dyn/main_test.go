@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/apache/thrift/lib/go/thrift"
+	thrift_dyn "github.com/ii64/go-thrift-dyn"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testRun(t *testing.T, stdin []byte, args ...string) (stdout, stderr string, code int) {
+	var outb, errb bytes.Buffer
+	code = run(&env{bytes.NewReader(stdin), &outb, &errb}, args)
+	return outb.String(), errb.String(), code
+}
+
+func testEncode(t *testing.T, ptype thrift_dyn.ProtocolType, value thrift.TStruct) []byte {
+	bb, err := thrift_dyn.NewEncoder(thrift_dyn.ProtocolFactory(ptype, &thrift.TConfiguration{})).Encode(value)
+	require.NoError(t, err)
+	return bb
+}
+
+func TestHexdump(t *testing.T) {
+	m := &base.Model{Abc: "hello", ListI64: []int64{1, 2, 3}}
+	for _, proto := range []string{"binary", "compact"} {
+		bb := testEncode(t, protocolAliases[proto], m)
+		stdout, stderr, code := testRun(t, bb, "hexdump", "-protocol", proto)
+		require.Equal(t, 0, code, stderr)
+		for _, desc := range []string{
+			`field 1 string = "hello"`,
+			"field 10 list<i64> header, size=3",
+			"  element [1] i64 = 2",
+			"field stop",
+		} {
+			require.Contains(t, stdout, desc)
+		}
+		require.NotContains(t, stdout, "undecoded")
+
+		// hex input gives the same dump.
+		hexOut, _, code := testRun(t, []byte(hex.EncodeToString(bb)), "hexdump", "-protocol", proto, "-hex")
+		require.Equal(t, 0, code)
+		require.Equal(t, stdout, hexOut)
+	}
+}
+
+func TestHexdumpMalformed(t *testing.T) {
+	bb := testEncode(t, thrift_dyn.ProtocolType_Binary, &base.Model{ListI64: []int64{1, 2, 3}})
+	cut := bytes.Index(bb, []byte{0, 0, 0, 0, 0, 0, 0, 2}) + 4
+	stdout, stderr, code := testRun(t, bb[:cut], "hexdump")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "malformed payload")
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Regexp(t, `^[0-9a-f]{8}  00 00 00 00 +undecoded$`, lines[len(lines)-2])
+	require.Contains(t, lines[len(lines)-1], "error: field 10 list<i64> truncated")
+}
+
+func TestUsage(t *testing.T) {
+	_, stderr, code := testRun(t, nil)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "hexdump")
+
+	_, stderr, code = testRun(t, nil, "nope")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `unknown command "nope"`)
+
+	_, _, code = testRun(t, nil, "hexdump", "-nope")
+	require.Equal(t, 2, code)
+
+	_, stderr, code = testRun(t, nil, "hexdump", "-protocol", "nope")
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "unsupported protocol")
+	for _, proto := range []string{"json", "tsimplejson", "text"} {
+		_, stderr, code = testRun(t, nil, "hexdump", "-protocol", proto)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "hexdump reads binary or compact")
+	}
+	_, _, code = testRun(t, testEncode(t, thrift_dyn.ProtocolType_Compact, base.NewModel()), "hexdump", "-protocol", "tcompact")
+	require.Equal(t, 0, code)
+}
+
+func TestConvert(t *testing.T) {
+	m := &base.Model{Abc: "hello", ListI64: []int64{1, 2, 3}, MapI64: map[int64]int64{1: 2}}
+	binary := testEncode(t, thrift_dyn.ProtocolType_Binary, m)
+	compact := testEncode(t, thrift_dyn.ProtocolType_Compact, m)
+
+	stdout, stderr, code := testRun(t, binary, "convert", "-from", "binary", "-to", "compact")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, string(compact), stdout)
+
+	stdout, _, code = testRun(t, []byte(hex.EncodeToString(compact)), "convert", "-from", "tcompact", "-to", "binary", "-hex")
+	require.Equal(t, 0, code)
+	require.Equal(t, hex.EncodeToString(binary)+"\n", stdout)
+
+	// bulk conversion into a directory.
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.bin", "b.bin"} {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, binary, 0o644))
+		files = append(files, file)
+	}
+	_, stderr, code = testRun(t, nil, append([]string{"convert", "-o", filepath.Join(dir, "out")}, files...)...)
+	require.Equal(t, 0, code, stderr)
+	for _, name := range []string{"a.bin", "b.bin"} {
+		bb, err := os.ReadFile(filepath.Join(dir, "out", name))
+		require.NoError(t, err)
+		require.Equal(t, compact, bb)
+	}
+	_, stderr, code = testRun(t, nil, append([]string{"convert"}, files...)...)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "-o directory is required")
+
+	_, stderr, code = testRun(t, binary[:len(binary)-2], "convert")
+	require.Equal(t, 1, code, stderr)
+}
+
+func TestCompat(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+		return path
+	}
+	old := write("old.thrift", "struct A {\n  1: i32 n\n  2: string s\n}\n")
+	renamed := write("renamed.thrift", "struct A {\n  1: i32 n\n  2: string text\n}\n")
+	widened := write("widened.thrift", "struct A {\n  1: i64 n\n}\n")
+	broken := write("broken.thrift", "struct A {\n  1 i32 n\n}\n")
+
+	stdout, stderr, code := testRun(t, nil, "compat", old, old)
+	require.Equal(t, 0, code, stderr)
+	require.Empty(t, stdout)
+
+	stdout, _, code = testRun(t, nil, "compat", old, renamed)
+	require.Equal(t, 0, code)
+	require.Equal(t, "source: struct A: field 2 's' renamed to 'text'\n", stdout)
+	_, stderr, code = testRun(t, nil, "compat", "-source", old, renamed)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "0 wire-breaking, 1 source-breaking changes")
+
+	stdout, stderr, code = testRun(t, nil, "compat", old, widened)
+	require.Equal(t, 1, code)
+	require.Contains(t, stdout, "wire: struct A: field 1 'n' type changed from i32 to i64\n")
+	require.Contains(t, stderr, "1 wire-breaking, 1 source-breaking changes")
+
+	_, stderr, code = testRun(t, nil, "compat", old, broken)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "broken.thrift:2:5: expected ':'")
+
+	_, _, code = testRun(t, nil, "compat", old)
+	require.Equal(t, 2, code)
+}
@@ -0,0 +1,233 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"strconv"
+)
+
+// CompatLevel is severity of an incompatible schema change.
+type CompatLevel uint8
+
+const (
+	// CompatSource breaks code generated from the schema, payloads are still exchangeable.
+	CompatSource CompatLevel = iota + 1
+	// CompatWire breaks payloads exchanged between peers of old and new schema.
+	CompatWire
+)
+
+func (l CompatLevel) String() string {
+	if l == CompatWire {
+		return "wire"
+	}
+	return "source"
+}
+
+// CompatIssue is an incompatible change between two schema versions.
+type CompatIssue struct {
+	Level   CompatLevel
+	Def     string // definition changed, e.g. `struct Job`
+	Message string
+}
+
+func (i CompatIssue) String() string {
+	return i.Level.String() + ": " + i.Def + ": " + i.Message
+}
+
+// CompatReport is list of incompatible changes, in order of old schema definitions.
+type CompatReport []CompatIssue
+
+// WireBreaking report whether any change breaks payloads on the wire.
+func (r CompatReport) WireBreaking() bool {
+	for _, issue := range r {
+		if issue.Level == CompatWire {
+			return true
+		}
+	}
+	return false
+}
+
+// Count get number of changes of level.
+func (r CompatReport) Count(level CompatLevel) (n int) {
+	for _, issue := range r {
+		if issue.Level == level {
+			n++
+		}
+	}
+	return
+}
+
+// CheckCompatibility compare new version of schema against old one.
+// Changes of field types, requiredness, enum values and service functions
+// that prevent old and new peers from exchanging payloads are wire-breaking,
+// renames and removals that only affect generated code are source-breaking.
+// Additions that are safe on both sides are not reported.
+func CheckCompatibility(old, new *Schema) CompatReport {
+	c := &compatChecker{}
+	for _, def := range old.Definitions {
+		switch d := def.(type) {
+		case *TypedefDesc:
+			if _, ok := new.Lookup(d.Name).(*TypedefDesc); !ok {
+				c.add(CompatSource, "typedef "+d.Name, "removed")
+			}
+		case *ConstDesc:
+			if _, ok := new.Lookup(d.Name).(*ConstDesc); !ok {
+				c.add(CompatSource, "const "+d.Name, "removed")
+			}
+		case *EnumDesc:
+			c.enum(d, new.Enum(d.Name))
+		case *StructDesc:
+			c.structure(d, new.Struct(d.Name))
+		case *ServiceDesc:
+			c.service(d, new.Service(d.Name))
+		}
+	}
+	return c.report
+}
+
+type compatChecker struct {
+	report CompatReport
+}
+
+func (c *compatChecker) add(level CompatLevel, def, format string, args ...any) {
+	c.report = append(c.report, CompatIssue{Level: level, Def: def, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *compatChecker) enum(old, new *EnumDesc) {
+	def := "enum " + old.Name
+	if new == nil {
+		c.add(CompatSource, def, "removed")
+		return
+	}
+	for _, v := range old.Values {
+		if nv := new.ValueByNumber(v.Value); nv == nil {
+			c.add(CompatWire, def, "value %s = %d removed", v.Name, v.Value)
+		} else if nv.Name != v.Name {
+			c.add(CompatSource, def, "value %d renamed from %s to %s", v.Value, v.Name, nv.Name)
+		}
+	}
+}
+
+func (c *compatChecker) structure(old, new *StructDesc) {
+	def := old.Kind.String() + " " + old.Name
+	if new == nil {
+		c.add(CompatSource, def, "removed")
+		return
+	}
+	if old.Kind != new.Kind {
+		c.add(CompatSource, def, "changed to %s", new.Kind)
+	}
+	c.fields(def, "field", old.Fields, new.Fields)
+}
+
+// fields compare fields of struct, function arguments or exceptions.
+func (c *compatChecker) fields(def, kind string, old, new []*FieldDesc) {
+	byID := make(map[TFieldID]*FieldDesc, len(new))
+	for _, f := range new {
+		byID[f.ID] = f
+	}
+	for _, f := range old {
+		name := kind + " " + strconv.Itoa(int(f.ID)) + " '" + f.Name + "'"
+		nf := byID[f.ID]
+		if nf == nil {
+			if f.Required {
+				c.add(CompatWire, def, "required %s removed", name)
+			} else {
+				c.add(CompatSource, def, "%s removed", name)
+			}
+			continue
+		}
+		delete(byID, f.ID)
+		if level := compareType(f.Type, nf.Type); level != 0 {
+			c.add(level, def, "%s type changed from %s to %s", name, f.Type, nf.Type)
+		}
+		if f.Required != nf.Required {
+			c.add(CompatWire, def, "%s changed from %s to %s", name, fieldRequiredness(f), fieldRequiredness(nf))
+		}
+		if f.Name != nf.Name {
+			c.add(CompatSource, def, "%s renamed to '%s'", name, nf.Name)
+		}
+	}
+	for _, f := range new {
+		if byID[f.ID] != nil && f.Required {
+			c.add(CompatWire, def, "required %s %d '%s' added", kind, f.ID, f.Name)
+		}
+	}
+}
+
+func fieldRequiredness(f *FieldDesc) string {
+	switch {
+	case f.Required:
+		return "required"
+	case f.Optional:
+		return "optional"
+	}
+	return "default"
+}
+
+// compareType get level of incompatibility between types, 0 if compatible.
+// Types of different wire type or referring to different structs are
+// wire-breaking, otherwise types of different IDL notation, e.g. typedef
+// or enum in place of its underlying type, are source-breaking.
+func compareType(old, new *TypeDesc) CompatLevel {
+	if old == nil || new == nil {
+		if old == new {
+			return 0
+		}
+		return CompatWire
+	}
+	if old.Type != new.Type {
+		return CompatWire
+	}
+	level := CompatLevel(0)
+	if old.Key != nil || new.Key != nil {
+		level = compareType(old.Key, new.Key)
+	}
+	if old.Value != nil || new.Value != nil {
+		if l := compareType(old.Value, new.Value); l > level {
+			level = l
+		}
+	}
+	if old.Type == thrift.STRUCT && structTypeName(old) != structTypeName(new) {
+		return CompatWire
+	}
+	if level == 0 && old.String() != new.String() {
+		level = CompatSource
+	}
+	return level
+}
+
+// structTypeName get name of struct the type refers to, through typedef if any.
+func structTypeName(d *TypeDesc) string {
+	if d.Struct != nil {
+		return d.Struct.Name
+	}
+	return d.Name
+}
+
+func (c *compatChecker) service(old, new *ServiceDesc) {
+	def := "service " + old.Name
+	if new == nil {
+		c.add(CompatWire, def, "removed")
+		return
+	}
+	if old.Extends != new.Extends {
+		c.add(CompatWire, def, "extends changed from '%s' to '%s'", old.Extends, new.Extends)
+	}
+	for _, f := range old.Functions {
+		nf := new.Function(f.Name)
+		if nf == nil {
+			c.add(CompatWire, def, "function %s removed", f.Name)
+			continue
+		}
+		fdef := def + ": function " + f.Name
+		if f.Oneway != nf.Oneway {
+			c.add(CompatWire, fdef, "oneway changed to %t", nf.Oneway)
+		}
+		if level := compareType(f.Result, nf.Result); level != 0 {
+			c.add(level, fdef, "result type changed from %s to %s", f.Result, nf.Result)
+		}
+		c.fields(fdef, "argument", f.Args, nf.Args)
+		c.fields(fdef, "exception", f.Throws, nf.Throws)
+	}
+}
@@ -0,0 +1,84 @@
+package thrift_dyn
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	old := MustParseIDL(`
+typedef i64 Timestamp
+enum Status { PENDING, RUNNING, FAILED }
+struct Owner { 1: string name }
+struct Job {
+  1: required i64 id
+  2: i32 count
+  3: list<i32> sizes
+  4: string note
+  5: Status status
+  6: required string key
+  7: Owner owner
+  8: i64 created
+  9: optional string name
+}
+service Jobs {
+  Job get(1: i64 id)
+  oneway void ping()
+  void drop(1: i64 id)
+}
+`)
+	new := MustParseIDL(`
+typedef i64 Timestamp
+enum Status { PENDING, RUNNING, FAILED_ }
+struct Owner { 1: string name }
+struct Job {
+  1: required i64 id
+  2: i64 count
+  3: list<i64> sizes
+  4: binary note
+  6: string key
+  7: Owner owner
+  8: Timestamp created
+  9: optional string title
+  10: required bool active
+}
+service Jobs {
+  Job get(1: i64 id, 2: required bool full)
+  oneway void ping()
+}
+`)
+	require.Empty(t, CheckCompatibility(old, old))
+
+	report := CheckCompatibility(old, new)
+	var issues []string
+	for _, issue := range report {
+		issues = append(issues, issue.String())
+	}
+	require.Equal(t, []string{
+		"source: enum Status: value 2 renamed from FAILED to FAILED_",
+		"wire: struct Job: field 2 'count' type changed from i32 to i64",
+		"wire: struct Job: field 3 'sizes' type changed from list<i32> to list<i64>",
+		"source: struct Job: field 4 'note' type changed from string to binary",
+		"source: struct Job: field 5 'status' removed",
+		"wire: struct Job: field 6 'key' changed from required to default",
+		"source: struct Job: field 8 'created' type changed from i64 to Timestamp",
+		"source: struct Job: field 9 'name' renamed to 'title'",
+		"wire: struct Job: required field 10 'active' added",
+		"wire: service Jobs: function get: required argument 2 'full' added",
+		"wire: service Jobs: function drop removed",
+	}, issues)
+	require.True(t, report.WireBreaking())
+	require.Equal(t, 5, report.Count(CompatSource))
+
+	// reused field id and removed enum value.
+	new = MustParseIDL(`
+enum Status { PENDING, RUNNING }
+struct Owner { 1: string name }
+struct Job { 1: required i64 id, 5: Owner status }
+`)
+	report = CheckCompatibility(old, new)
+	require.Contains(t, report, CompatIssue{CompatWire, "enum Status", "value FAILED = 2 removed"})
+	require.Contains(t, report, CompatIssue{CompatWire, "struct Job", "field 5 'status' type changed from Status to Owner"})
+	require.Contains(t, report, CompatIssue{CompatWire, "struct Job", "required field 6 'key' removed"})
+	require.Contains(t, report, CompatIssue{CompatWire, "service Jobs", "removed"})
+}
@@ -3,17 +3,24 @@ package thrift_dyn
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
 	"io"
+	"math"
 	"sync"
 )
 
 type Decoder struct {
-	buf   bytes.Buffer
-	prot  thrift.TProtocol
-	trans *thrift.StreamTransport
-	mu    sync.Mutex
+	buf    bytes.Buffer
+	reader bytes.Reader
+	limit  limitedReader
+	prot   *decodeProtocol
+	wire   *wireProtocol // nil unless wire map is enabled
+	trans  *thrift.StreamTransport
+	mu     sync.Mutex
+
+	pf       thrift.TProtocolFactory
+	buffered bool // protocol holds read-ahead input across decodes
+	renew    bool
 }
 
 func NewDecoder(pf thrift.TProtocolFactory) *Decoder {
@@ -21,23 +28,179 @@ func NewDecoder(pf thrift.TProtocolFactory) *Decoder {
 }
 
 func (dec *Decoder) Init(pf thrift.TProtocolFactory) *Decoder {
+	dec.pf = pf
 	dec.trans = thrift.NewStreamTransportR(&dec.buf)
-	dec.prot = pf.GetProtocol(dec.trans)
+	dec.prot = newDecodeProtocol(pf.GetProtocol(dec.trans))
+	dec.prot.limits = DefaultDecodeLimits
+	switch dec.prot.TProtocol.(type) {
+	case *thrift.TBinaryProtocol, *thrift.TCompactProtocol:
+		dec.buffered = false
+	default:
+		dec.buffered = true
+	}
+	dec.renew = false
+	dec.wire = nil
 	dec.buf.Reset()
 	return dec
 }
 
+// protocol get new protocol over the decoder transport.
+func (dec *Decoder) protocol() thrift.TProtocol {
+	p := dec.pf.GetProtocol(dec.trans)
+	if dec.wire != nil {
+		dec.wire.TProtocol = p
+		return dec.wire
+	}
+	return p
+}
+
+// SetReuse set reuse mode, decoding into a previously decoded value recycles
+// its fields, nested structs and container backing arrays when the shape matches.
+// Scalar and STRING field values are kept when the payload matches them,
+// so decoding the same shape again does not allocate.
+func (dec *Decoder) SetReuse(v bool) *Decoder {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	dec.prot.reuse = v
+	return dec
+}
+
+// SetZeroCopy set zero-copy mode, STRING values decoded by Decode and DecodeMessage
+// point into the source buffer instead of being copied, for Binary and Compact protocol.
+// The source buffer must outlive the decoded value and must not be modified.
+func (dec *Decoder) SetZeroCopy(v bool) *Decoder {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	dec.prot.zeroCopy = v
+	return dec
+}
+
+// SetLimits set limits enforced while decoding, see DecodeLimits.
+func (dec *Decoder) SetLimits(limits DecodeLimits) *Decoder {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	dec.prot.limits = limits
+	return dec
+}
+
+// Limits get limits enforced while decoding.
+func (dec *Decoder) Limits() DecodeLimits {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	return dec.prot.limits
+}
+
+// SetBestEffort set best-effort mode, a field value that is well-formed on the wire
+// but cannot be decoded is skipped and decoding continues with the next field,
+// any other failure stops decoding but keeps everything read so far in the destination value.
+// Failures are returned as Diagnostics with their byte offset and field path.
+func (dec *Decoder) SetBestEffort(v bool) *Decoder {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	dec.prot.bestEffort = v
+	return dec
+}
+
+// SetWireMap set wire map mode, byte offsets of every field header, value,
+// container header and element are recorded while decoding, see WireMap.
+// Offsets are exact for Binary and Compact protocol.
+func (dec *Decoder) SetWireMap(v bool) *Decoder {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	switch {
+	case v && dec.wire == nil:
+		dec.wire = &wireProtocol{TProtocol: dec.prot.TProtocol, dp: dec.prot}
+		dec.prot.TProtocol = dec.wire
+	case !v && dec.wire != nil:
+		dec.prot.TProtocol = dec.wire.TProtocol
+		dec.wire = nil
+	}
+	return dec
+}
+
+// WireMap get byte ranges recorded by the last decode in wire map mode,
+// the result is overwritten by the next decode.
+func (dec *Decoder) WireMap() WireMap {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	if dec.wire == nil {
+		return nil
+	}
+	return dec.wire.entries
+}
+
+func (dec *Decoder) setSource(src []byte) {
+	dec.reader.Reset(src)
+	dec.prot.src, dec.prot.reader = src, &dec.reader
+}
+
+func (dec *Decoder) setReader(reader io.Reader) error {
+	if dec.renew {
+		// discard protocol state left by previous decode.
+		dec.prot.TProtocol = dec.protocol()
+	}
+	if dec.wire != nil {
+		dec.wire.reset()
+	}
+	dec.renew = dec.buffered
+	dec.prot.resetLimits()
+	dec.prot.resetDiagnostics()
+	max := dec.prot.limits.MaxMessageSize
+	if dec.prot.src != nil {
+		if n := int64(len(dec.prot.src)); max > 0 && n > max {
+			return decodeLimitError(thrift.SIZE_LIMIT, "message size", n, max)
+		}
+		dec.prot.stream = nil
+		dec.trans.Reader = reader
+		return nil
+	}
+	if max <= 0 {
+		max = math.MaxInt64
+	}
+	dec.limit.reset(reader, max)
+	dec.prot.stream = &dec.limit
+	dec.trans.Reader = &dec.limit
+	return nil
+}
+
+// result get error of decoding, failures are reported as Diagnostics in best-effort mode.
+func (dec *Decoder) result(err error) error {
+	if err == nil {
+		if len(dec.prot.diags) == 0 {
+			return nil
+		}
+		return append(Diagnostics(nil), dec.prot.diags...)
+	}
+	dec.renew = true
+	if !dec.prot.bestEffort {
+		return err
+	}
+	dec.prot.diagnose(err)
+	return append(Diagnostics(nil), dec.prot.diags...)
+}
+
 func (dec *Decoder) decodeInternal(reader io.Reader, valueDst any) (err error) {
-	dec.trans.Reader = reader
-	switch value := valueDst.(type) {
-	case thrift.TStruct:
-		err = value.Read(context.Background(), dec.prot)
-	default:
-		err = fmt.Errorf("unsupported type %T", value)
+	if err = dec.setReader(reader); err != nil {
+		return
+	}
+	st, err := structOf(valueDst)
+	if err != nil {
+		return
+	}
+	err = st.Read(context.Background(), dec.prot)
+	return dec.result(err)
+}
+
+func (dec *Decoder) decodeMessageInternal(reader io.Reader, valueDst any) (h TMessageHeader, err error) {
+	if err = dec.setReader(reader); err != nil {
+		return
 	}
+	st, err := structOf(valueDst)
 	if err != nil {
 		return
 	}
+	h, err = ReadMessage(context.Background(), dec.prot, st)
+	err = dec.result(err)
 	return
 }
 
@@ -53,8 +216,28 @@ func (dec *Decoder) ReadFrom(reader io.Reader, valueDst any) (err error) {
 func (dec *Decoder) Decode(src []byte, valueDst any) (err error) {
 	dec.mu.Lock()
 	defer dec.mu.Unlock()
-	if err = dec.decodeInternal(bytes.NewReader(src), valueDst); err != nil {
+	dec.setSource(src)
+	defer dec.setSource(nil)
+	if err = dec.decodeInternal(&dec.reader, valueDst); err != nil {
 		return
 	}
 	return
 }
+
+// ReadMessageFrom read message header and body from reader,
+// message name is split into service and method name.
+func (dec *Decoder) ReadMessageFrom(reader io.Reader, valueDst any) (h TMessageHeader, err error) {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	return dec.decodeMessageInternal(reader, valueDst)
+}
+
+// DecodeMessage decode message header and body,
+// message name is split into service and method name.
+func (dec *Decoder) DecodeMessage(src []byte, valueDst any) (h TMessageHeader, err error) {
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+	dec.setSource(src)
+	defer dec.setSource(nil)
+	return dec.decodeMessageInternal(&dec.reader, valueDst)
+}
@@ -0,0 +1,304 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic describes where and why best-effort decoding failed.
+type Diagnostic struct {
+	Offset    int64  // byte offset of the failure
+	Path      string // field path from the top-level struct, e.g. `44[1].10`
+	Type      string // type of the field, e.g. `map<i64,struct>`
+	Recovered int    // container elements decoded before the failure
+	Size      int    // container elements declared on the wire, -1 if not a container failure
+	Truncated bool   // payload ended early
+	Err       error
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	if d.Path != "" {
+		sb.WriteString("field ")
+		sb.WriteString(d.Path)
+		if d.Type != "" {
+			sb.WriteString(" ")
+			sb.WriteString(d.Type)
+		}
+	} else {
+		sb.WriteString("struct")
+	}
+	if d.Truncated {
+		sb.WriteString(" truncated")
+	} else {
+		sb.WriteString(" invalid")
+	}
+	fmt.Fprintf(&sb, " at offset %d", d.Offset)
+	if d.Size >= 0 {
+		unit := "elements"
+		if strings.HasPrefix(d.Type, "map") {
+			unit = "entries"
+		}
+		fmt.Fprintf(&sb, ", %d of %d %s recovered", d.Recovered, d.Size, unit)
+	}
+	if !d.Truncated && d.Err != nil {
+		sb.WriteString(": ")
+		sb.WriteString(d.Err.Error())
+	}
+	return sb.String()
+}
+
+// Diagnostics is error of best-effort decoding, the decoded value holds
+// everything read except the failed values.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	ss := make([]string, len(ds))
+	for i, d := range ds {
+		ss[i] = d.String()
+	}
+	return strings.Join(ss, "; ")
+}
+
+func (ds Diagnostics) Unwrap() error {
+	if len(ds) > 0 {
+		return ds[0].Err
+	}
+	return nil
+}
+
+func isTruncated(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (dp *decodeProtocol) resetDiagnostics() {
+	dp.diags = dp.diags[:0]
+	dp.failing = -1
+	dp.partial = false
+	dp.skipped = -1
+	dp.frames = dp.frames[:0]
+}
+
+// diagnoseField record failure of reading field, the failure is recorded once
+// where it happens, enclosing structs prefix its path while unwinding.
+func diagnoseField(p thrift.TProtocol, id TFieldID, field *TField, err error) {
+	dp := decodeProtocolOf(p)
+	if dp == nil || !dp.bestEffort {
+		return
+	}
+	var path string
+	if field != nil {
+		path = strconv.Itoa(int(id))
+	}
+	if dp.failing >= 0 {
+		d := &dp.diags[dp.failing]
+		switch {
+		case path == "":
+		case d.Path == "" || d.Path[0] == '[':
+			d.Path = path + d.Path
+		default:
+			d.Path = path + "." + d.Path
+		}
+		if d.Type == "" && field != nil {
+			d.Type = describeFieldType(field)
+		}
+		return
+	}
+	d := Diagnostic{
+		Offset:    dp.offset(),
+		Path:      path,
+		Size:      -1,
+		Truncated: isTruncated(err),
+		Err:       err,
+	}
+	if field != nil {
+		d.Type = describeFieldType(field)
+	}
+	if dp.partial {
+		d.Recovered, d.Size = dp.recovered, dp.declared
+		dp.partial = false
+	}
+	dp.diags = append(dp.diags, d)
+	dp.failing = len(dp.diags) - 1
+}
+
+// diagnoseElem record failure of reading container element i of size.
+func diagnoseElem(p thrift.TProtocol, i, size int) {
+	dp := decodeProtocolOf(p)
+	if dp == nil || !dp.bestEffort {
+		return
+	}
+	if dp.failing >= 0 {
+		d := &dp.diags[dp.failing]
+		if d.Path != "" && d.Path[0] != '[' {
+			d.Path = "." + d.Path
+		}
+		d.Path = "[" + strconv.Itoa(i) + "]" + d.Path
+		return
+	}
+	if !dp.partial {
+		dp.partial = true
+		dp.recovered, dp.declared = i, size
+	}
+}
+
+// skipInvalid skip the rest of a value that is well-formed on the wire but
+// cannot be decoded, so that the enclosing struct can continue, see recoverField.
+func skipInvalid(p thrift.TProtocol, skip func() error) {
+	dp := decodeProtocolOf(p)
+	if dp == nil || !dp.bestEffort || dp.failing >= 0 {
+		return
+	}
+	off := dp.offset()
+	if skip != nil && skip() != nil {
+		return
+	}
+	dp.skipped, dp.skippedAt = dp.depth, off
+}
+
+// recoverField record failure of reading field of struct at depth, ok is true
+// when the field value was skipped by skipInvalid and the next field can be read.
+func recoverField(p thrift.TProtocol, depth int, field *TField, err error) (ok bool) {
+	dp := decodeProtocolOf(p)
+	if dp == nil || dp.skipped < 0 {
+		return false
+	}
+	// deeper values are skipped within a container that is not.
+	ok = dp.skipped == depth
+	dp.skipped = -1
+	if ok {
+		dp.diags = append(dp.diags, Diagnostic{
+			Offset: dp.skippedAt,
+			Path:   dp.framePath(),
+			Type:   describeFieldType(field),
+			Size:   -1,
+			Err:    err,
+		})
+	}
+	return
+}
+
+// diagFrame is struct or container being read in best-effort mode,
+// the path of a skipped value is taken from them as decoding continues.
+type diagFrame struct {
+	container bool
+	id        TFieldID // current field of struct
+	per       int      // struct or container values in an element of container
+	count     int      // struct or container values begun in container
+}
+
+func (dp *decodeProtocol) pushFrame(container bool, per int) {
+	if n := len(dp.frames); n > 0 && dp.frames[n-1].container {
+		dp.frames[n-1].count++
+	}
+	dp.frames = append(dp.frames, diagFrame{container: container, per: per})
+}
+
+func (dp *decodeProtocol) popFrame() {
+	if n := len(dp.frames); n > 0 {
+		dp.frames = dp.frames[:n-1]
+	}
+}
+
+// framePath get path of the field being read, e.g. `44[2].10`.
+func (dp *decodeProtocol) framePath() string {
+	var sb strings.Builder
+	for _, f := range dp.frames {
+		if f.container {
+			sb.WriteString("[")
+			sb.WriteString(strconv.Itoa((f.count - 1) / f.per))
+			sb.WriteString("]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(strconv.Itoa(int(f.id)))
+	}
+	return sb.String()
+}
+
+// containerPer get struct or container values in an element of container.
+func containerPer(key, value thrift.TType) int {
+	if isCompound(key) && isCompound(value) {
+		return 2
+	}
+	return 1
+}
+
+func isCompound(t thrift.TType) bool {
+	switch t {
+	case thrift.STRUCT, thrift.MAP, thrift.SET, thrift.LIST:
+		return true
+	}
+	return false
+}
+
+func (dp *decodeProtocol) ReadFieldBegin(ctx context.Context) (name string, typeId thrift.TType, id int16, err error) {
+	name, typeId, id, err = dp.TProtocol.ReadFieldBegin(ctx)
+	if n := len(dp.frames); dp.bestEffort && n > 0 {
+		dp.frames[n-1].id = TFieldID(id)
+	}
+	return
+}
+
+// decodeDepth get nesting depth of the value being read from p, -1 if unknown.
+func decodeDepth(p thrift.TProtocol) int {
+	if dp := decodeProtocolOf(p); dp != nil {
+		return dp.depth
+	}
+	return -1
+}
+
+// skipElems skip size elements of container whose header is read, with its end.
+func skipElems(ctx context.Context, p thrift.TProtocol, type_ thrift.TType, desc TypeContainerDesc, size int) (err error) {
+	for i := 0; i < size; i++ {
+		if type_ == thrift.MAP {
+			if err = thrift.SkipDefaultDepth(ctx, p, desc.Key); err != nil {
+				return
+			}
+		}
+		if err = thrift.SkipDefaultDepth(ctx, p, desc.Value); err != nil {
+			return
+		}
+	}
+	switch type_ {
+	case thrift.MAP:
+		return p.ReadMapEnd(ctx)
+	case thrift.SET:
+		return p.ReadSetEnd(ctx)
+	}
+	return p.ReadListEnd(ctx)
+}
+
+// diagnose record failure outside of struct fields, e.g. message header.
+func (dp *decodeProtocol) diagnose(err error) {
+	if !dp.bestEffort || dp.failing >= 0 {
+		return
+	}
+	dp.diags = append(dp.diags, Diagnostic{
+		Offset:    dp.offset(),
+		Size:      -1,
+		Truncated: isTruncated(err),
+		Err:       err,
+	})
+	dp.failing = len(dp.diags) - 1
+}
+
+func describeFieldType(field *TField) string {
+	if typ, ok := field.Value.(typeContainerDescriber); ok {
+		desc := typ.GetDesc()
+		switch typ.GetType() {
+		case thrift.MAP:
+			return "map<" + textTypeName(desc.Key) + "," + textTypeName(desc.Value) + ">"
+		case thrift.SET, thrift.LIST:
+			return textTypeName(typ.GetType()) + "<" + textTypeName(desc.Value) + ">"
+		}
+	}
+	return textTypeName(field.Type)
+}
@@ -0,0 +1,155 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"errors"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeBestEffortTruncated(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(&base.Request{
+		Models: []*base.Model{
+			{Abc: "a"},
+			{Abc: "b"},
+			{Abc: "c", ListI64: []int64{4, 5, 6}},
+		},
+	})
+	require.NoError(t, err)
+	// cut in the middle of the second list element.
+	cut := bytes.Index(bb, []byte{0, 0, 0, 0, 0, 0, 0, 5}) + 4
+	src := bb[:cut]
+
+	var st RPCStruct
+	dec := NewDecoder(pf)
+	err = dec.Decode(src, &st)
+	require.Error(t, err)
+	var diags Diagnostics
+	require.False(t, errors.As(err, &diags))
+
+	dec.SetBestEffort(true)
+	for _, decode := range []func() error{
+		func() error { return dec.Decode(src, &st) },
+		func() error { return dec.ReadFrom(bytes.NewReader(src), &st) },
+	} {
+		st = RPCStruct{}
+		err = decode()
+		require.True(t, errors.As(err, &diags), err)
+		require.Equal(t, Diagnostics{{
+			Offset:    int64(cut),
+			Path:      "44[2].10",
+			Type:      "list<i64>",
+			Recovered: 1,
+			Size:      3,
+			Truncated: true,
+			Err:       diags[0].Err,
+		}}, diags)
+		require.Equal(t, "field 44[2].10 list<i64> truncated at offset "+strconv.Itoa(cut)+", 1 of 3 elements recovered", err.Error())
+
+		// everything before the failure is kept.
+		models, ok := testField(&st, 44).Value.(*TypeContainerList[thrift.TStruct])
+		require.True(t, ok)
+		require.Len(t, models.Value, 3)
+		last := models.Value[2].(*RPCStruct)
+		require.Equal(t, []byte("c"), testField(last, 1).Value)
+		require.Equal(t, []int64{4}, testField(last, 10).Value.(*TypeContainerList[int64]).Value)
+	}
+}
+
+func TestDecodeBestEffortInvalid(t *testing.T) {
+	// compact: field 1 i32 = 1, field 2 of unknown type 13.
+	src := []byte{0x15, 0x02, 0x2d}
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).SetBestEffort(true)
+
+	var st RPCStruct
+	err := dec.Decode(src, &st)
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags), err)
+	require.Len(t, diags, 1)
+	require.False(t, diags[0].Truncated)
+	require.Equal(t, int64(3), diags[0].Offset)
+	require.Contains(t, err.Error(), "struct invalid at offset 3: ")
+	require.Equal(t, int32(1), testField(&st, 1).Value)
+
+	// diagnostics do not leak into the next decode.
+	require.NoError(t, dec.Decode([]byte{0x15, 0x02, 0x00}, &st))
+}
+
+func testField(s *RPCStruct, id TFieldID) *TField {
+	for _, f := range s.Fields {
+		if f.ID == id {
+			return f
+		}
+	}
+	return &TField{}
+}
+
+func TestDecodeBestEffortSkipInvalid(t *testing.T) {
+	type point struct {
+		X int32 `thrift:"x,1"`
+	}
+	type grid struct {
+		A    map[point]int32 `thrift:"a,1"`
+		N    int32           `thrift:"n,2"`
+		B    map[point]int32 `thrift:"b,3"`
+		Tail string          `thrift:"tail,4"`
+	}
+	type outer struct {
+		Grid  grid   `thrift:"grid,1"`
+		N     int64  `thrift:"n,2"`
+		Grids []grid `thrift:"grids,3"`
+	}
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(&outer{
+			Grid: grid{
+				A:    map[point]int32{{X: 1}: 1},
+				N:    7,
+				B:    map[point]int32{{X: 2}: 2, {X: 3}: 3},
+				Tail: "tail",
+			},
+			N:     9,
+			Grids: []grid{{N: 1}, {B: map[point]int32{{X: 4}: 4}, N: 2}},
+		})
+		require.NoError(t, err)
+
+		var st RPCStruct
+		dec := NewDecoder(pf)
+		require.Error(t, dec.Decode(bb, &st))
+
+		// maps with struct keys are skipped, every other field is decoded.
+		st = RPCStruct{}
+		err = dec.SetBestEffort(true).Decode(bb, &st)
+		var diags Diagnostics
+		require.True(t, errors.As(err, &diags), err)
+		require.Len(t, diags, 3)
+		for i, path := range []string{"1.1", "1.3", "3[1].3"} {
+			require.Equal(t, path, diags[i].Path)
+			require.Equal(t, "map", diags[i].Type)
+			require.False(t, diags[i].Truncated)
+			require.Equal(t, -1, diags[i].Size)
+			require.Contains(t, diags[i].Err.Error(), "unhandled type key:{STRUCT}")
+		}
+		require.Less(t, diags[0].Offset, diags[1].Offset)
+		grid, ok := testField(&st, 1).Value.(*RPCStruct)
+		require.True(t, ok)
+		require.Equal(t, []TFieldID{2, 4}, fieldIDs(grid))
+		require.Equal(t, int32(7), testField(grid, 2).Value)
+		require.Equal(t, []byte("tail"), testField(grid, 4).Value)
+		require.Equal(t, int64(9), testField(&st, 2).Value)
+		grids, ok := testField(&st, 3).Value.(*TypeContainerList[thrift.TStruct])
+		require.True(t, ok)
+		require.Len(t, grids.Value, 2)
+		require.Equal(t, int32(2), testField(grids.Value[1].(*RPCStruct), 2).Value)
+
+		// a failure that is not skipped is still recorded after them.
+		st = RPCStruct{}
+		err = dec.Decode(bb[:len(bb)-3], &st)
+		require.True(t, errors.As(err, &diags), err)
+		require.Len(t, diags, 4)
+		require.True(t, diags[3].Truncated, diags)
+	})
+}
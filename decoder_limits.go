@@ -0,0 +1,238 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"io"
+)
+
+var (
+	ErrDecodeLimit = errors.New("decode limit exceeded")
+)
+
+// DecodeLimits bounds resources consumed by decoding untrusted input,
+// zero value of a limit means unlimited.
+type DecodeLimits struct {
+	MaxDepth             int   // nesting depth of structs and containers
+	MaxContainerElements int   // elements of a single container, map entries for map
+	MaxTotalElements     int   // elements of all containers in a decode
+	MaxStringLength      int   // length of STRING value in bytes
+	MaxMessageSize       int64 // bytes read in a decode
+}
+
+// DefaultDecodeLimits is limits of NewDecoder, the nesting depth matches thrift.Skip.
+var DefaultDecodeLimits = DecodeLimits{
+	MaxDepth: thrift.DEFAULT_RECURSION_DEPTH,
+}
+
+func decodeLimitError(typeId int, what string, n, max int64) error {
+	return thrift.NewTProtocolExceptionWithType(typeId, fmt.Errorf("%w: %s %d exceeds %d", ErrDecodeLimit, what, n, max))
+}
+
+func (dp *decodeProtocol) resetLimits() {
+	dp.depth, dp.elements = 0, 0
+}
+
+func (dp *decodeProtocol) enter() error {
+	dp.depth++
+	if max := dp.limits.MaxDepth; max > 0 && dp.depth > max {
+		return decodeLimitError(thrift.DEPTH_LIMIT, "depth", int64(dp.depth), int64(max))
+	}
+	return nil
+}
+
+func (dp *decodeProtocol) leave() {
+	dp.depth--
+}
+
+func (dp *decodeProtocol) checkContainer(size int) error {
+	if max := dp.limits.MaxContainerElements; max > 0 && size > max {
+		return decodeLimitError(thrift.SIZE_LIMIT, "container size", int64(size), int64(max))
+	}
+	dp.elements += size
+	if max := dp.limits.MaxTotalElements; max > 0 && dp.elements > max {
+		return decodeLimitError(thrift.SIZE_LIMIT, "total elements", int64(dp.elements), int64(max))
+	}
+	return nil
+}
+
+// checkDeclaredSize bound container size declared on the wire by the bytes left in
+// the transport of p, each element takes at least one byte, or by the default max
+// message size if that is unknown. It holds for protocols not of Decoder, which
+// have no DecodeLimits, e.g. RPCStruct.Read on a thrift.TProtocol.
+func checkDeclaredSize(p thrift.TProtocol, size int) error {
+	max := uint64(thrift.DEFAULT_MAX_MESSAGE_SIZE)
+	if trans := p.Transport(); trans != nil {
+		if n := trans.RemainingBytes(); n < max {
+			max = n
+		}
+	}
+	if size > 0 && uint64(size) > max {
+		return thrift.NewTProtocolExceptionWithType(thrift.SIZE_LIMIT,
+			fmt.Errorf("%w: container size %d exceeds %d bytes left", ErrDecodeLimit, size, max))
+	}
+	return nil
+}
+
+func (dp *decodeProtocol) checkString(size int) error {
+	if max := dp.limits.MaxStringLength; max > 0 && size > max {
+		return decodeLimitError(thrift.SIZE_LIMIT, "string length", int64(size), int64(max))
+	}
+	return nil
+}
+
+func (dp *decodeProtocol) ReadStructBegin(ctx context.Context) (name string, err error) {
+	if name, err = dp.TProtocol.ReadStructBegin(ctx); err != nil {
+		return
+	}
+	if dp.bestEffort {
+		dp.pushFrame(false, 0)
+	}
+	return name, dp.enter()
+}
+
+func (dp *decodeProtocol) ReadStructEnd(ctx context.Context) error {
+	dp.leave()
+	if dp.bestEffort {
+		dp.popFrame()
+	}
+	return dp.TProtocol.ReadStructEnd(ctx)
+}
+
+func (dp *decodeProtocol) ReadMapBegin(ctx context.Context) (keyType thrift.TType, valueType thrift.TType, size int, err error) {
+	if keyType, valueType, size, err = dp.TProtocol.ReadMapBegin(ctx); err != nil {
+		return
+	}
+	if dp.bestEffort {
+		dp.pushFrame(true, containerPer(keyType, valueType))
+	}
+	if err = dp.enter(); err != nil {
+		return
+	}
+	err = dp.checkContainer(size)
+	return
+}
+
+func (dp *decodeProtocol) ReadMapEnd(ctx context.Context) error {
+	dp.leave()
+	if dp.bestEffort {
+		dp.popFrame()
+	}
+	return dp.TProtocol.ReadMapEnd(ctx)
+}
+
+func (dp *decodeProtocol) ReadListBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	if elemType, size, err = dp.TProtocol.ReadListBegin(ctx); err != nil {
+		return
+	}
+	if dp.bestEffort {
+		dp.pushFrame(true, 1)
+	}
+	if err = dp.enter(); err != nil {
+		return
+	}
+	err = dp.checkContainer(size)
+	return
+}
+
+func (dp *decodeProtocol) ReadListEnd(ctx context.Context) error {
+	dp.leave()
+	if dp.bestEffort {
+		dp.popFrame()
+	}
+	return dp.TProtocol.ReadListEnd(ctx)
+}
+
+func (dp *decodeProtocol) ReadSetBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	if elemType, size, err = dp.TProtocol.ReadSetBegin(ctx); err != nil {
+		return
+	}
+	if dp.bestEffort {
+		dp.pushFrame(true, 1)
+	}
+	if err = dp.enter(); err != nil {
+		return
+	}
+	err = dp.checkContainer(size)
+	return
+}
+
+func (dp *decodeProtocol) ReadSetEnd(ctx context.Context) error {
+	dp.leave()
+	if dp.bestEffort {
+		dp.popFrame()
+	}
+	return dp.TProtocol.ReadSetEnd(ctx)
+}
+
+func (dp *decodeProtocol) ReadBinary(ctx context.Context) (b []byte, err error) {
+	if dp.limits.MaxStringLength <= 0 {
+		return dp.TProtocol.ReadBinary(ctx)
+	}
+	size, ok, err := readBinarySize(ctx, dp)
+	if !ok {
+		if b, err = dp.TProtocol.ReadBinary(ctx); err != nil {
+			return
+		}
+		return b, dp.checkString(len(b))
+	} else if err != nil {
+		return
+	}
+	if b, err = readFullInto(dp.Transport(), make([]byte, 0), size); err == nil {
+		if w := wireOf(dp); w != nil {
+			w.endValue(b)
+		}
+	}
+	return
+}
+
+func (dp *decodeProtocol) ReadString(ctx context.Context) (s string, err error) {
+	if dp.limits.MaxStringLength <= 0 {
+		return dp.TProtocol.ReadString(ctx)
+	}
+	var b []byte
+	b, err = dp.ReadBinary(ctx)
+	return string(b), err
+}
+
+// limitedReader fails reads beyond n bytes.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+	buf [1]byte
+}
+
+func (l *limitedReader) reset(r io.Reader, max int64) {
+	l.r, l.n, l.max = r, max, max
+}
+
+func (l *limitedReader) Read(b []byte) (n int, err error) {
+	if l.n <= 0 {
+		return 0, decodeLimitError(thrift.SIZE_LIMIT, "message size", l.max+1, l.max)
+	}
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+	n, err = l.r.Read(b)
+	l.n -= int64(n)
+	return
+}
+
+func (l *limitedReader) ReadByte() (c byte, err error) {
+	if l.n <= 0 {
+		return 0, decodeLimitError(thrift.SIZE_LIMIT, "message size", l.max+1, l.max)
+	}
+	if br, ok := l.r.(io.ByteReader); ok {
+		c, err = br.ReadByte()
+	} else {
+		_, err = io.ReadFull(l.r, l.buf[:])
+		c = l.buf[0]
+	}
+	if err == nil {
+		l.n--
+	}
+	return
+}
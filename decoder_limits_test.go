@@ -0,0 +1,114 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecodeLimitsDepth(t *testing.T) {
+	// compact: field 1 list<list>, nested N times, innermost list<i32> is empty.
+	nested := func(n int) []byte {
+		bb := []byte{0x19}
+		for i := 0; i < n; i++ {
+			bb = append(bb, 0x19)
+		}
+		return append(bb, 0x05, 0x00)
+	}
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration))
+	var st RPCStruct
+	require.NoError(t, dec.Decode(nested(10), &st))
+
+	err := dec.Decode(nested(100000), &st)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrDecodeLimit), err)
+
+	dec.SetLimits(DecodeLimits{MaxDepth: 5})
+	require.Error(t, dec.Decode(nested(10), &st))
+	require.NoError(t, dec.Decode(nested(3), &st))
+}
+
+func TestDecodeLimitsElements(t *testing.T) {
+	var err error
+	m := base.Model{Abc: "hello", ListI64: []int64{1, 2, 3}, MapI64: map[int64]int64{1: 2}}
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(&m)
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			limits DecodeLimits
+			ok     bool
+		}{
+			{DecodeLimits{MaxContainerElements: 3}, true},
+			{DecodeLimits{MaxContainerElements: 2}, false},
+			{DecodeLimits{MaxTotalElements: 4}, true},
+			{DecodeLimits{MaxTotalElements: 3}, false},
+			{DecodeLimits{MaxStringLength: 5}, true},
+			{DecodeLimits{MaxStringLength: 4}, false},
+			{DecodeLimits{MaxMessageSize: int64(len(bb))}, true},
+			{DecodeLimits{MaxMessageSize: int64(len(bb)) - 1}, false},
+		} {
+			for _, dec := range []*Decoder{
+				NewDecoder(pf),
+				NewDecoder(pf).SetReuse(true),
+				NewDecoder(pf).SetZeroCopy(true),
+			} {
+				dec.SetLimits(tc.limits)
+				var st RPCStruct
+				err = dec.Decode(bb, &st)
+				if tc.ok {
+					require.NoError(t, err, tc.limits)
+				} else {
+					require.True(t, errors.Is(err, ErrDecodeLimit), "%+v: %v", tc.limits, err)
+				}
+				var st2 RPCStruct
+				err = dec.ReadFrom(bytes.NewReader(bb), &st2)
+				if tc.ok {
+					require.NoError(t, err, tc.limits)
+				} else {
+					require.True(t, errors.Is(err, ErrDecodeLimit), "%+v: %v", tc.limits, err)
+				}
+			}
+		}
+	})
+	_ = err
+}
+
+func TestDecodeLimitsDeclaredSize(t *testing.T) {
+	// compact: field 1 list<i64> declaring 2^31-1 elements without payload.
+	bb := []byte{0x19, 0xf6, 0xff, 0xff, 0xff, 0xff, 0x07}
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).
+		SetLimits(DecodeLimits{MaxContainerElements: 1 << 16})
+	var st RPCStruct
+	err := dec.Decode(bb, &st)
+	require.True(t, errors.Is(err, ErrDecodeLimit), err)
+
+	// binary: field 1 string declaring 2^31-1 bytes without payload.
+	bb = []byte{0x0b, 0x00, 0x01, 0x7f, 0xff, 0xff, 0xff}
+	dec = NewDecoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).
+		SetLimits(DecodeLimits{MaxStringLength: 1 << 20})
+	err = dec.Decode(bb, &st)
+	require.True(t, errors.Is(err, ErrDecodeLimit), err)
+}
+
+func TestDecodeLimitsDeclaredSizeRaw(t *testing.T) {
+	// compact: field 1 list<i64> declaring 2^20 elements without payload, below
+	// max message size checked by thrift, read without Decoder.
+	bb := []byte{0x19, 0xf6, 0x80, 0x80, 0x40}
+	p := thrift.NewTCompactProtocolConf(thrift.NewTMemoryBufferLen(len(bb)), defaultTestTConfiguration)
+	_, err := p.Transport().Write(bb)
+	require.NoError(t, err)
+	var st RPCStruct
+	err = st.Read(context.Background(), p)
+	var pe thrift.TProtocolException
+	require.True(t, errors.As(err, &pe), err)
+	require.Equal(t, thrift.SIZE_LIMIT, pe.TypeId(), err)
+	require.True(t, errors.Is(err, ErrDecodeLimit), err)
+
+	// the default depth limit is the only one enforced by Decoder.
+	require.Equal(t, DecodeLimits{MaxDepth: thrift.DEFAULT_RECURSION_DEPTH}, NewDecoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).Limits())
+}
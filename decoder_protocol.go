@@ -0,0 +1,220 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"golang.org/x/exp/slices"
+	"io"
+	"math"
+)
+
+// decodeProtocol carries Decoder options down to the read paths.
+type decodeProtocol struct {
+	thrift.TProtocol
+
+	reuse   bool
+	scratch []byte
+
+	zeroCopy bool
+	src      []byte        // source of Decoder.Decode, nil when reading from a stream
+	reader   *bytes.Reader // position in src
+
+	limits   DecodeLimits
+	depth    int
+	elements int
+	stream   *limitedReader // position in stream of Decoder.ReadFrom
+
+	bestEffort bool
+	diags      []Diagnostic
+	failing    int // index of diagnostic being unwound, -1 if none
+	partial    bool
+	recovered  int
+	declared   int
+	skipped    int   // depth of invalid value skipped on the wire, -1 if none
+	skippedAt  int64 // offset of skipped value
+	frames     []diagFrame
+}
+
+func newDecodeProtocol(p thrift.TProtocol) *decodeProtocol {
+	return &decodeProtocol{TProtocol: p, failing: -1, skipped: -1}
+}
+
+// offset get byte offset of next read, -1 if unknown.
+func (dp *decodeProtocol) offset() int64 {
+	if dp.src != nil {
+		return int64(len(dp.src) - dp.reader.Len())
+	}
+	if dp.stream != nil {
+		return dp.stream.max - dp.stream.n
+	}
+	return -1
+}
+
+// decodeProtocolOf get decoding options of p, nil if p is not installed by Decoder.
+func decodeProtocolOf(p thrift.TProtocol) *decodeProtocol {
+	dp, _ := p.(*decodeProtocol)
+	return dp
+}
+
+// isDecodeReuse report whether values can be recycled while reading from p.
+func isDecodeReuse(p thrift.TProtocol) bool {
+	dp, ok := p.(*decodeProtocol)
+	return ok && dp.reuse
+}
+
+// readString read STRING, old is returned as is when the payload matches it.
+func (dp *decodeProtocol) readString(ctx context.Context, old string) (s string, err error) {
+	if b, ok, err := dp.readBinaryZeroCopy(ctx); ok {
+		if w := wireOf(dp); w != nil && err == nil {
+			w.endValue(Bs2String(b))
+		}
+		return Bs2String(b), err
+	}
+	if dp.scratch, err = readBinaryInto(ctx, dp, dp.scratch[:0]); err != nil {
+		return
+	}
+	if string(dp.scratch) == old {
+		return old, nil
+	}
+	return string(dp.scratch), nil
+}
+
+// readBinaryZeroCopy read STRING as a slice of the source buffer,
+// ok is false when zero-copy is disabled or not supported by the protocol.
+// In wire map mode the caller records the end of the value.
+func (dp *decodeProtocol) readBinaryZeroCopy(ctx context.Context) (b []byte, ok bool, err error) {
+	if !dp.zeroCopy || dp.src == nil {
+		return nil, false, nil
+	}
+	var size int
+	if size, ok, err = readBinarySize(ctx, dp); !ok || err != nil {
+		return
+	}
+	off := len(dp.src) - dp.reader.Len()
+	if size > dp.reader.Len() {
+		return nil, true, thrift.NewTProtocolException(io.ErrUnexpectedEOF)
+	}
+	if _, err = dp.reader.Seek(int64(size), io.SeekCurrent); err != nil {
+		return nil, true, thrift.NewTProtocolException(err)
+	}
+	return dp.src[off : off+size : off+size], true, nil
+}
+
+// readStringValue read STRING as string, pointing into the source buffer in zero-copy mode.
+func readStringValue(ctx context.Context, p thrift.TProtocol) (string, error) {
+	if dp, ok := p.(*decodeProtocol); ok {
+		if b, ok, err := dp.readBinaryZeroCopy(ctx); ok {
+			if w := wireOf(dp); w != nil && err == nil {
+				w.endValue(Bs2String(b))
+			}
+			return Bs2String(b), err
+		}
+	}
+	return p.ReadString(ctx)
+}
+
+// readBinaryValue read STRING as binary, pointing into the source buffer in zero-copy mode.
+func readBinaryValue(ctx context.Context, p thrift.TProtocol) ([]byte, error) {
+	if dp, ok := p.(*decodeProtocol); ok {
+		if b, ok, err := dp.readBinaryZeroCopy(ctx); ok {
+			if w := wireOf(dp); w != nil && err == nil {
+				w.endValue(b)
+			}
+			return b, err
+		}
+	}
+	return p.ReadBinary(ctx)
+}
+
+// readBinarySize read length prefix of STRING,
+// ok is false when protocol is not Binary or Compact.
+// In wire map mode the payload is read past the recorder, so the start of
+// the value is recorded here and its end by the caller once the payload is read.
+func readBinarySize(ctx context.Context, p thrift.TProtocol) (size int, ok bool, err error) {
+	dp, isDp := p.(*decodeProtocol)
+	if isDp {
+		p = dp.TProtocol
+	}
+	w, isWire := p.(*wireProtocol)
+	if isWire {
+		p = w.TProtocol
+	}
+	switch pp := p.(type) {
+	case *thrift.TBinaryProtocol:
+		if isWire {
+			w.beginValue(thrift.STRING)
+		}
+		var sz int32
+		if sz, err = pp.ReadI32(ctx); err != nil {
+			return 0, true, err
+		}
+		size = int(sz)
+	case *thrift.TCompactProtocol:
+		br, isBr := pp.Transport().(io.ByteReader)
+		if !isBr {
+			return 0, false, nil
+		}
+		if isWire {
+			w.beginValue(thrift.STRING)
+		}
+		var sz uint64
+		if sz, err = binary.ReadUvarint(br); err != nil {
+			return 0, true, thrift.NewTProtocolException(err)
+		}
+		if sz > math.MaxInt32 {
+			return 0, true, thrift.NewTProtocolExceptionWithType(thrift.SIZE_LIMIT, fmt.Errorf("binary size %d exceeds limit", sz))
+		}
+		size = int(sz)
+	default:
+		return 0, false, nil
+	}
+	if size < 0 {
+		return 0, true, thrift.NewTProtocolExceptionWithType(thrift.NEGATIVE_SIZE, fmt.Errorf("negative binary size %d", size))
+	}
+	if isDp {
+		err = dp.checkString(size)
+	}
+	return size, true, err
+}
+
+// readBinaryInto read STRING into backing array of dst.
+// Binary and Compact protocol read the payload directly from transport,
+// other protocols fallback to ReadBinary.
+func readBinaryInto(ctx context.Context, p thrift.TProtocol, dst []byte) (b []byte, err error) {
+	size, ok, err := readBinarySize(ctx, p)
+	if !ok {
+		return p.ReadBinary(ctx)
+	} else if err != nil {
+		return dst, err
+	}
+	if b, err = readFullInto(p.Transport(), dst[:0], size); err == nil {
+		if w := wireOf(p); w != nil {
+			w.endValue(append([]byte{}, b...))
+		}
+	}
+	return
+}
+
+// readFullInto read size bytes into dst, the buffer grows as the payload
+// arrives so a bogus size fails on EOF before allocating all of it.
+func readFullInto(r io.Reader, dst []byte, size int) ([]byte, error) {
+	const chunk = 64 << 10
+	for len(dst) < size {
+		n := size - len(dst)
+		if free := cap(dst) - len(dst); n > free && n > chunk {
+			n = chunk
+			if free > n {
+				n = free
+			}
+		}
+		off := len(dst)
+		dst = slices.Grow(dst, n)[:off+n]
+		if _, err := io.ReadFull(r, dst[off:]); err != nil {
+			return dst[:off], thrift.NewTProtocolException(err)
+		}
+	}
+	return dst, nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/ii64/go-thrift-dyn/internal/test/base"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 )
 
@@ -44,3 +45,248 @@ func TestDecode(t *testing.T) {
 
 	}
 }
+
+func testReuseRequest() *base.Request {
+	return &base.Request{
+		Model: &base.Model{Abc: "hello", Sd: 0xcafe, F64: 1.05, ListI64: []int64{1, 2, 3}, MapI64: map[int64]int64{1: 2}},
+		Models: []*base.Model{
+			{Abc: "a", ListI64: []int64{4}},
+			{Abc: "b", MapI32: map[int32]int32{3: 4}},
+		},
+		ModelById: map[int64]*base.Model{
+			1234: base.NewModel(),
+		},
+		ModelByTime: map[int64][]*base.Model{
+			567: {base.NewModel()},
+		},
+		Modset: []*base.Model{base.NewModel()},
+	}
+}
+
+func TestDecodeReuse(t *testing.T) {
+	var err error
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		enc := NewEncoder(pf)
+		dec := NewDecoder(pf).SetReuse(true)
+
+		bb, err := enc.Encode(testReuseRequest())
+		require.NoError(t, err)
+		other, err := enc.Encode(&base.Request{
+			Model:       &base.Model{Abc: "other", Sd: 1},
+			ModelById:   map[int64]*base.Model{1: base.NewModel()},
+			ModelByTime: map[int64][]*base.Model{2: {}},
+		})
+		require.NoError(t, err)
+
+		var st RPCStruct
+		for _, src := range [][]byte{bb, other, bb, bb} {
+			err = dec.Decode(src, &st)
+			require.NoError(t, err)
+			actual, err := enc.Encode(&st)
+			require.NoError(t, err)
+			require.Equal(t, src, actual)
+		}
+
+		model := st.Fields[0].Value.(*RPCStruct)
+		require.Equal(t, []byte("hello"), model.Fields[0].Value)
+		require.Equal(t, int64(0xcafe), model.Fields[1].Value)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			if err = dec.Decode(bb, &st); err != nil {
+				t.Fatal(err)
+			}
+		})
+		require.Zero(t, allocs)
+	})
+	_ = err
+}
+
+func TestDecodeReuseString(t *testing.T) {
+	var err error
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration))
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).SetReuse(true)
+	bb, err := enc.Encode(&base.Model{Abc: "hello"})
+	require.NoError(t, err)
+
+	var st RPCStruct
+	st.AddField(NewTField(1, thrift.STRING, "abc", true).SetValue(""))
+	require.NoError(t, dec.Decode(bb, &st))
+	require.Equal(t, "hello", st.Fields[0].Value)
+
+	// shape change drops the recycled value.
+	bb, err = enc.Encode(testReuseRequest())
+	require.NoError(t, err)
+	require.NoError(t, dec.Decode(bb, &st))
+	require.Equal(t, TFieldID(6), st.Fields[0].ID)
+	require.IsType(t, &RPCStruct{}, st.Fields[0].Value)
+}
+
+func TestDecodeReuseRetainedValues(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	enc := NewEncoder(pf)
+	dec := NewDecoder(pf).SetReuse(true)
+	first, err := enc.Encode(&base.Model{Abc: "hello", Sd: 0xcafe})
+	require.NoError(t, err)
+	second, err := enc.Encode(&base.Model{Abc: "world", Sd: 1})
+	require.NoError(t, err)
+
+	var st RPCStruct
+	st.AddField(NewTField(1, thrift.STRING, "abc", true).SetValue(""))
+	require.NoError(t, dec.Decode(first, &st))
+	abc, sd := testField(&st, 1).Value, testField(&st, 4).Value
+	seen := map[any]bool{abc: true}
+
+	// values taken from a previous decode do not change.
+	require.NoError(t, dec.Decode(second, &st))
+	require.Equal(t, "world", testField(&st, 1).Value)
+	require.Equal(t, int64(1), testField(&st, 4).Value)
+	require.Equal(t, "hello", abc)
+	require.Equal(t, int64(0xcafe), sd)
+	require.True(t, seen["hello"])
+}
+
+func TestDecodeReuseNestedMap(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	st, err := NewStruct("Nested").
+		Field(1, "maps", NewTypeDescList(NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDesc(thrift.I64))),
+			[]map[int64]int64{{1: 2, 3: 4}, {5: 6}}).
+		Build()
+	require.NoError(t, err)
+	bb, err := NewEncoder(pf).Encode(st)
+	require.NoError(t, err)
+
+	dec := NewDecoder(pf).SetReuse(true)
+	var out RPCStruct
+	require.NoError(t, dec.Decode(bb, &out))
+	allocs := testing.AllocsPerRun(100, func() {
+		if err = dec.Decode(bb, &out); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.Zero(t, allocs)
+	maps, err := testField(&out, 1).GetList()
+	require.NoError(t, err)
+	require.Len(t, maps, 2)
+}
+
+func benchmarkDecodeRequest(b *testing.B, reuse bool) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(testReuseRequest())
+	require.NoError(b, err)
+	dec := NewDecoder(pf).SetReuse(reuse)
+	var st RPCStruct
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = dec.Decode(bb, &st); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeRequest(b *testing.B) {
+	benchmarkDecodeRequest(b, false)
+}
+
+func BenchmarkDecodeRequestReuse(b *testing.B) {
+	benchmarkDecodeRequest(b, true)
+}
+
+func TestDecodeZeroCopy(t *testing.T) {
+	var err error
+	expected := base.Model{Abc: "hello", ListI64: []int64{1}}
+	for _, proto := range append(defaultTestTProtocols, ProtocolType_Text) {
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		enc := NewEncoder(pf)
+		dec := NewDecoder(pf).SetZeroCopy(true).SetReuse(true)
+		bb, err := enc.Encode(&expected)
+		require.NoError(t, err)
+
+		var st RPCStruct
+		st.AddField(NewTField(1, thrift.STRING, "abc", true).SetValue(""))
+		err = dec.Decode(bb, &st)
+		require.NoError(t, err)
+		require.Equal(t, "hello", st.Fields[0].Value)
+		var st2 RPCStruct
+		err = dec.Decode(bb, &st2)
+		require.NoError(t, err)
+		require.Equal(t, []byte("hello"), st2.Fields[0].Value)
+
+		// values alias the source buffer.
+		idx := bytes.Index(bb, []byte("hello"))
+		bb[idx] = 'j'
+		if proto == ProtocolType_Text {
+			require.Equal(t, "hello", st.Fields[0].Value)
+			continue
+		}
+		require.Equal(t, "jello", st.Fields[0].Value)
+		require.Equal(t, []byte("jello"), st2.Fields[0].Value)
+
+		// streams are copied.
+		var st3 RPCStruct
+		err = dec.ReadFrom(bytes.NewReader(bb), &st3)
+		require.NoError(t, err)
+		bb[idx] = 'h'
+		require.Equal(t, []byte("jello"), st3.Fields[0].Value)
+	}
+	_ = err
+}
+
+func TestDecodeZeroCopyTruncated(t *testing.T) {
+	var err error
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(&base.Model{Abc: "hello"})
+		require.NoError(t, err)
+		dec := NewDecoder(pf).SetZeroCopy(true)
+		var st RPCStruct
+		idx := bytes.Index(bb, []byte("hello"))
+		require.Error(t, dec.Decode(bb[:idx+2], &st))
+	})
+	_ = err
+}
+
+func BenchmarkDecodeBlob(b *testing.B) {
+	benchmarkDecodeBlob(b, false)
+}
+
+func BenchmarkDecodeBlobZeroCopy(b *testing.B) {
+	benchmarkDecodeBlob(b, true)
+}
+
+func benchmarkDecodeBlob(b *testing.B, zeroCopy bool) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(&base.Model{Abc: string(make([]byte, 1<<20))})
+	require.NoError(b, err)
+	dec := NewDecoder(pf).SetZeroCopy(zeroCopy)
+	var st RPCStruct
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = dec.Decode(bb, &st); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoderSettersConcurrent(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode((&RPCStruct{}).AddField(NewTField(1, thrift.STRING, "", false).SetValue("a")))
+	require.NoError(t, err)
+	dec := NewDecoder(pf)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			var st RPCStruct
+			require.NoError(t, dec.Decode(bb, &st))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			dec.SetReuse(i%2 == 0).SetZeroCopy(i%2 == 0).SetBestEffort(i%2 == 0).SetLimits(dec.Limits())
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,458 @@
+package thrift_dyn
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"strconv"
+	"strings"
+)
+
+// WireKind is kind of byte range recorded in WireMap.
+type WireKind uint8
+
+const (
+	WireMessageHeader   WireKind = iota // message name, type and sequence id
+	WireFieldHeader                     // field id and type
+	WireFieldStop                       // end of struct fields
+	WireField                           // field value
+	WireContainerHeader                 // container element types and size
+	WireElement                         // list or set element
+	WireMapKey                          // map entry key
+	WireMapValue                        // map entry value
+)
+
+// WireEntry is byte range [Start, End) of an item decoded from the wire,
+// End is -1 when decoding failed before the item was complete.
+type WireEntry struct {
+	Kind       WireKind
+	Start, End int64
+	Depth      int          // nesting depth, 0 for fields of the top-level struct
+	Path       string       // path of the value, e.g. `44[2].10`, as in Diagnostic
+	ID         TFieldID     // id of field
+	Index      int          // index of element or map entry
+	Type       thrift.TType // type of value, or of container for header
+	Key, Elem  thrift.TType // key and element type of container value
+	Size       int          // size of container header
+	Value      any          // value of scalar and STRING, name of message header
+}
+
+// WireMap is byte ranges of a decoded payload in wire order,
+// an item is listed before the items nested in it.
+type WireMap []WireEntry
+
+// Leaf report whether the entry does not contain other entries,
+// the leaves of a decoded payload cover each byte exactly once.
+func (e WireEntry) Leaf() bool {
+	switch e.Kind {
+	case WireField, WireElement, WireMapKey, WireMapValue:
+		switch e.Type {
+		case thrift.STRUCT, thrift.MAP, thrift.SET, thrift.LIST:
+			return false
+		}
+	}
+	return true
+}
+
+// TypeName get type name of the entry, e.g. `map<i64,struct>`.
+func (e WireEntry) TypeName() string {
+	switch e.Type {
+	case thrift.MAP:
+		if e.Key != thrift.STOP || e.Elem != thrift.STOP {
+			return "map<" + textTypeName(e.Key) + "," + textTypeName(e.Elem) + ">"
+		}
+	case thrift.SET, thrift.LIST:
+		if e.Elem != thrift.STOP {
+			return textTypeName(e.Type) + "<" + textTypeName(e.Elem) + ">"
+		}
+	}
+	return textTypeName(e.Type)
+}
+
+func (e WireEntry) String() string {
+	var sb strings.Builder
+	switch e.Kind {
+	case WireMessageHeader:
+		fmt.Fprintf(&sb, "message %q header", e.Value)
+		return sb.String()
+	case WireFieldStop:
+		return "field stop"
+	case WireElement:
+		fmt.Fprintf(&sb, "element [%d] ", e.Index)
+	case WireMapKey:
+		fmt.Fprintf(&sb, "key [%d] ", e.Index)
+	case WireMapValue:
+		fmt.Fprintf(&sb, "value [%d] ", e.Index)
+	case WireContainerHeader:
+		if strings.HasSuffix(e.Path, "]") {
+			fmt.Fprintf(&sb, "element [%d] ", e.Index)
+		} else {
+			fmt.Fprintf(&sb, "field %d ", e.ID)
+		}
+	default:
+		fmt.Fprintf(&sb, "field %d ", e.ID)
+	}
+	switch e.Kind {
+	case WireFieldHeader:
+		sb.WriteString("header, type=")
+		sb.WriteString(textTypeName(e.Type))
+	case WireContainerHeader:
+		sb.WriteString(e.TypeName())
+		fmt.Fprintf(&sb, " header, size=%d", e.Size)
+	default:
+		sb.WriteString(e.TypeName())
+		switch v := e.Value.(type) {
+		case nil:
+		case string:
+			fmt.Fprintf(&sb, " = %q", v)
+		case []byte:
+			fmt.Fprintf(&sb, " = %q", v)
+		default:
+			fmt.Fprintf(&sb, " = %v", v)
+		}
+	}
+	return sb.String()
+}
+
+type wireFrameKind uint8
+
+const (
+	wireFrameStruct wireFrameKind = iota
+	wireFrameList
+	wireFrameMap
+)
+
+type wireFrame struct {
+	kind  wireFrameKind
+	path  string
+	id    TFieldID // current field of struct
+	field int      // entry of current field value, -1 if none
+	index int      // current element of container, map counts keys and values
+	elem  int      // entry of current element, -1 if none
+}
+
+// wireProtocol records byte ranges of everything read through it,
+// offsets are taken from the position of the decoder transport.
+type wireProtocol struct {
+	thrift.TProtocol
+	dp      *decodeProtocol
+	entries WireMap
+	stack   []wireFrame
+}
+
+// wireOf get recorder below p, nil if wire map mode is off.
+func wireOf(p thrift.TProtocol) *wireProtocol {
+	if dp, ok := p.(*decodeProtocol); ok {
+		p = dp.TProtocol
+	}
+	w, _ := p.(*wireProtocol)
+	return w
+}
+
+func (w *wireProtocol) reset() {
+	w.entries = w.entries[:0]
+	w.stack = w.stack[:0]
+}
+
+func (w *wireProtocol) top() *wireFrame {
+	if n := len(w.stack); n > 0 {
+		return &w.stack[n-1]
+	}
+	return nil
+}
+
+func (w *wireProtocol) add(e WireEntry) int {
+	e.Depth = len(w.stack) - 1
+	if e.Depth < 0 {
+		e.Depth = 0
+	}
+	w.entries = append(w.entries, e)
+	return len(w.entries) - 1
+}
+
+// valuePath get path of value being read.
+func (w *wireProtocol) valuePath() string {
+	f := w.top()
+	switch {
+	case f == nil:
+		return ""
+	case f.kind == wireFrameStruct:
+		return joinWirePath(f.path, strconv.Itoa(int(f.id)))
+	case f.kind == wireFrameMap:
+		return f.path + "[" + strconv.Itoa(f.index/2) + "]"
+	default:
+		return f.path + "[" + strconv.Itoa(f.index) + "]"
+	}
+}
+
+func joinWirePath(prefix, id string) string {
+	if prefix == "" {
+		return id
+	}
+	return prefix + "." + id
+}
+
+// beginValue record start of value of type t, returns its path.
+func (w *wireProtocol) beginValue(t thrift.TType) string {
+	path := w.valuePath()
+	f := w.top()
+	if f == nil || f.kind == wireFrameStruct {
+		return path
+	}
+	e := WireEntry{Kind: WireElement, Start: w.dp.offset(), End: -1, Path: path, Index: f.index, Type: t}
+	if f.kind == wireFrameMap {
+		e.Kind, e.Index = WireMapKey, f.index/2
+		if f.index%2 == 1 {
+			e.Kind = WireMapValue
+		}
+	}
+	f.elem = w.add(e)
+	return path
+}
+
+// endValue record end of value, v is the value of scalar.
+func (w *wireProtocol) endValue(v any) {
+	f := w.top()
+	if f == nil {
+		return
+	}
+	i := f.field
+	if f.kind != wireFrameStruct {
+		i, f.elem = f.elem, -1
+		f.index++
+	}
+	if i < 0 {
+		return
+	}
+	e := &w.entries[i]
+	e.End, e.Value = w.dp.offset(), v
+}
+
+// setContainer describe container value being read by the enclosing frame.
+func (w *wireProtocol) setContainer(key, elem thrift.TType) {
+	f := w.top()
+	if f == nil {
+		return
+	}
+	i := f.field
+	if f.kind != wireFrameStruct {
+		i = f.elem
+	}
+	if i >= 0 {
+		w.entries[i].Key, w.entries[i].Elem = key, elem
+	}
+}
+
+func (w *wireProtocol) ReadMessageBegin(ctx context.Context) (name string, typeId thrift.TMessageType, seqid int32, err error) {
+	start := w.dp.offset()
+	if name, typeId, seqid, err = w.TProtocol.ReadMessageBegin(ctx); err != nil {
+		return
+	}
+	w.add(WireEntry{Kind: WireMessageHeader, Start: start, End: w.dp.offset(), Value: name})
+	return
+}
+
+func (w *wireProtocol) ReadStructBegin(ctx context.Context) (name string, err error) {
+	path := w.beginValue(thrift.STRUCT)
+	if name, err = w.TProtocol.ReadStructBegin(ctx); err != nil {
+		return
+	}
+	w.stack = append(w.stack, wireFrame{kind: wireFrameStruct, path: path, field: -1, elem: -1})
+	return
+}
+
+func (w *wireProtocol) ReadStructEnd(ctx context.Context) (err error) {
+	if err = w.TProtocol.ReadStructEnd(ctx); err != nil {
+		return
+	}
+	if n := len(w.stack); n > 0 {
+		w.stack = w.stack[:n-1]
+	}
+	w.endValue(nil)
+	return
+}
+
+func (w *wireProtocol) ReadFieldBegin(ctx context.Context) (name string, typeId thrift.TType, id int16, err error) {
+	start := w.dp.offset()
+	if name, typeId, id, err = w.TProtocol.ReadFieldBegin(ctx); err != nil {
+		return
+	}
+	f := w.top()
+	if f == nil {
+		return
+	}
+	end := w.dp.offset()
+	if typeId == thrift.STOP {
+		w.add(WireEntry{Kind: WireFieldStop, Start: start, End: end, Path: f.path})
+		return
+	}
+	f.id = id
+	path := joinWirePath(f.path, strconv.Itoa(int(id)))
+	w.add(WireEntry{Kind: WireFieldHeader, Start: start, End: end, Path: path, ID: id, Type: typeId})
+	f.field = w.add(WireEntry{Kind: WireField, Start: end, End: -1, Path: path, ID: id, Type: typeId})
+	return
+}
+
+func (w *wireProtocol) ReadFieldEnd(ctx context.Context) (err error) {
+	if err = w.TProtocol.ReadFieldEnd(ctx); err != nil {
+		return
+	}
+	if f := w.top(); f != nil && f.field >= 0 {
+		if e := &w.entries[f.field]; e.End < 0 {
+			e.End = w.dp.offset()
+		}
+		f.field = -1
+	}
+	return
+}
+
+func (w *wireProtocol) beginContainer(kind wireFrameKind, t, key, elem thrift.TType, size int, start int64, path string) {
+	w.setContainer(key, elem)
+	w.add(WireEntry{Kind: WireContainerHeader, Start: start, End: w.dp.offset(), Path: path, ID: w.fieldID(), Index: w.index(), Type: t, Key: key, Elem: elem, Size: size})
+	w.stack = append(w.stack, wireFrame{kind: kind, path: path, field: -1, elem: -1})
+}
+
+func (w *wireProtocol) endContainer() {
+	if n := len(w.stack); n > 0 {
+		w.stack = w.stack[:n-1]
+	}
+	w.endValue(nil)
+}
+
+// fieldID get id of field being read by the top frame.
+func (w *wireProtocol) fieldID() TFieldID {
+	if f := w.top(); f != nil && f.kind == wireFrameStruct {
+		return f.id
+	}
+	return 0
+}
+
+// index get index of element being read by the top frame.
+func (w *wireProtocol) index() int {
+	if f := w.top(); f != nil && f.kind == wireFrameMap {
+		return f.index / 2
+	} else if f != nil {
+		return f.index
+	}
+	return 0
+}
+
+func (w *wireProtocol) ReadMapBegin(ctx context.Context) (keyType thrift.TType, valueType thrift.TType, size int, err error) {
+	path := w.beginValue(thrift.MAP)
+	start := w.dp.offset()
+	if keyType, valueType, size, err = w.TProtocol.ReadMapBegin(ctx); err != nil {
+		return
+	}
+	w.beginContainer(wireFrameMap, thrift.MAP, keyType, valueType, size, start, path)
+	return
+}
+
+func (w *wireProtocol) ReadMapEnd(ctx context.Context) (err error) {
+	if err = w.TProtocol.ReadMapEnd(ctx); err == nil {
+		w.endContainer()
+	}
+	return
+}
+
+func (w *wireProtocol) ReadListBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	path := w.beginValue(thrift.LIST)
+	start := w.dp.offset()
+	if elemType, size, err = w.TProtocol.ReadListBegin(ctx); err != nil {
+		return
+	}
+	w.beginContainer(wireFrameList, thrift.LIST, thrift.STOP, elemType, size, start, path)
+	return
+}
+
+func (w *wireProtocol) ReadListEnd(ctx context.Context) (err error) {
+	if err = w.TProtocol.ReadListEnd(ctx); err == nil {
+		w.endContainer()
+	}
+	return
+}
+
+func (w *wireProtocol) ReadSetBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	path := w.beginValue(thrift.SET)
+	start := w.dp.offset()
+	if elemType, size, err = w.TProtocol.ReadSetBegin(ctx); err != nil {
+		return
+	}
+	w.beginContainer(wireFrameList, thrift.SET, thrift.STOP, elemType, size, start, path)
+	return
+}
+
+func (w *wireProtocol) ReadSetEnd(ctx context.Context) (err error) {
+	if err = w.TProtocol.ReadSetEnd(ctx); err == nil {
+		w.endContainer()
+	}
+	return
+}
+
+func (w *wireProtocol) ReadBool(ctx context.Context) (v bool, err error) {
+	w.beginValue(thrift.BOOL)
+	if v, err = w.TProtocol.ReadBool(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadByte(ctx context.Context) (v int8, err error) {
+	w.beginValue(thrift.BYTE)
+	if v, err = w.TProtocol.ReadByte(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadI16(ctx context.Context) (v int16, err error) {
+	w.beginValue(thrift.I16)
+	if v, err = w.TProtocol.ReadI16(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadI32(ctx context.Context) (v int32, err error) {
+	w.beginValue(thrift.I32)
+	if v, err = w.TProtocol.ReadI32(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadI64(ctx context.Context) (v int64, err error) {
+	w.beginValue(thrift.I64)
+	if v, err = w.TProtocol.ReadI64(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadDouble(ctx context.Context) (v float64, err error) {
+	w.beginValue(thrift.DOUBLE)
+	if v, err = w.TProtocol.ReadDouble(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadString(ctx context.Context) (v string, err error) {
+	w.beginValue(thrift.STRING)
+	if v, err = w.TProtocol.ReadString(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+func (w *wireProtocol) ReadBinary(ctx context.Context) (v []byte, err error) {
+	w.beginValue(thrift.STRING)
+	if v, err = w.TProtocol.ReadBinary(ctx); err == nil {
+		w.endValue(v)
+	}
+	return
+}
+
+// Skip skip value through the recorder, so skipped fields are mapped too.
+func (w *wireProtocol) Skip(ctx context.Context, fieldType thrift.TType) (err error) {
+	return thrift.SkipDefaultDepth(ctx, w, fieldType)
+}
@@ -0,0 +1,113 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecodeWireMap(t *testing.T) {
+	var err error
+	withProtocols(t, []ProtocolType{ProtocolType_Binary, ProtocolType_Compact}, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(testReuseRequest())
+		require.NoError(t, err)
+
+		for _, dec := range []*Decoder{
+			NewDecoder(pf).SetWireMap(true),
+			NewDecoder(pf).SetWireMap(true).SetReuse(true),
+			NewDecoder(pf).SetWireMap(true).SetReuse(true).SetZeroCopy(true),
+		} {
+			var st RPCStruct
+			for i := 0; i < 2; i++ {
+				require.NoError(t, dec.Decode(bb, &st))
+				wm := dec.WireMap()
+				require.NotEmpty(t, wm)
+
+				// leaves cover the payload in order, each byte exactly once.
+				var off int64
+				for _, e := range wm {
+					require.GreaterOrEqual(t, e.End, e.Start, e)
+					if e.Leaf() {
+						require.Equal(t, off, e.Start, e)
+						off = e.End
+					}
+				}
+				require.Equal(t, int64(len(bb)), off)
+			}
+		}
+	})
+	_ = err
+}
+
+func TestDecodeWireMapZeroCopy(t *testing.T) {
+	var err error
+	withProtocols(t, []ProtocolType{ProtocolType_Binary, ProtocolType_Compact}, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(&base.Model{Abc: "hello", ListI64: []int64{1, 2}})
+		require.NoError(t, err)
+
+		var st RPCStruct
+		dec := NewDecoder(pf).SetWireMap(true)
+		require.NoError(t, dec.Decode(bb, &st))
+		expected := append(WireMap(nil), dec.WireMap()...)
+
+		// zero-copy is kept in wire map mode, the map is the same.
+		dec.SetZeroCopy(true)
+		for _, limits := range []DecodeLimits{{}, {MaxStringLength: 16}} {
+			st = RPCStruct{}
+			require.NoError(t, dec.SetLimits(limits).Decode(bb, &st))
+			require.Equal(t, expected, dec.WireMap())
+			abc := testField(&st, 1).Value.([]byte)
+			idx := bytes.Index(bb, []byte("hello"))
+			require.Same(t, &bb[idx], &abc[0])
+		}
+	})
+	_ = err
+}
+
+func TestDecodeWireMapEntries(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(&base.Model{ListI64: []int64{1, 2, 3}})
+	require.NoError(t, err)
+
+	dec := NewDecoder(pf)
+	var st RPCStruct
+	require.NoError(t, dec.Decode(bb, &st))
+	require.Nil(t, dec.WireMap())
+
+	dec.SetWireMap(true)
+	require.NoError(t, dec.Decode(bb, &st))
+	var descs []string
+	for _, e := range dec.WireMap() {
+		if e.Path == "10" || e.Path == "10[1]" {
+			descs = append(descs, e.String())
+		}
+	}
+	require.Equal(t, []string{
+		"field 10 header, type=list",
+		"field 10 list<i64>",
+		"field 10 list<i64> header, size=3",
+		"element [1] i64 = 2",
+	}, descs)
+	for _, e := range dec.WireMap() {
+		if e.Kind == WireElement {
+			require.Equal(t, int64(8), e.End-e.Start)
+			require.Equal(t, 1, e.Depth)
+		}
+	}
+
+	// message header comes first, field 4 follows empty string field 1.
+	msg := NewEncoder(pf)
+	mb, err := msg.EncodeMessage(NewTMessageHeader("Svc:call", thrift.CALL, 1), &base.Model{Sd: 1})
+	require.NoError(t, err)
+	_, err = dec.DecodeMessage(mb, &st)
+	require.NoError(t, err)
+	wm := dec.WireMap()
+	require.Equal(t, WireMessageHeader, wm[0].Kind)
+	require.Equal(t, `message "Svc:call" header`, wm[0].String())
+	require.Contains(t, wm, WireEntry{
+		Kind: WireField, Start: wm[0].End + 10, End: wm[0].End + 18,
+		Path: "4", ID: 4, Type: thrift.I64, Value: int64(1),
+	})
+}
@@ -3,7 +3,6 @@ package thrift_dyn
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
 	"io"
 	"sync"
@@ -12,8 +11,11 @@ import (
 type Encoder struct {
 	buf   bytes.Buffer
 	prot  thrift.TProtocol
+	opts  *encodeProtocol // nil unless an encoding option is set
 	trans *thrift.StreamTransport
 	mu    sync.Mutex
+
+	pf thrift.TProtocolFactory
 }
 
 func NewEncoder(pf thrift.TProtocolFactory) *Encoder {
@@ -21,26 +23,99 @@ func NewEncoder(pf thrift.TProtocolFactory) *Encoder {
 }
 
 func (enc *Encoder) Init(pf thrift.TProtocolFactory) *Encoder {
+	enc.pf = pf
 	enc.trans = thrift.NewStreamTransportRW(&enc.buf)
 	enc.prot = pf.GetProtocol(enc.trans)
+	enc.opts = nil
 	enc.buf.Reset()
 	return enc
 }
 
+// SetOmitDefaults set omit defaults mode, fields of struct plans equal to
+// the default declared by the schema are left out unless they are required.
+func (enc *Encoder) SetOmitDefaults(v bool) *Encoder {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if v || enc.opts != nil {
+		enc.options().omitDefaults = v
+	}
+	return enc
+}
+
+// OmitDefaults get omit defaults mode.
+func (enc *Encoder) OmitDefaults() bool {
+	return enc.opts != nil && enc.opts.omitDefaults
+}
+
+// SetCanonical set canonical mode, struct fields are written in field ID order,
+// map entries in key order and set elements in order with duplicates removed,
+// of repeated field IDs or map keys only the last is written, so equal values
+// are always encoded to identical bytes.
+func (enc *Encoder) SetCanonical(v bool) *Encoder {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if v || enc.opts != nil {
+		enc.options().canonical = v
+	}
+	return enc
+}
+
+// Canonical get canonical mode.
+func (enc *Encoder) Canonical() bool {
+	return enc.opts != nil && enc.opts.canonical
+}
+
+// options get protocol carrying encoding options, enc.prot is wrapped on first use.
+func (enc *Encoder) options() *encodeProtocol {
+	if enc.opts == nil {
+		enc.opts = &encodeProtocol{TProtocol: enc.prot}
+		enc.prot = enc.opts
+	}
+	return enc.opts
+}
+
 func (enc *Encoder) encodeInternal(value any) (err error) {
 	enc.buf.Reset()
-	switch value := value.(type) {
-	case thrift.TStruct:
-		err = value.Write(context.Background(), enc.prot)
-	default:
-		err = fmt.Errorf("uns"+
-			"unsupported type %T", value)
+	st, err := structOf(value)
+	if err != nil {
+		return
 	}
+	if err = st.Write(context.Background(), enc.prot); err != nil {
+		enc.renew()
+		return
+	}
+	return enc.flush()
+}
+
+func (enc *Encoder) encodeMessageInternal(h TMessageHeader, value any) (err error) {
+	enc.buf.Reset()
+	st, err := structOf(value)
 	if err != nil {
 		return
 	}
-	err = enc.prot.Flush(context.Background())
-	return
+	if err = WriteMessage(context.Background(), enc.prot, h, st); err != nil {
+		enc.renew()
+		return
+	}
+	return enc.flush()
+}
+
+// renew discard output buffered by transport and protocol state left by failed encode.
+func (enc *Encoder) renew() {
+	enc.trans = thrift.NewStreamTransportRW(&enc.buf)
+	if enc.opts != nil {
+		enc.opts.TProtocol = enc.pf.GetProtocol(enc.trans)
+	} else {
+		enc.prot = enc.pf.GetProtocol(enc.trans)
+	}
+}
+
+// flush flush protocol and transport, TSimpleJSONProtocol does not flush its transport.
+func (enc *Encoder) flush() (err error) {
+	if err = enc.prot.Flush(context.Background()); err != nil {
+		return
+	}
+	return enc.trans.Flush(context.Background())
 }
 
 func (enc *Encoder) WriteTo(writer io.Writer, value any) (n int64, err error) {
@@ -76,3 +151,30 @@ func (enc *Encoder) Encode(value any) (bb []byte, err error) {
 	copy(bb, enc.buf.Bytes())
 	return
 }
+
+// WriteMessageTo write message header and body to writer,
+// message name is prefixed with service name if set.
+func (enc *Encoder) WriteMessageTo(writer io.Writer, h TMessageHeader, value any) (n int64, err error) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	err = enc.encodeMessageInternal(h, value)
+	if err != nil {
+		return
+	}
+	n, err = enc.buf.WriteTo(writer)
+	return
+}
+
+// EncodeMessage encode message header and body,
+// message name is prefixed with service name if set.
+func (enc *Encoder) EncodeMessage(h TMessageHeader, value any) (bb []byte, err error) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	err = enc.encodeMessageInternal(h, value)
+	if err != nil {
+		return
+	}
+	bb = make([]byte, enc.buf.Len())
+	copy(bb, enc.buf.Bytes())
+	return
+}
@@ -0,0 +1,201 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// canonicalFields get fields in field ID order, of repeated field IDs only the last
+// field is kept, fs is returned as is when already ordered.
+func canonicalFields(fs []*TField) []*TField {
+	ordered := true
+	for i := 1; i < len(fs) && ordered; i++ {
+		ordered = fs[i-1].ID < fs[i].ID
+	}
+	if ordered {
+		return fs
+	}
+	fs = append([]*TField(nil), fs...)
+	sort.SliceStable(fs, func(i, j int) bool { return fs[i].ID < fs[j].ID })
+	out := fs[:0]
+	for i, f := range fs {
+		if i+1 < len(fs) && fs[i+1].ID == f.ID {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// canonicalElems get elements in canonical order, of equal elements only the last is kept if dedup.
+func canonicalElems[T any](vv []T, dedup bool) []T {
+	return pickOrder(vv, mapKeySorter(vv, dedup))
+}
+
+// canonicalEntries get map entries in key order, of repeated keys only the last entry is kept.
+func canonicalEntries[K comparable, V any](vv []TypeContainerMapItem[K, V]) []TypeContainerMapItem[K, V] {
+	keys := make([]K, len(vv))
+	for i := range vv {
+		keys[i] = vv[i].Key
+	}
+	return pickOrder(vv, mapKeySorter(keys, true))
+}
+
+// canonicalKeys get keys of m in order.
+func canonicalKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return pickOrder(keys, mapKeySorter(keys, false))
+}
+
+// canonicalValues get Go values in canonical order, of equal values only the last is kept if dedup.
+func canonicalValues(vv []reflect.Value, dedup bool) []reflect.Value {
+	values := make([]any, len(vv))
+	for i := range vv {
+		values[i] = vv[i].Interface()
+	}
+	return pickOrder(vv, mapKeySorter(values, dedup))
+}
+
+// pickOrder get values of vv at indexes of order, vv as is if order is nil.
+func pickOrder[T any](vv []T, order []int) []T {
+	if order == nil {
+		return vv
+	}
+	out := make([]T, len(order))
+	for i, j := range order {
+		out[i] = vv[j]
+	}
+	return out
+}
+
+// canonicalKey get value to compare v by, struct, container and Go map values
+// are compared by their canonical binary encoding.
+func canonicalKey(v any) any {
+	var write func(ctx context.Context, p thrift.TProtocol) error
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case TEnum:
+		return v.Value
+	case thrift.TStruct:
+		write = v.Write
+	case TypeContainerImplementer:
+		write = v.Write
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map, reflect.Struct, reflect.Pointer:
+			c, err := goCodecOf(rv.Type(), false)
+			if err != nil {
+				return fmt.Sprint(v)
+			}
+			write = func(ctx context.Context, p thrift.TProtocol) error {
+				return c.write(ctx, p, rv)
+			}
+		default:
+			return v
+		}
+	}
+	if isNilValue(reflect.ValueOf(v)) {
+		return nil
+	}
+	buf := thrift.NewTMemoryBuffer()
+	p := &encodeProtocol{TProtocol: thrift.NewTBinaryProtocolConf(buf, nil), canonical: true}
+	if err := write(context.Background(), p); err != nil {
+		return fmt.Sprint(v) // not encodable, the write fails again later.
+	}
+	_ = p.Flush(context.Background())
+	return buf.Bytes()
+}
+
+// compareValues order bool, numeric, string and binary values and slices of them,
+// values of different kind are ordered by kind.
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		return compareBool(a != nil, b != nil)
+	}
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	ka, kb := compareKind(ra), compareKind(rb)
+	if ka != kb {
+		return cmp.Compare(ka, kb)
+	}
+	switch ka {
+	case reflect.Bool:
+		return compareBool(ra.Bool(), rb.Bool())
+	case reflect.Int64:
+		return cmp.Compare(ra.Int(), rb.Int())
+	case reflect.Uint64:
+		return cmp.Compare(ra.Uint(), rb.Uint())
+	case reflect.Float64:
+		return compareFloat(ra.Float(), rb.Float())
+	case reflect.String:
+		return bytes.Compare(compareBytes(ra), compareBytes(rb))
+	case reflect.Slice:
+		for i := 0; i < ra.Len() && i < rb.Len(); i++ {
+			if c := compareValues(canonicalKey(ra.Index(i).Interface()), canonicalKey(rb.Index(i).Interface())); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(ra.Len(), rb.Len())
+	}
+	return 0
+}
+
+// compareKind get kind values of v are compared as, string and binary are ordered together.
+func compareKind(v reflect.Value) reflect.Kind {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint64
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.String
+		}
+		return reflect.Slice
+	}
+	return v.Kind()
+}
+
+func compareBytes(v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.String:
+		return String2bs(v.String())
+	case reflect.Array:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return b
+	}
+	return v.Bytes()
+}
+
+// compareBool order false before true.
+func compareBool(a, b bool) int {
+	if a == b {
+		return 0
+	}
+	if b {
+		return -1
+	}
+	return 1
+}
+
+// compareFloat order floats ascending with NaN first, -0 and +0 or NaNs of
+// different payload are distinct by their bits, they encode differently.
+func compareFloat(a, b float64) int {
+	if c := cmp.Compare(a, b); c != 0 {
+		return c
+	}
+	return cmp.Compare(math.Float64bits(a), math.Float64bits(b))
+}
@@ -0,0 +1,239 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func canonicalTestStruct(reverse bool) *RPCStruct {
+	elem := func(x int64) *RPCStruct {
+		return (&RPCStruct{Name: "S"}).AddField(NewTField(1, thrift.I64, "x", false).SetValue(x))
+	}
+	byID := NewTypeContainerMap[int64, string](TypeContainerDesc{Key: thrift.I64, Value: thrift.STRING}, false)
+	tags := NewTypeContainerSet[string](TypeContainerDesc{Value: thrift.STRING}, false)
+	byName := NewTypeContainerMapUnordered[string, int64](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I64}, false)
+	elems := NewTypeContainerSet[thrift.TStruct](TypeContainerDesc{Value: thrift.STRUCT}, false)
+	byElem := NewTypeContainerMap[thrift.TStruct, int32](TypeContainerDesc{Key: thrift.STRUCT, Value: thrift.I32}, false)
+	byData := NewTypeContainerMap[Container, int32](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I32}, false)
+	for i := 0; i < 16; i++ {
+		byName.AddKV("k"+strconv.Itoa(i), int64(i))
+	}
+	if reverse {
+		byID.AddKV(2, "b")
+		byID.AddKV(1, "a")
+		tags.Add("b", "a", "b")
+		elems.Add(elem(2), elem(1), elem(2))
+		byElem.AddKV(elem(2), 2)
+		byElem.AddKV(elem(1), 1)
+		byData.AddKV([]byte("b"), 2)
+		byData.AddKV([]byte("a"), 1)
+	} else {
+		byID.AddKV(1, "a")
+		byID.AddKV(2, "b")
+		tags.Add("a", "b")
+		elems.Add(elem(1), elem(2))
+		byElem.AddKV(elem(1), 1)
+		byElem.AddKV(elem(2), 2)
+		byData.AddKV([]byte("a"), 1)
+		byData.AddKV([]byte("b"), 2)
+	}
+	fields := []*TField{
+		NewTField(1, thrift.MAP, "byID", false).SetValue(byID),
+		NewTField(2, thrift.SET, "tags", false).SetValue(tags),
+		NewTField(3, thrift.MAP, "byName", false).SetValue(byName),
+		NewTField(4, thrift.SET, "elems", false).SetValue(elems),
+		NewTField(5, thrift.MAP, "byElem", false).SetValue(byElem),
+		NewTField(6, thrift.MAP, "byData", false).SetValue(byData),
+	}
+	if reverse {
+		for i, j := 0, len(fields)-1; i < j; i, j = i+1, j-1 {
+			fields[i], fields[j] = fields[j], fields[i]
+		}
+	}
+	return (&RPCStruct{Name: "T"}).AddField(fields...)
+}
+
+func TestEncoderCanonical(t *testing.T) {
+	s := NewTypeDescStruct(NewStructDesc("S", NewFieldDesc(1, NewTypeDesc(thrift.I64), "x", false)))
+	pl, err := CompileStruct(NewStructDesc("T",
+		NewFieldDesc(1, NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDesc(thrift.STRING)), "byID", false),
+		NewFieldDesc(2, NewTypeDescSet(NewTypeDesc(thrift.STRING)), "tags", false),
+		NewFieldDesc(3, NewTypeDescMap(NewTypeDesc(thrift.STRING), NewTypeDesc(thrift.I64)), "byName", false),
+		NewFieldDesc(4, NewTypeDescSet(s), "elems", false),
+		NewFieldDesc(5, NewTypeDescMap(s, NewTypeDesc(thrift.I32)), "byElem", false),
+		NewFieldDesc(6, NewTypeDescMap(&TypeDesc{Type: thrift.STRING, Name: "binary"}, NewTypeDesc(thrift.I32)), "byData", false),
+	))
+	require.NoError(t, err)
+
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		enc := NewEncoder(pf).SetCanonical(true)
+		require.True(t, enc.Canonical())
+		expected, err := enc.Encode(canonicalTestStruct(false))
+		require.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			actual, err := enc.Encode(canonicalTestStruct(true))
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+			actual, err = enc.Encode(pl.Bind(canonicalTestStruct(true)))
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+		}
+
+		// map of struct keys cannot be decoded.
+		noStructKey := canonicalTestStruct(true)
+		fields := noStructKey.Fields[:0]
+		for _, f := range noStructKey.Fields {
+			if f.ID != 5 {
+				fields = append(fields, f)
+			}
+		}
+		noStructKey.Fields = fields
+		bb, err := enc.Encode(noStructKey)
+		require.NoError(t, err)
+		var st RPCStruct
+		require.NoError(t, NewDecoder(pf).Decode(bb, &st))
+		require.Equal(t, []TFieldID{1, 2, 3, 4, 6}, fieldIDs(&st))
+		tags, err := testField(&st, 2).GetSet()
+		require.NoError(t, err)
+		require.Equal(t, []any{"a", "b"}, tags)
+		elems, err := testField(&st, 4).GetSet()
+		require.NoError(t, err)
+		require.Len(t, elems, 2)
+		keys, err := testField(&st, 3).GetMap()
+		require.NoError(t, err)
+		for i := 1; i < len(keys); i++ {
+			require.Less(t, keys[i-1].Key, keys[i].Key)
+		}
+
+		enc.SetCanonical(false)
+		require.False(t, enc.Canonical())
+		bb, err = enc.Encode(noStructKey)
+		require.NoError(t, err)
+		st = RPCStruct{}
+		require.NoError(t, NewDecoder(pf).Decode(bb, &st))
+		require.Equal(t, []TFieldID{6, 4, 3, 2, 1}, fieldIDs(&st))
+	})
+}
+
+func TestEncoderCanonicalTagged(t *testing.T) {
+	type tagged struct {
+		IDs  []int32        `thrift:"ids,2,set"`
+		Name string         `thrift:"name,1"`
+		Sets [][]int32      `thrift:"sets,3,set"`
+		Byte map[int8]int64 `thrift:"byte,4"`
+	}
+	v := tagged{
+		IDs:  []int32{3, 1, 3, 2},
+		Name: "n",
+		Sets: [][]int32{{2}, {1, 2}, {2}},
+		Byte: map[int8]int64{3: 1, -1: 2, 0: 3},
+	}
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).SetCanonical(true).Encode(&v)
+		require.NoError(t, err)
+		var st RPCStruct
+		require.NoError(t, NewDecoder(pf).Decode(bb, &st))
+		require.Equal(t, []TFieldID{1, 2, 3, 4}, fieldIDs(&st))
+		ids, err := testField(&st, 2).GetSet()
+		require.NoError(t, err)
+		require.Equal(t, []any{int32(1), int32(2), int32(3)}, ids)
+		sets, err := testField(&st, 3).GetSet()
+		require.NoError(t, err)
+		require.Len(t, sets, 2)
+
+		var out tagged
+		require.NoError(t, NewDecoder(pf).Decode(bb, &out))
+		require.Equal(t, []int32{1, 2, 3}, out.IDs)
+		require.Equal(t, [][]int32{{1, 2}, {2}}, out.Sets)
+		require.Equal(t, v.Byte, out.Byte)
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	for _, tc := range []struct {
+		a, b any
+		c    int
+	}{
+		{nil, false, -1},
+		{false, true, -1},
+		{int8(-1), int64(0), -1},
+		{int32(2), int32(2), 0},
+		{1.5, 0.5, 1},
+		{math.Copysign(0, -1), 0.0, 1},
+		{math.NaN(), math.Float64frombits(math.Float64bits(math.NaN()) + 1), -1},
+		{"a", []byte("b"), -1},
+		{[]byte("ab"), "a", 1},
+		{[]int32{1, 2}, []int32{1}, 1},
+		{TEnum{Value: 1}, TEnum{Value: 2}, -1},
+		{int64(1), "a", -1}, // ordered by kind
+	} {
+		require.Equal(t, tc.c, compareValues(canonicalKey(tc.a), canonicalKey(tc.b)), "%v %v", tc.a, tc.b)
+	}
+}
+
+func TestEncoderCanonicalFloatSet(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).SetCanonical(true)
+	encode := func(vs ...float64) []byte {
+		set := NewTypeContainerSet[float64](TypeContainerDesc{Value: thrift.DOUBLE}, false)
+		set.Add(vs...)
+		bb, err := enc.Encode((&RPCStruct{}).AddField(NewTField(1, thrift.SET, "", false).SetValue(set)))
+		require.NoError(t, err)
+		return bb
+	}
+	require.Equal(t, encode(0, negZero), encode(negZero, 0))
+	require.Equal(t, encode(0, negZero), encode(negZero, 0, negZero))
+	require.NotEqual(t, encode(0), encode(negZero))
+}
+
+func TestEncoderCanonicalTaggedStructKeys(t *testing.T) {
+	type point struct {
+		X int32 `thrift:"x,1"`
+	}
+	type grid struct {
+		Cells map[point]int32 `thrift:"cells,1"`
+	}
+	v := grid{Cells: map[point]int32{}}
+	for i := int32(0); i < 16; i++ {
+		v.Cells[point{X: 15 - i}] = i
+	}
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).SetCanonical(true)
+	expected, err := enc.Encode(&v)
+	require.NoError(t, err)
+	for i := 0; i < 8; i++ {
+		actual, err := enc.Encode(&v)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestEncoderCanonicalDuplicates(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).SetCanonical(true)
+	encode := func(kvs ...float64) []byte {
+		m := NewTypeContainerMap[float64, int32](TypeContainerDesc{Key: thrift.DOUBLE, Value: thrift.I32}, false)
+		for i := 0; i < len(kvs); i += 2 {
+			m.AddKV(kvs[i], int32(kvs[i+1]))
+		}
+		bb, err := enc.Encode((&RPCStruct{}).AddField(NewTField(1, thrift.MAP, "", false).SetValue(m)))
+		require.NoError(t, err)
+		return bb
+	}
+	// keys ordered as FromMapOrdered does, repeated keys keep the last value.
+	require.Equal(t, encode(math.NaN(), 0, -1, 1, 0, 2, negZero, 3), encode(negZero, 3, 0, 2, -1, 1, math.NaN(), 0))
+	require.Equal(t, encode(1, 2), encode(1, 1, 1, 2))
+	require.Equal(t, encode(0, 1, 1, 2), encode(1, 0, 0, 1, 1, 2))
+
+	// repeated field IDs keep the last field.
+	field := func(id TFieldID, v string) *TField {
+		return NewTField(id, thrift.STRING, "", false).SetValue(v)
+	}
+	expected, err := enc.Encode((&RPCStruct{}).AddField(field(1, "a")).AddField(field(2, "c")))
+	require.NoError(t, err)
+	actual, err := enc.Encode((&RPCStruct{}).AddField(field(2, "b")).AddField(field(1, "a")).AddField(field(2, "c")))
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
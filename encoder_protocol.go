@@ -0,0 +1,29 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// encodeProtocol wraps protocol of Encoder to carry encoding options down to struct writers.
+type encodeProtocol struct {
+	thrift.TProtocol
+	omitDefaults bool
+	canonical    bool
+}
+
+func encodeProtocolOf(p thrift.TProtocol) *encodeProtocol {
+	ep, _ := p.(*encodeProtocol)
+	return ep
+}
+
+// isOmitDefaults report whether fields equal to their declared default are left out while writing to p.
+func isOmitDefaults(p thrift.TProtocol) bool {
+	ep, ok := p.(*encodeProtocol)
+	return ok && ep.omitDefaults
+}
+
+// isCanonical report whether struct fields, map entries and set elements are written in canonical order to p.
+func isCanonical(p thrift.TProtocol) bool {
+	ep, ok := p.(*encodeProtocol)
+	return ok && ep.canonical
+}
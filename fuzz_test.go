@@ -0,0 +1,219 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"errors"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fuzzLimits bound what a fuzzed payload can make the decoder allocate.
+var fuzzLimits = DecodeLimits{
+	MaxDepth:         32,
+	MaxTotalElements: 1 << 16,
+	MaxStringLength:  1 << 16,
+}
+
+// addFuzzSeeds add seeds encoded in every protocol, the protocol is chosen by the first argument.
+func addFuzzSeeds(f *testing.F, seeds ...any) {
+	for i, proto := range ProtocolType_VALUES {
+		enc := NewEncoder(ProtocolFactory(proto, defaultTestTConfiguration))
+		for _, seed := range seeds {
+			bb, err := enc.Encode(seed)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(uint8(i), bb)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addFuzzSeeds(f, &base.Request{}, &base.Request{Model: base.NewModel()}, testReuseRequest())
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		proto := ProtocolType_VALUES[int(i)%len(ProtocolType_VALUES)]
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		dec := NewDecoder(pf).SetLimits(fuzzLimits)
+		var st RPCStruct
+		if err := dec.Decode(data, &st); err != nil {
+			// best-effort mode reports any failure as diagnostics.
+			var diags Diagnostics
+			dec.SetBestEffort(true)
+			if err = dec.Decode(data, &st); !errors.As(err, &diags) || len(diags) < 1 {
+				t.Fatalf("%s: best-effort decode: %v", proto, err)
+			}
+			return
+		}
+		// anything that decodes must encode, and decode to the same encoding.
+		enc := NewEncoder(pf)
+		bb, err := enc.Encode(&st)
+		if err != nil {
+			t.Fatalf("%s: encode decoded value: %v", proto, err)
+		}
+		// reuse and zero-copy mode decode to the same value.
+		reuse := NewDecoder(pf).SetLimits(dec.Limits()).SetReuse(true).SetZeroCopy(true)
+		var st3 RPCStruct
+		for j := 0; j < 2; j++ {
+			if err = reuse.Decode(data, &st3); err != nil {
+				t.Fatalf("%s: reuse decode: %v", proto, err)
+			}
+			if bb3, err := enc.Encode(&st3); err != nil || string(bb) != string(bb3) {
+				t.Fatalf("%s: reuse decode mismatch: %v\n%q\n%q", proto, err, bb, bb3)
+			}
+		}
+		if proto == ProtocolType_SimpleJSON {
+			return // field ids are not part of the payload.
+		}
+		var st2 RPCStruct
+		if err = dec.Decode(bb, &st2); err != nil {
+			t.Fatalf("%s: decode re-encoded value: %v\n%q", proto, err, bb)
+		}
+		bb2, err := enc.Encode(&st2)
+		if err != nil {
+			t.Fatalf("%s: encode re-decoded value: %v", proto, err)
+		}
+		if string(bb) != string(bb2) {
+			t.Fatalf("%s: round trip mismatch\n%q\n%q", proto, bb, bb2)
+		}
+	})
+}
+
+func FuzzDecodePlan(f *testing.F) {
+	pl, err := CompileStruct(testRequestStructDesc())
+	if err != nil {
+		f.Fatal(err)
+	}
+	addFuzzSeeds(f, &base.Request{}, &base.Request{Model: base.NewModel()}, testReuseRequest())
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		proto := ProtocolType_VALUES[int(i)%len(ProtocolType_VALUES)]
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		dec := NewDecoder(pf).SetLimits(fuzzLimits)
+		var st RPCStruct
+		if err := dec.Decode(data, pl.Bind(&st)); err != nil {
+			var diags Diagnostics
+			dec.SetBestEffort(true)
+			if err = dec.Decode(data, pl.Bind(&st)); !errors.As(err, &diags) || len(diags) < 1 {
+				t.Fatalf("%s: best-effort decode: %v", proto, err)
+			}
+			return
+		}
+		enc := NewEncoder(pf)
+		bb, err := enc.Encode(pl.Bind(&st))
+		if err != nil {
+			t.Fatalf("%s: encode decoded value: %v", proto, err)
+		}
+		if proto == ProtocolType_SimpleJSON {
+			return // field ids are not part of the payload.
+		}
+		// a plan normalizes empty containers to the schema, its encoding is stable.
+		var st2 RPCStruct
+		if err = dec.Decode(bb, pl.Bind(&st2)); err != nil {
+			t.Fatalf("%s: decode re-encoded value: %v\n%q", proto, err, bb)
+		}
+		if bb2, err := enc.Encode(pl.Bind(&st2)); err != nil || string(bb) != string(bb2) {
+			t.Fatalf("%s: round trip mismatch: %v\n%q\n%q", proto, err, bb, bb2)
+		}
+	})
+}
+
+func FuzzDecodeTagged(f *testing.F) {
+	count := int64(3)
+	addFuzzSeeds(f, &taggedJob{}, &taggedJob{
+		Name:   "build",
+		Count:  &count,
+		Tags:   []string{"a", "b"},
+		Scores: map[string]int32{"x": 1},
+		Parent: &taggedJob{Name: "root"},
+		Data:   []byte{1, 2},
+		Status: TEnum{Value: 5},
+		Extra:  NewStruct("Extra").I64(1, "id", 7).MustBuild(),
+	})
+	f.Fuzz(func(t *testing.T, i uint8, data []byte) {
+		proto := ProtocolType_VALUES[int(i)%len(ProtocolType_VALUES)]
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		dec := NewDecoder(pf).SetLimits(fuzzLimits)
+		var job taggedJob
+		if err := dec.Decode(data, &job); err != nil {
+			return
+		}
+		// anything that decodes must encode, and decode to the same encoding.
+		enc := NewEncoder(pf)
+		bb, err := enc.Encode(&job)
+		if err != nil {
+			t.Fatalf("%s: encode decoded value: %v", proto, err)
+		}
+		if proto == ProtocolType_SimpleJSON {
+			return // field ids are not part of the payload.
+		}
+		var job2 taggedJob
+		if err = dec.Decode(bb, &job2); err != nil {
+			t.Fatalf("%s: decode re-encoded value: %v\n%q", proto, err, bb)
+		}
+		if bb2, err := enc.Encode(&job2); err != nil || string(bb) != string(bb2) {
+			t.Fatalf("%s: round trip mismatch: %v\n%q\n%q", proto, err, bb, bb2)
+		}
+	})
+}
+
+func TestDecodeEmptyMap(t *testing.T) {
+	var err error
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		enc := NewEncoder(pf)
+		bb, err := enc.Encode(&base.Request{Model: base.NewModel()})
+		require.NoError(t, err)
+
+		var st RPCStruct
+		err = NewDecoder(pf).Decode(bb, &st)
+		require.NoError(t, err)
+		actual, err := enc.Encode(&st)
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+	})
+	_ = err
+}
+
+func TestDecodeRenewProtocol(t *testing.T) {
+	// read-ahead input of JSON protocol does not leak into the next decode.
+	dec := NewDecoder(ProtocolFactory(ProtocolType_JSON, defaultTestTConfiguration))
+	var st RPCStruct
+	require.NoError(t, dec.Decode([]byte("{}0"), &st))
+	require.NoError(t, dec.Decode([]byte("{}"), &st))
+
+	// compact protocol state left by malformed input is discarded.
+	dec = NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration))
+	require.Error(t, dec.Decode([]byte{0x1c, 0x1c}, &st))
+	bb, err := NewEncoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).Encode(base.NewModel())
+	require.NoError(t, err)
+	require.NoError(t, dec.Decode(bb, &st))
+	require.Equal(t, TFieldID(1), st.Fields[0].ID)
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	_, err := NewProtocolFactory("unknown", defaultTestTConfiguration)
+	require.True(t, errors.Is(err, ErrUnsupportedProtocol))
+
+	pf := ProtocolFactory("unknown", defaultTestTConfiguration)
+	_, err = NewEncoder(pf).Encode(base.NewModel())
+	require.True(t, errors.Is(err, ErrUnsupportedProtocol), err)
+	var st RPCStruct
+	err = NewDecoder(pf).ReadFrom(bytes.NewReader([]byte{0}), &st)
+	require.True(t, errors.Is(err, ErrUnsupportedProtocol), err)
+}
+
+func TestNewTypeContainerOfUnhandled(t *testing.T) {
+	for _, d := range []*TypeDesc{
+		NewTypeDescList(NewTypeDesc(thrift.STOP)),
+		NewTypeDescSet(NewTypeDesc(thrift.TType(200))),
+		NewTypeDescList(nil),
+		NewTypeDescMap(NewTypeDescList(NewTypeDesc(thrift.I64)), NewTypeDesc(thrift.I64)),
+		NewTypeDesc(thrift.I64),
+	} {
+		_, err := NewTypeContainerOf(d, false)
+		require.Error(t, err, d)
+	}
+	typ, err := NewTypeContainerOf(NewTypeDescMap(NewTypeDesc(thrift.STRING), NewTypeDesc(thrift.I64)), false)
+	require.NoError(t, err)
+	require.IsType(t, &TypeContainerMap[string, int64]{}, typ)
+}
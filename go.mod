@@ -1,13 +1,16 @@
 module github.com/ii64/go-thrift-dyn
 
-go 1.18
+go 1.23
+
+require (
+	github.com/apache/thrift v0.16.0
+	github.com/davecgh/go-spew v1.1.0
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/exp v0.0.0-20220518171630-0b5c67f07fdf
+)
 
 require (
-	github.com/apache/thrift v0.16.0 // indirect
-	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.1.0 // indirect
-	github.com/stretchr/testify v1.7.1 // indirect
-	golang.org/x/exp v0.0.0-20220518171630-0b5c67f07fdf // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
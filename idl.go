@@ -0,0 +1,251 @@
+package thrift_dyn
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteStructIDL write struct definitions in thrift IDL.
+func WriteStructIDL(w io.Writer, descs ...*StructDesc) error {
+	defs := make([]Definition, len(descs))
+	for i, desc := range descs {
+		defs[i] = desc
+	}
+	return NewSchema(defs...).WriteIDL(w)
+}
+
+// WriteIDL write schema in thrift IDL. Comments and annotations are written
+// back as parsed, fields are written one per line without separators.
+func (s *Schema) WriteIDL(w io.Writer) error {
+	iw := &idlWriter{Writer: bufio.NewWriter(w)}
+	section := false
+	begin := func() {
+		if section {
+			iw.WriteString("\n")
+		}
+		section = true
+	}
+	if s.Comment != "" {
+		begin()
+		iw.comment(s.Comment, "")
+	}
+	if len(s.Includes) > 0 || len(s.CppIncludes) > 0 {
+		begin()
+		for _, path := range s.Includes {
+			iw.WriteString("include " + strconv.Quote(path) + "\n")
+		}
+		for _, path := range s.CppIncludes {
+			iw.WriteString("cpp_include " + strconv.Quote(path) + "\n")
+		}
+	}
+	if len(s.Namespaces) > 0 {
+		begin()
+		for _, ns := range s.Namespaces {
+			iw.WriteString("namespace " + ns.Scope + " " + ns.Name)
+			iw.annotations(ns.Annotations)
+			iw.WriteString("\n")
+		}
+	}
+	for _, def := range s.Definitions {
+		begin()
+		switch d := def.(type) {
+		case *TypedefDesc:
+			iw.comment(d.Comment, "")
+			iw.WriteString("typedef ")
+			iw.typ(d.Type)
+			iw.WriteString(" " + d.Name)
+			iw.annotations(d.Annotations)
+			iw.WriteString("\n")
+		case *ConstDesc:
+			iw.comment(d.Comment, "")
+			iw.WriteString("const ")
+			iw.typ(d.Type)
+			iw.WriteString(" " + d.Name + " = " + d.Value + "\n")
+		case *EnumDesc:
+			iw.enum(d)
+		case *StructDesc:
+			iw.structure(d)
+		case *ServiceDesc:
+			iw.service(d)
+		}
+	}
+	return iw.Flush()
+}
+
+type idlWriter struct {
+	*bufio.Writer
+}
+
+// comment write comment lines with indent, continuation lines
+// of block comment are aligned to its opening.
+func (w *idlWriter) comment(c, indent string) {
+	if c == "" {
+		return
+	}
+	for _, line := range strings.Split(c, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			line = " " + line
+		}
+		w.WriteString(indent + line + "\n")
+	}
+}
+
+func (w *idlWriter) trailing(c string) {
+	if c != "" {
+		w.WriteString(" " + c)
+	}
+	w.WriteString("\n")
+}
+
+func (w *idlWriter) annotations(anns []Annotation) {
+	if len(anns) == 0 {
+		return
+	}
+	w.WriteString(" (")
+	for i, ann := range anns {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(ann.Key)
+		if strings.Contains(ann.Value, `"`) {
+			w.WriteString(" = '" + ann.Value + "'")
+		} else {
+			w.WriteString(` = "` + ann.Value + `"`)
+		}
+	}
+	w.WriteString(")")
+}
+
+func (w *idlWriter) typ(d *TypeDesc) {
+	if d == nil || d.Name != "" || !d.IsContainer() {
+		w.WriteString(d.String())
+	} else if d.Key != nil {
+		w.WriteString("map<")
+		w.typ(d.Key)
+		w.WriteString(",")
+		w.typ(d.Value)
+		w.WriteString(">")
+	} else {
+		w.WriteString(textTypeName(d.Type) + "<")
+		w.typ(d.Value)
+		w.WriteString(">")
+	}
+	if d != nil {
+		w.annotations(d.Annotations)
+	}
+}
+
+func (w *idlWriter) enum(d *EnumDesc) {
+	w.comment(d.Comment, "")
+	w.WriteString("enum " + d.Name + " {")
+	if len(d.Values) > 0 {
+		w.WriteString("\n")
+	}
+	for _, v := range d.Values {
+		w.comment(v.Comment, "  ")
+		w.WriteString("  " + v.Name + " = " + strconv.Itoa(int(v.Value)))
+		w.annotations(v.Annotations)
+		w.trailing(v.Trailing)
+	}
+	w.WriteString("}")
+	w.annotations(d.Annotations)
+	w.WriteString("\n")
+}
+
+func (w *idlWriter) field(f *FieldDesc) {
+	w.WriteString(strconv.Itoa(int(f.ID)) + ": ")
+	switch {
+	case f.Required:
+		w.WriteString("required ")
+	case f.Optional:
+		w.WriteString("optional ")
+	}
+	w.typ(f.Type)
+	w.WriteString(" " + f.Name)
+	if f.Default != "" {
+		w.WriteString(" = " + f.Default)
+	}
+	w.annotations(f.Annotations)
+}
+
+func (w *idlWriter) structure(d *StructDesc) {
+	w.comment(d.Comment, "")
+	w.WriteString(d.Kind.String() + " " + d.Name + " {")
+	if len(d.Fields) > 0 {
+		w.WriteString("\n")
+	}
+	for _, f := range d.Fields {
+		w.comment(f.Comment, "  ")
+		w.WriteString("  ")
+		w.field(f)
+		w.trailing(f.Trailing)
+	}
+	w.WriteString("}")
+	w.annotations(d.Annotations)
+	w.WriteString("\n")
+}
+
+// fields write argument list, one per line when any of them is commented.
+func (w *idlWriter) fields(fs []*FieldDesc) {
+	multiline := false
+	for _, f := range fs {
+		multiline = multiline || f.Comment != "" || f.Trailing != ""
+	}
+	w.WriteString("(")
+	for i, f := range fs {
+		if multiline {
+			w.WriteString("\n")
+			w.comment(f.Comment, "    ")
+			w.WriteString("    ")
+		} else if i > 0 {
+			w.WriteString(", ")
+		}
+		w.field(f)
+		if multiline {
+			if i < len(fs)-1 {
+				w.WriteString(",")
+			}
+			if f.Trailing != "" {
+				w.WriteString(" " + f.Trailing)
+			}
+		}
+	}
+	if multiline {
+		w.WriteString("\n  ")
+	}
+	w.WriteString(")")
+}
+
+func (w *idlWriter) service(d *ServiceDesc) {
+	w.comment(d.Comment, "")
+	w.WriteString("service " + d.Name)
+	if d.Extends != "" {
+		w.WriteString(" extends " + d.Extends)
+	}
+	w.WriteString(" {")
+	if len(d.Functions) > 0 {
+		w.WriteString("\n")
+	}
+	for _, f := range d.Functions {
+		w.comment(f.Comment, "  ")
+		w.WriteString("  ")
+		if f.Oneway {
+			w.WriteString("oneway ")
+		}
+		w.typ(f.Result)
+		w.WriteString(" " + f.Name)
+		w.fields(f.Args)
+		if len(f.Throws) > 0 {
+			w.WriteString(" throws ")
+			w.fields(f.Throws)
+		}
+		w.annotations(f.Annotations)
+		w.trailing(f.Trailing)
+	}
+	w.WriteString("}")
+	w.annotations(d.Annotations)
+	w.WriteString("\n")
+}
@@ -0,0 +1,330 @@
+package thrift_dyn
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ConstEntry is entry of map or struct const value, key of struct entry is field name.
+type ConstEntry struct {
+	Key, Value any
+}
+
+// EvalConst evaluate IDL const literal of type t, e.g. `3`, `"a"` or `[1, 2]`.
+// The value is one of bool, int64, float64, string, []any for list and set,
+// and []ConstEntry for map and struct. Consts and enum values of schema may be
+// referred to by name, schema may be nil.
+func EvalConst(lit string, t *TypeDesc, schema *Schema) (any, error) {
+	toks, err := lexIDL(lit)
+	if err != nil {
+		return nil, err
+	}
+	e := &constEval{toks: toks, schema: schema}
+	v, err := e.eval(t)
+	if err == nil && e.toks[e.pos].kind != idlTokenEOF {
+		err = fmt.Errorf("unexpected %s after value", e.toks[e.pos])
+	}
+	return v, err
+}
+
+type constEval struct {
+	toks   []idlToken
+	pos    int
+	schema *Schema
+	depth  int
+}
+
+func (e *constEval) next() idlToken {
+	for e.toks[e.pos].kind == idlTokenComment {
+		e.pos++
+	}
+	tok := e.toks[e.pos]
+	if tok.kind != idlTokenEOF {
+		e.pos++
+	}
+	return tok
+}
+
+func (e *constEval) accept(s string) bool {
+	i := e.pos
+	for e.toks[i].kind == idlTokenComment {
+		i++
+	}
+	if tok := e.toks[i]; tok.kind == idlTokenPunct && tok.text == s {
+		e.pos = i + 1
+		return true
+	}
+	return false
+}
+
+func (e *constEval) eval(t *TypeDesc) (v any, err error) {
+	tok := e.next()
+	if tok.kind == idlTokenIdent {
+		return e.ident(tok.text, t)
+	}
+	switch t.Type {
+	case thrift.BOOL:
+		if tok.kind == idlTokenNumber && (tok.text == "0" || tok.text == "1") {
+			return tok.text == "1", nil
+		}
+	case thrift.BYTE, thrift.I16, thrift.I32, thrift.I64:
+		if tok.kind == idlTokenNumber {
+			return parseConstInt(tok.text, t)
+		}
+	case thrift.DOUBLE:
+		if tok.kind == idlTokenNumber {
+			return strconv.ParseFloat(tok.text, 64)
+		}
+	case thrift.STRING:
+		if tok.kind == idlTokenString {
+			return tok.text, nil
+		}
+	case thrift.LIST, thrift.SET:
+		if tok.kind == idlTokenPunct && tok.text == "[" {
+			var vs []any
+			for !e.accept("]") {
+				if v, err = e.eval(t.Value); err != nil {
+					return
+				}
+				vs = append(vs, v)
+				if !e.accept(",") {
+					e.accept(";")
+				}
+			}
+			return vs, nil
+		}
+	case thrift.MAP, thrift.STRUCT:
+		if tok.kind == idlTokenPunct && tok.text == "{" {
+			var entries []ConstEntry
+			for !e.accept("}") {
+				var entry ConstEntry
+				keyType, valueType := t.Key, t.Value
+				if t.Type == thrift.STRUCT {
+					keyType = NewTypeDesc(thrift.STRING)
+				}
+				if entry.Key, err = e.eval(keyType); err != nil {
+					return
+				}
+				if !e.accept(":") {
+					return nil, fmt.Errorf("expected ':' after key %v", entry.Key)
+				}
+				if t.Type == thrift.STRUCT {
+					if valueType, err = constFieldType(t, entry.Key.(string)); err != nil {
+						return
+					}
+				}
+				if entry.Value, err = e.eval(valueType); err != nil {
+					return
+				}
+				entries = append(entries, entry)
+				if !e.accept(",") {
+					e.accept(";")
+				}
+			}
+			return entries, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %s", t, tok)
+}
+
+func constFieldType(t *TypeDesc, name string) (*TypeDesc, error) {
+	if t.Struct == nil {
+		return nil, fmt.Errorf("unknown fields of %s", t)
+	}
+	f := t.Struct.FieldByName(name)
+	if f == nil {
+		return nil, fmt.Errorf("%s has no field '%s'", t, name)
+	}
+	return f.Type, nil
+}
+
+func parseConstInt(s string, t *TypeDesc) (v int64, err error) {
+	bits := map[thrift.TType]int{thrift.BYTE: 8, thrift.I16: 16, thrift.I32: 32, thrift.I64: 64}[t.Type]
+	if v, err = strconv.ParseInt(s, 0, bits); err != nil {
+		return 0, fmt.Errorf("invalid %s value %s", t, s)
+	}
+	return
+}
+
+// ident evaluate identifier, true and false, value of enum t or const of schema.
+func (e *constEval) ident(name string, t *TypeDesc) (any, error) {
+	switch {
+	case t.Type == thrift.BOOL && (name == "true" || name == "false"):
+		return name == "true", nil
+	case t.Enum != nil:
+		v := t.Enum.ValueByName(strings.TrimPrefix(name, t.Enum.Name+"."))
+		if v != nil {
+			return int64(v.Value), nil
+		}
+	}
+	if e.schema == nil {
+		return nil, fmt.Errorf("undefined %s", name)
+	}
+	if i := strings.LastIndexByte(name, '.'); i > 0 { // enum value of type other than t
+		if d := e.schema.Enum(name[:i]); d != nil {
+			if v := d.ValueByName(name[i+1:]); v != nil {
+				return int64(v.Value), nil
+			}
+		}
+	}
+	c, ok := e.schema.Lookup(name).(*ConstDesc)
+	if !ok {
+		return nil, fmt.Errorf("undefined %s", name)
+	}
+	if e.depth > 64 {
+		return nil, fmt.Errorf("const %s: circular definition", name)
+	}
+	toks, err := lexIDL(c.Value)
+	if err != nil {
+		return nil, err
+	}
+	sub := &constEval{toks: toks, schema: e.schema, depth: e.depth + 1}
+	return sub.eval(t)
+}
+
+// writeConst write const value of type t, see EvalConst.
+// Integers, strings and enum values given as Go values are accepted too.
+func writeConst(ctx context.Context, p thrift.TProtocol, v any, t *TypeDesc) (err error) {
+	switch t.Type {
+	case thrift.BOOL:
+		if v, ok := v.(bool); ok {
+			return p.WriteBool(ctx, v)
+		}
+	case thrift.BYTE, thrift.I16, thrift.I32, thrift.I64:
+		n, ok := constInt(v, t)
+		if !ok {
+			break
+		}
+		switch t.Type {
+		case thrift.BYTE:
+			ok = n >= math.MinInt8 && n <= math.MaxInt8
+		case thrift.I16:
+			ok = n >= math.MinInt16 && n <= math.MaxInt16
+		case thrift.I32:
+			ok = n >= math.MinInt32 && n <= math.MaxInt32
+		}
+		if !ok {
+			return fmt.Errorf("%d overflows %s", n, t)
+		}
+		switch t.Type {
+		case thrift.BYTE:
+			return p.WriteByte(ctx, int8(n))
+		case thrift.I16:
+			return p.WriteI16(ctx, int16(n))
+		case thrift.I32:
+			return p.WriteI32(ctx, int32(n))
+		}
+		return p.WriteI64(ctx, n)
+	case thrift.DOUBLE:
+		switch v := v.(type) {
+		case float64:
+			return p.WriteDouble(ctx, v)
+		case int64:
+			return p.WriteDouble(ctx, float64(v))
+		}
+	case thrift.STRING:
+		switch v := v.(type) {
+		case string:
+			return p.WriteString(ctx, v)
+		case []byte:
+			return p.WriteBinary(ctx, v)
+		}
+	case thrift.LIST, thrift.SET:
+		vs, ok := v.([]any)
+		if !ok {
+			break
+		}
+		if t.Type == thrift.SET {
+			err = p.WriteSetBegin(ctx, t.Value.Type, len(vs))
+		} else {
+			err = p.WriteListBegin(ctx, t.Value.Type, len(vs))
+		}
+		if err != nil {
+			return
+		}
+		for _, v := range vs {
+			if err = writeConst(ctx, p, v, t.Value); err != nil {
+				return
+			}
+		}
+		if t.Type == thrift.SET {
+			return p.WriteSetEnd(ctx)
+		}
+		return p.WriteListEnd(ctx)
+	case thrift.MAP:
+		entries, ok := v.([]ConstEntry)
+		if !ok {
+			break
+		}
+		if err = p.WriteMapBegin(ctx, t.Key.Type, t.Value.Type, len(entries)); err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if err = writeConst(ctx, p, entry.Key, t.Key); err != nil {
+				return
+			}
+			if err = writeConst(ctx, p, entry.Value, t.Value); err != nil {
+				return
+			}
+		}
+		return p.WriteMapEnd(ctx)
+	case thrift.STRUCT:
+		entries, ok := v.([]ConstEntry)
+		if !ok || t.Struct == nil {
+			break
+		}
+		if err = p.WriteStructBegin(ctx, t.Struct.Name); err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name, _ := entry.Key.(string)
+			f := t.Struct.FieldByName(name)
+			if f == nil {
+				return fmt.Errorf("%s has no field '%v'", t, entry.Key)
+			}
+			if err = p.WriteFieldBegin(ctx, f.Name, f.Type.Type, f.ID); err != nil {
+				return
+			}
+			if err = writeConst(ctx, p, entry.Value, f.Type); err != nil {
+				return
+			}
+			if err = p.WriteFieldEnd(ctx); err != nil {
+				return
+			}
+		}
+		if err = p.WriteFieldStop(ctx); err != nil {
+			return
+		}
+		return p.WriteStructEnd(ctx)
+	}
+	return fmt.Errorf("invalid %s value %v (%T)", t, v, v)
+}
+
+// constInt get integer of const value, enum value may be given by name.
+func constInt(v any, t *TypeDesc) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case TEnum:
+		return int64(v.Value), true
+	case string:
+		if t.Enum != nil {
+			if ev := t.Enum.ValueByName(v); ev != nil {
+				return int64(ev.Value), true
+			}
+		}
+	}
+	return 0, false
+}
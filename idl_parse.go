@@ -0,0 +1,690 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"strconv"
+	"strings"
+)
+
+var idlBaseTypes = map[string]thrift.TType{
+	"bool":   thrift.BOOL,
+	"byte":   thrift.BYTE,
+	"i8":     thrift.BYTE,
+	"i16":    thrift.I16,
+	"i32":    thrift.I32,
+	"i64":    thrift.I64,
+	"double": thrift.DOUBLE,
+	"string": thrift.STRING,
+	"binary": thrift.STRING,
+}
+
+type idlTokenKind uint8
+
+const (
+	idlTokenEOF idlTokenKind = iota
+	idlTokenIdent
+	idlTokenNumber
+	idlTokenString
+	idlTokenPunct
+	idlTokenComment
+)
+
+type idlToken struct {
+	kind  idlTokenKind
+	text  string // string literal without quotes
+	line  int
+	col   int
+	start int // offset in source
+	end   int
+
+	newline bool // preceded by line break
+	blank   bool // preceded by blank line
+}
+
+func (t idlToken) String() string {
+	switch t.kind {
+	case idlTokenEOF:
+		return "end of file"
+	case idlTokenString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// lexIDL split src into tokens, comments are kept as tokens.
+func lexIDL(src string) (toks []idlToken, err error) {
+	line, col, lines := 1, 1, 0
+	advance := func(n int) {
+		for _, c := range src[:n] {
+			if c == '\n' {
+				line, col = line+1, 1
+				lines++
+			} else {
+				col++
+			}
+		}
+	}
+	off := 0
+	for {
+		// whitespace
+		n := 0
+		for n < len(src) && strings.IndexByte(" \t\r\n", src[n]) >= 0 {
+			n++
+		}
+		advance(n)
+		src, off = src[n:], off+n
+		if len(src) == 0 {
+			toks = append(toks, idlToken{kind: idlTokenEOF, line: line, col: col, start: off, end: off, newline: true})
+			return
+		}
+		tok := idlToken{line: line, col: col, start: off, newline: lines > 0 || len(toks) == 0, blank: lines > 1}
+		c := src[0]
+		switch {
+		case strings.HasPrefix(src, "//") || c == '#':
+			n = strings.IndexByte(src, '\n')
+			if n < 0 {
+				n = len(src)
+			}
+			tok.kind, tok.text = idlTokenComment, strings.TrimRight(src[:n], " \t\r")
+		case strings.HasPrefix(src, "/*"):
+			n = strings.Index(src[2:], "*/")
+			if n < 0 {
+				return nil, fmt.Errorf("%d:%d: unterminated comment", line, col)
+			}
+			n += 4
+			tok.kind, tok.text = idlTokenComment, src[:n]
+		case c == '"' || c == '\'':
+			n = strings.IndexByte(src[1:], c)
+			if n < 0 {
+				return nil, fmt.Errorf("%d:%d: unterminated string", line, col)
+			}
+			n += 2
+			tok.kind, tok.text = idlTokenString, src[1:n-1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			n = 1
+			for n < len(src) && (src[n] == '_' || src[n] == '.' || src[n] >= 'a' && src[n] <= 'z' ||
+				src[n] >= 'A' && src[n] <= 'Z' || src[n] >= '0' && src[n] <= '9') {
+				n++
+			}
+			tok.kind, tok.text = idlTokenIdent, src[:n]
+		case c >= '0' && c <= '9' || (c == '-' || c == '+') && len(src) > 1 && src[1] >= '0' && src[1] <= '9':
+			n = 1
+			for n < len(src) && (strings.IndexByte("0123456789abcdefABCDEFxX.", src[n]) >= 0 ||
+				(src[n] == '-' || src[n] == '+') && (src[n-1] == 'e' || src[n-1] == 'E')) {
+				n++
+			}
+			tok.kind, tok.text = idlTokenNumber, src[:n]
+		case strings.IndexByte("{}()<>[],;:=*", c) >= 0:
+			n = 1
+			tok.kind, tok.text = idlTokenPunct, src[:1]
+		default:
+			return nil, fmt.Errorf("%d:%d: unexpected character %q", line, col, c)
+		}
+		tok.end = off + n
+		toks = append(toks, tok)
+		lines = 0
+		advance(n)
+		src, off = src[n:], off+n
+	}
+}
+
+type idlParser struct {
+	src  string
+	toks []idlToken
+	pos  int
+	prev idlToken // last token consumed, comments excluded
+
+	schema *Schema
+	refs   []*TypeDesc // types referring to definitions by name
+}
+
+// ParseIDL parse thrift IDL into Schema. Types referring to definitions
+// of included files are kept by name as struct types.
+func ParseIDL(src string) (*Schema, error) {
+	toks, err := lexIDL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &idlParser{src: src, toks: toks, schema: &Schema{}}
+	if err = p.parse(); err != nil {
+		return nil, err
+	}
+	if err = p.resolve(); err != nil {
+		return nil, err
+	}
+	return p.schema, nil
+}
+
+// MustParseIDL parse thrift IDL into Schema, panic on error.
+func MustParseIDL(src string) *Schema {
+	s, err := ParseIDL(src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (p *idlParser) errorf(tok idlToken, format string, args ...any) error {
+	return fmt.Errorf("%d:%d: %s", tok.line, tok.col, fmt.Sprintf(format, args...))
+}
+
+// peek get next token, comments are skipped.
+func (p *idlParser) peek() idlToken {
+	i := p.pos
+	for p.toks[i].kind == idlTokenComment {
+		i++
+	}
+	return p.toks[i]
+}
+
+func (p *idlParser) next() idlToken {
+	for p.toks[p.pos].kind == idlTokenComment {
+		p.pos++
+	}
+	tok := p.toks[p.pos]
+	if tok.kind != idlTokenEOF {
+		p.pos++
+	}
+	p.prev = tok
+	return tok
+}
+
+// accept consume next token if it is punctuation or keyword s.
+func (p *idlParser) accept(s string) bool {
+	if tok := p.peek(); (tok.kind == idlTokenPunct || tok.kind == idlTokenIdent) && tok.text == s {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *idlParser) expect(s string) error {
+	if tok := p.peek(); !p.accept(s) {
+		return p.errorf(tok, "expected '%s', found %s", s, tok)
+	}
+	return nil
+}
+
+func (p *idlParser) ident() (string, error) {
+	tok := p.next()
+	if tok.kind != idlTokenIdent {
+		return "", p.errorf(tok, "expected identifier, found %s", tok)
+	}
+	return tok.text, nil
+}
+
+func (p *idlParser) literal() (string, error) {
+	tok := p.next()
+	if tok.kind != idlTokenString {
+		return "", p.errorf(tok, "expected string literal, found %s", tok)
+	}
+	return tok.text, nil
+}
+
+// leading get comments preceding next token.
+func (p *idlParser) leading() string {
+	var cs []string
+	for ; p.toks[p.pos].kind == idlTokenComment; p.pos++ {
+		cs = append(cs, p.toks[p.pos].text)
+	}
+	return strings.Join(cs, "\n")
+}
+
+// trailing get comment following the last token on the same line.
+func (p *idlParser) trailing() string {
+	if tok := p.toks[p.pos]; tok.kind == idlTokenComment && !tok.newline {
+		p.pos++
+		return tok.text
+	}
+	return ""
+}
+
+// separator skip optional list separator, trailing comment is returned.
+func (p *idlParser) separator() string {
+	if tok := p.toks[p.pos]; tok.kind == idlTokenPunct && (tok.text == "," || tok.text == ";") {
+		p.next()
+	}
+	return p.trailing()
+}
+
+func (p *idlParser) parse() (err error) {
+	// comment heading the file is separated by a blank line.
+	if n := p.headerComments(); n > 0 {
+		var cs []string
+		for _, tok := range p.toks[:n] {
+			cs = append(cs, tok.text)
+		}
+		p.schema.Comment = strings.Join(cs, "\n")
+		p.pos = n
+	}
+	for {
+		comment := p.leading()
+		tok := p.next()
+		if tok.kind == idlTokenEOF {
+			return nil
+		}
+		if tok.kind != idlTokenIdent {
+			return p.errorf(tok, "expected definition, found %s", tok)
+		}
+		var def Definition
+		switch tok.text {
+		case "include", "cpp_include":
+			var path string
+			if path, err = p.literal(); err != nil {
+				return
+			}
+			if tok.text == "include" {
+				p.schema.Includes = append(p.schema.Includes, path)
+			} else {
+				p.schema.CppIncludes = append(p.schema.CppIncludes, path)
+			}
+		case "namespace":
+			ns := &NamespaceDesc{}
+			if ns.Scope, err = p.namespaceScope(); err != nil {
+				return
+			}
+			if ns.Name, err = p.ident(); err != nil {
+				return
+			}
+			if ns.Annotations, err = p.annotations(); err != nil {
+				return
+			}
+			p.schema.Namespaces = append(p.schema.Namespaces, ns)
+		case "typedef":
+			def, err = p.typedef()
+		case "const":
+			def, err = p.constant()
+		case "enum":
+			def, err = p.enum()
+		case "struct", "union", "exception":
+			def, err = p.structure(tok.text)
+		case "service":
+			def, err = p.service()
+		default:
+			return p.errorf(tok, "unexpected %s", tok)
+		}
+		if err != nil {
+			return
+		}
+		p.separator()
+		if def != nil {
+			setDefinitionComment(def, comment)
+			p.schema.Definitions = append(p.schema.Definitions, def)
+		}
+	}
+}
+
+// headerComments get number of comment tokens heading the file followed by a blank line.
+func (p *idlParser) headerComments() int {
+	n := 0
+	for p.toks[n].kind == idlTokenComment {
+		n++
+	}
+	if n > 0 && p.toks[n].blank {
+		return n
+	}
+	return 0
+}
+
+func setDefinitionComment(def Definition, comment string) {
+	switch d := def.(type) {
+	case *TypedefDesc:
+		d.Comment = comment
+	case *ConstDesc:
+		d.Comment = comment
+	case *EnumDesc:
+		d.Comment = comment
+	case *StructDesc:
+		d.Comment = comment
+	case *ServiceDesc:
+		d.Comment = comment
+	}
+}
+
+func (p *idlParser) namespaceScope() (string, error) {
+	if p.accept("*") {
+		return "*", nil
+	}
+	return p.ident()
+}
+
+func (p *idlParser) annotations() (anns []Annotation, err error) {
+	if !p.accept("(") {
+		return
+	}
+	for !p.accept(")") {
+		var ann Annotation
+		if ann.Key, err = p.ident(); err != nil {
+			return
+		}
+		if p.accept("=") {
+			if ann.Value, err = p.literal(); err != nil {
+				return
+			}
+		}
+		anns = append(anns, ann)
+		if !p.accept(",") {
+			p.accept(";")
+		}
+	}
+	return
+}
+
+func (p *idlParser) typ() (d *TypeDesc, err error) {
+	tok := p.next()
+	if tok.kind != idlTokenIdent {
+		return nil, p.errorf(tok, "expected type, found %s", tok)
+	}
+	switch tok.text {
+	case "list", "set":
+		d = &TypeDesc{Type: thrift.LIST}
+		if tok.text == "set" {
+			d.Type = thrift.SET
+		}
+		if err = p.expect("<"); err != nil {
+			return
+		}
+		if d.Value, err = p.typ(); err != nil {
+			return
+		}
+		if err = p.expect(">"); err != nil {
+			return
+		}
+	case "map":
+		d = &TypeDesc{Type: thrift.MAP}
+		if err = p.expect("<"); err != nil {
+			return
+		}
+		if d.Key, err = p.typ(); err != nil {
+			return
+		}
+		if err = p.expect(","); err != nil {
+			return
+		}
+		if d.Value, err = p.typ(); err != nil {
+			return
+		}
+		if err = p.expect(">"); err != nil {
+			return
+		}
+	default:
+		if t, ok := idlBaseTypes[tok.text]; ok {
+			d = &TypeDesc{Type: t}
+			if tok.text != textTypeName(t) {
+				d.Name = tok.text
+			}
+		} else {
+			d = &TypeDesc{Type: thrift.STRUCT, Name: tok.text}
+			p.refs = append(p.refs, d)
+		}
+	}
+	d.Annotations, err = p.annotations()
+	return
+}
+
+func (p *idlParser) typedef() (d *TypedefDesc, err error) {
+	d = &TypedefDesc{}
+	if d.Type, err = p.typ(); err != nil {
+		return
+	}
+	if d.Name, err = p.ident(); err != nil {
+		return
+	}
+	d.Annotations, err = p.annotations()
+	return
+}
+
+func (p *idlParser) constant() (d *ConstDesc, err error) {
+	d = &ConstDesc{}
+	if d.Type, err = p.typ(); err != nil {
+		return
+	}
+	if d.Name, err = p.ident(); err != nil {
+		return
+	}
+	if err = p.expect("="); err != nil {
+		return
+	}
+	d.Value, err = p.value()
+	return
+}
+
+// value get literal of const value as written in IDL.
+func (p *idlParser) value() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case idlTokenNumber, idlTokenIdent, idlTokenString:
+		return p.src[tok.start:tok.end], nil
+	case idlTokenPunct:
+		var closing string
+		switch tok.text {
+		case "[":
+			closing = "]"
+		case "{":
+			closing = "}"
+		default:
+			return "", p.errorf(tok, "expected value, found %s", tok)
+		}
+		for !p.accept(closing) {
+			if _, err := p.value(); err != nil {
+				return "", err
+			}
+			if closing == "}" {
+				if err := p.expect(":"); err != nil {
+					return "", err
+				}
+				if _, err := p.value(); err != nil {
+					return "", err
+				}
+			}
+			if !p.accept(",") {
+				p.accept(";")
+			}
+		}
+		return p.src[tok.start:p.prev.end], nil
+	}
+	return "", p.errorf(tok, "expected value, found %s", tok)
+}
+
+func (p *idlParser) enum() (d *EnumDesc, err error) {
+	d = &EnumDesc{}
+	if d.Name, err = p.ident(); err != nil {
+		return
+	}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	next := int32(0)
+	for {
+		comment := p.leading()
+		if p.accept("}") {
+			break
+		}
+		v := &EnumValueDesc{Comment: comment}
+		if v.Name, err = p.ident(); err != nil {
+			return
+		}
+		v.Value = next
+		if p.accept("=") {
+			tok := p.next()
+			n, perr := strconv.ParseInt(tok.text, 0, 32)
+			if tok.kind != idlTokenNumber || perr != nil {
+				return nil, p.errorf(tok, "invalid enum value %s", tok)
+			}
+			v.Value = int32(n)
+		}
+		next = v.Value + 1
+		if v.Annotations, err = p.annotations(); err != nil {
+			return
+		}
+		v.Trailing = p.separator()
+		d.Values = append(d.Values, v)
+	}
+	d.Annotations, err = p.annotations()
+	return
+}
+
+func (p *idlParser) structure(kind string) (d *StructDesc, err error) {
+	d = &StructDesc{}
+	switch kind {
+	case "union":
+		d.Kind = StructKindUnion
+	case "exception":
+		d.Kind = StructKindException
+	}
+	if d.Name, err = p.ident(); err != nil {
+		return
+	}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	if d.Fields, err = p.fields("}"); err != nil {
+		return
+	}
+	d.Annotations, err = p.annotations()
+	return
+}
+
+// fields parse field list up to closing punctuation.
+func (p *idlParser) fields(closing string) (fs []*FieldDesc, err error) {
+	for {
+		comment := p.leading()
+		if p.accept(closing) {
+			return
+		}
+		f := &FieldDesc{Comment: comment}
+		tok := p.next()
+		id, perr := strconv.ParseInt(tok.text, 0, 16)
+		if tok.kind != idlTokenNumber || perr != nil {
+			return nil, p.errorf(tok, "expected field id, found %s", tok)
+		}
+		f.ID = TFieldID(id)
+		if err = p.expect(":"); err != nil {
+			return
+		}
+		if p.accept("required") {
+			f.Required = true
+		} else if p.accept("optional") {
+			f.Optional = true
+		}
+		if f.Type, err = p.typ(); err != nil {
+			return
+		}
+		if f.Name, err = p.ident(); err != nil {
+			return
+		}
+		if p.accept("=") {
+			if f.Default, err = p.value(); err != nil {
+				return
+			}
+		}
+		if f.Annotations, err = p.annotations(); err != nil {
+			return
+		}
+		f.Trailing = p.separator()
+		fs = append(fs, f)
+	}
+}
+
+func (p *idlParser) service() (d *ServiceDesc, err error) {
+	d = &ServiceDesc{}
+	if d.Name, err = p.ident(); err != nil {
+		return
+	}
+	if p.accept("extends") {
+		if d.Extends, err = p.ident(); err != nil {
+			return
+		}
+	}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	for {
+		comment := p.leading()
+		if p.accept("}") {
+			break
+		}
+		f := &FunctionDesc{Comment: comment}
+		f.Oneway = p.accept("oneway")
+		if !p.accept("void") {
+			if f.Result, err = p.typ(); err != nil {
+				return
+			}
+		}
+		if f.Name, err = p.ident(); err != nil {
+			return
+		}
+		if err = p.expect("("); err != nil {
+			return
+		}
+		if f.Args, err = p.fields(")"); err != nil {
+			return
+		}
+		if p.accept("throws") {
+			if err = p.expect("("); err != nil {
+				return
+			}
+			if f.Throws, err = p.fields(")"); err != nil {
+				return
+			}
+		}
+		if f.Annotations, err = p.annotations(); err != nil {
+			return
+		}
+		f.Trailing = p.separator()
+		d.Functions = append(d.Functions, f)
+	}
+	d.Annotations, err = p.annotations()
+	return
+}
+
+// resolve resolve types referring to definitions by name.
+func (p *idlParser) resolve() error {
+	for _, ref := range p.refs {
+		if err := p.resolveType(ref, 0); err != nil {
+			return err
+		}
+	}
+	return p.resolveDefaults()
+}
+
+// resolveDefaults evaluate default values of fields, consts are resolved by the schema.
+func (p *idlParser) resolveDefaults() (err error) {
+	for _, d := range p.schema.Structs() {
+		for _, f := range d.Fields {
+			if f.Default == "" {
+				continue
+			}
+			if f.DefaultValue, err = EvalConst(f.Default, f.Type, p.schema); err != nil {
+				return fmt.Errorf("%s: field %d '%s' default: %w", d.Name, f.ID, f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *idlParser) resolveType(d *TypeDesc, depth int) error {
+	if d.Type != thrift.STRUCT || d.Struct != nil || d.Name == "" {
+		return nil
+	}
+	if depth > 64 {
+		return fmt.Errorf("typedef %s: circular definition", d.Name)
+	}
+	switch def := p.schema.Lookup(d.Name).(type) {
+	case *StructDesc:
+		d.Struct, d.Name = def, ""
+	case *EnumDesc:
+		d.Type, d.Enum = thrift.I32, def
+	case *TypedefDesc:
+		if err := p.resolveType(def.Type, depth+1); err != nil {
+			return err
+		}
+		d.Type, d.Key, d.Value, d.Struct, d.Enum = def.Type.Type, def.Type.Key, def.Type.Value, def.Type.Struct, def.Type.Enum
+	case nil:
+		// defined by included file.
+	default:
+		return fmt.Errorf("%s is not a type", d.Name)
+	}
+	return nil
+}
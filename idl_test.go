@@ -0,0 +1,151 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+const testIDL = `/*
+ * Example service.
+ */
+
+include "shared.thrift"
+cpp_include "<vector>"
+
+namespace go example
+namespace * example.all (deprecated = "true")
+
+typedef i64 Timestamp
+
+typedef list<binary> Blobs (go.type = "[][]byte")
+
+// maximum batch size
+const i32 MaxBatch = 100
+
+const map<string,i32> Weights = {"a": 1, "b": 2}
+
+/** Status of a job. */
+enum Status {
+  PENDING = 0
+  // running jobs
+  RUNNING = 1
+  FAILED = -1 // unrecoverable
+} (go.name = "JobStatus")
+
+struct Job {
+  1: required i64 id (go.tag = 'json:"id"')
+  2: optional string name = "unnamed"
+  3: Status status = Status.PENDING
+  4: Timestamp created
+  5: map<string,list<i8>> tags
+  // payloads of the job
+  6: Blobs blobs // may be empty
+  7: shared.Owner owner
+}
+
+struct Empty {}
+
+union Value {
+  1: i64 num
+  2: string str (min_len = "1")
+}
+
+exception NotFound {
+  1: string message
+}
+
+service Jobs extends shared.Base {
+  Job get(1: i64 id) throws (1: NotFound notFound)
+  // fire and forget
+  oneway void ping()
+  list<Job> batch(
+    1: list<i64> ids, // at most MaxBatch
+    2: bool strict
+  ) (timeout = "10")
+}
+`
+
+func TestIDLRoundTrip(t *testing.T) {
+	s, err := ParseIDL(testIDL)
+	require.NoError(t, err)
+	var sb bytes.Buffer
+	require.NoError(t, s.WriteIDL(&sb))
+	require.Equal(t, testIDL, sb.String())
+
+	require.Equal(t, []string{"shared.thrift"}, s.Includes)
+	require.Equal(t, []Annotation{{"deprecated", "true"}}, s.Namespaces[1].Annotations)
+	require.Equal(t, `{"a": 1, "b": 2}`, s.Lookup("Weights").(*ConstDesc).Value)
+	require.Equal(t, "// maximum batch size", s.Lookup("MaxBatch").(*ConstDesc).Comment)
+
+	status := s.Enum("Status")
+	require.Equal(t, int32(-1), status.ValueByName("FAILED").Value)
+	require.Equal(t, "// unrecoverable", status.ValueByName("FAILED").Trailing)
+	require.Equal(t, "RUNNING", status.ValueByNumber(1).Name)
+
+	job := s.Struct("Job")
+	require.Equal(t, "/** Status of a job. */", status.Comment)
+	require.True(t, job.FieldByID(1).Required)
+	require.Equal(t, `json:"id"`, job.FieldByID(1).Annotations[0].Value)
+	require.Equal(t, `"unnamed"`, job.FieldByID(2).Default)
+	require.Equal(t, thrift.TType(thrift.I32), job.FieldByID(3).Type.Type)
+	require.Equal(t, "Status", job.FieldByID(3).Type.Name)
+	require.Equal(t, thrift.TType(thrift.I64), job.FieldByID(4).Type.Type) // typedef resolved
+	require.Equal(t, thrift.TType(thrift.LIST), job.FieldByID(6).Type.Type)
+	require.Equal(t, "// payloads of the job", job.FieldByID(6).Comment)
+	require.Equal(t, "// may be empty", job.FieldByID(6).Trailing)
+	require.Equal(t, "shared.Owner", job.FieldByID(7).Type.Name) // defined by include
+	require.Equal(t, StructKindUnion, s.Struct("Value").Kind)
+	require.Equal(t, StructKindException, s.Struct("NotFound").Kind)
+
+	jobs := s.Service("Jobs")
+	require.Equal(t, "shared.Base", jobs.Extends)
+	require.Equal(t, job, jobs.Function("get").Result.Struct)
+	require.Equal(t, "notFound", jobs.Function("get").Throws[0].Name)
+	require.True(t, jobs.Function("ping").Oneway)
+	require.Nil(t, jobs.Function("ping").Result)
+	require.Len(t, jobs.Function("batch").Args, 2)
+}
+
+func TestIDLParseModel(t *testing.T) {
+	bb, err := os.ReadFile("internal/test/model.thrift")
+	require.NoError(t, err)
+	s, err := ParseIDL(string(bb))
+	require.NoError(t, err)
+	require.Equal(t, "base", s.Namespaces[0].Name)
+	require.Len(t, s.Structs(), 8)
+
+	req := s.Struct("Request")
+	require.Equal(t, "list<Model>", req.FieldByID(44).Type.String())
+	require.Equal(t, s.Struct("Model"), req.FieldByID(88).Type.Value.Value.Struct)
+	require.True(t, req.FieldByID(7).Optional)
+	require.Equal(t, "binary", s.Struct("Common").FieldByID(1).Type.String())
+	require.NoError(t, req.Validate())
+
+	// generated IDL parses back to the same schema.
+	var sb, sb2 bytes.Buffer
+	require.NoError(t, s.WriteIDL(&sb))
+	s2, err := ParseIDL(sb.String())
+	require.NoError(t, err)
+	require.NoError(t, s2.WriteIDL(&sb2))
+	require.Equal(t, sb.String(), sb2.String())
+	require.Contains(t, sb.String(), "struct EmptyField {}\n")
+	require.Contains(t, sb.String(), "  oneway void pushAnalytics(3: Request request)\n")
+}
+
+func TestIDLParseError(t *testing.T) {
+	for src, msg := range map[string]string{
+		"struct A {\n  1 string a\n}":          "2:5: expected ':', found 'string'",
+		"struct A {\n  x: string a\n}":         "2:3: expected field id, found 'x'",
+		"enum E { A = B }":                     "1:14: invalid enum value 'B'",
+		"typedef T T":                          "typedef T: circular definition",
+		"const i32 A = 1\nstruct B { 1: A a }": "A is not a type",
+		"struct A {":                           "1:11: expected field id, found end of file",
+		"/* open":                              "1:1: unterminated comment",
+	} {
+		_, err := ParseIDL(src)
+		require.EqualError(t, err, msg, src)
+	}
+}
@@ -203,6 +203,139 @@ func BenchmarkModelRebuildDyn(bn *testing.B) {
 
 }
 
+func modelStructPlan() *th.StructPlan {
+	pl, err := th.CompileStruct(th.NewStructDesc("Model",
+		th.NewFieldDesc(1, th.NewTypeDesc(thrift.STRING), "abc", true),
+		th.NewFieldDesc(4, th.NewTypeDesc(thrift.I64), "sd", true),
+		th.NewFieldDesc(9, th.NewTypeDesc(thrift.DOUBLE), "f64", true),
+		th.NewFieldDesc(10, th.NewTypeDescList(th.NewTypeDesc(thrift.I64)), "listI64", false),
+		th.NewFieldDesc(11, th.NewTypeDescMap(th.NewTypeDesc(thrift.I64), th.NewTypeDesc(thrift.I64)), "mapI64", false),
+		th.NewFieldDesc(12, th.NewTypeDescMap(th.NewTypeDesc(thrift.I32), th.NewTypeDesc(thrift.I32)), "mapI32", false),
+	))
+	if err != nil {
+		panic(err)
+	}
+	return pl
+}
+
+func BenchmarkModelRebuildPlan(bn *testing.B) {
+	ctx := context.Background()
+	var err error
+	var b bytes.Buffer
+	b.Grow(2 << 11)
+	trans := thrift.NewStreamTransportRW(&b)
+	protofactory := th.ProtocolFactory(th.ProtocolType_Compact, &thrift.TConfiguration{})
+	prot := protofactory.GetProtocol(trans)
+
+	listData := []int64{1, 2, 3}
+	mapI64 := map[int64]int64{1: 2}
+	mapI32 := map[int32]int32{3: 4}
+
+	var m th.RPCStruct
+	f1 := th.NewTField(1, thrift.STRING, "abc", true)
+	f2 := th.NewTField(4, thrift.I64, "sd", true)
+	f3 := th.NewTField(9, thrift.DOUBLE, "f64", true)
+	f4 := th.NewTField(10, thrift.LIST, "listI64", true)
+	f4Value := th.NewTypeContainerList[int64](th.TypeContainerDesc{Value: thrift.I64}, true)
+	f4.SetValue(f4Value)
+	f5 := th.NewTField(11, thrift.MAP, "mapI64", true)
+	f5Value := th.NewTypeContainerMapUnordered[int64, int64](th.TypeContainerDesc{Key: thrift.I64, Value: thrift.I64}, true)
+	f5.SetValue(f5Value)
+	f6 := th.NewTField(12, thrift.MAP, "mapI32", false)
+	f6Value := th.NewTypeContainerMap[int32, int32](th.TypeContainerDesc{Key: thrift.I32, Value: thrift.I32}, false)
+	f6.SetValue(f6Value)
+	m.AddField(f1, f2, f3, f4, f5, f6)
+
+	f1.SetValue("hello")
+	f2.SetValue(int64(0xcafe))
+	f4Value.Value = listData
+	f5Value.Value = mapI64
+	f6Value.FromMap(mapI32)
+
+	st := modelStructPlan().Bind(&m)
+
+	// check
+	st.Write(ctx, prot)
+	prot.Flush(ctx)
+	fmt.Println(b.Bytes())
+	f6Value.Value = nil
+	bn.ResetTimer()
+	for i := 0; i < bn.N; i++ {
+		b.Reset()
+		f4Value.Value = listData
+		f5Value.Value = mapI64
+
+		err = st.Write(ctx, prot)
+		if err != nil {
+			fmt.Println(err)
+			bn.Fail()
+		}
+		err = prot.Flush(ctx)
+		if err != nil {
+			fmt.Println(err)
+			bn.Fail()
+		}
+	}
+	bn.StopTimer()
+}
+
+func benchmarkModelRead(bn *testing.B, f func(ctx context.Context, prot thrift.TProtocol) error) {
+	ctx := context.Background()
+	var b bytes.Buffer
+	trans := thrift.NewStreamTransportRW(&b)
+	protofactory := th.ProtocolFactory(th.ProtocolType_Compact, &thrift.TConfiguration{})
+	prot := protofactory.GetProtocol(trans)
+
+	m := base.Model{
+		Abc:     "hello",
+		Sd:      0xcafe,
+		ListI64: []int64{1, 2, 3},
+		MapI64:  map[int64]int64{1: 2},
+	}
+	if err := m.Write(ctx, prot); err != nil {
+		panic(err)
+	}
+	prot.Flush(ctx)
+	data := make([]byte, b.Len())
+	copy(data, b.Bytes())
+
+	var reader bytes.Reader
+	rtrans := thrift.NewStreamTransportR(&reader)
+	rtrans.Reader = &reader
+	rprot := protofactory.GetProtocol(rtrans)
+	bn.ResetTimer()
+	for i := 0; i < bn.N; i++ {
+		reader.Reset(data)
+		if err := f(ctx, rprot); err != nil {
+			fmt.Println(err)
+			bn.Fail()
+		}
+	}
+	bn.StopTimer()
+}
+
+func BenchmarkModelReadOriginal(bn *testing.B) {
+	var m base.Model
+	benchmarkModelRead(bn, func(ctx context.Context, prot thrift.TProtocol) error {
+		return m.Read(ctx, prot)
+	})
+}
+
+func BenchmarkModelReadDyn(bn *testing.B) {
+	var m th.RPCStruct
+	benchmarkModelRead(bn, func(ctx context.Context, prot thrift.TProtocol) error {
+		return m.Read(ctx, prot)
+	})
+}
+
+func BenchmarkModelReadPlan(bn *testing.B) {
+	var m th.RPCStruct
+	st := modelStructPlan().Bind(&m)
+	benchmarkModelRead(bn, func(ctx context.Context, prot thrift.TProtocol) error {
+		return st.Read(ctx, prot)
+	})
+}
+
 func TestModelRebuild(t *testing.T) {
 	ctx := context.Background()
 	is := require.New(t)
@@ -235,26 +368,15 @@ func TestModelRebuild(t *testing.T) {
 
 	// rebuild model
 
-	var m2 th.RPCStruct
-	f1 := th.NewTField(1, thrift.STRING, "abc", true)
-	f2 := th.NewTField(4, thrift.I64, "sd", true)
-	f3 := th.NewTField(9, thrift.DOUBLE, "f64", true)
-	f4 := th.NewTField(10, thrift.LIST, "listI64", true)
-	f4Value := th.NewTypeContainerList[int64](th.TypeContainerDesc{Value: thrift.I64}, true)
-	f4.SetValue(f4Value)
-	f5 := th.NewTField(11, thrift.MAP, "mapI64", true)
-	f5Value := th.NewTypeContainerMapUnordered[int64, int64](th.TypeContainerDesc{Key: thrift.I64, Value: thrift.I64}, true)
-	f5.SetValue(f5Value)
-	f6 := th.NewTField(12, thrift.MAP, "mapI32", false)
-	f6Value := th.NewTypeContainerMap[int32, int32](th.TypeContainerDesc{Key: thrift.I32, Value: thrift.I32}, false)
-	f6.SetValue(f6Value) // comment this to make it act as optional
-	m2.AddField(f1, f2, f3, f4, f5, f6)
-	//
-	f1.SetValue(m.Abc)
-	f2.SetValue(m.Sd)
-	f4Value.Value = m.ListI64
-	f5Value.Value = m.MapI64
-	f6Value.FromMap(m.MapI32)
+	m2, err := th.NewStruct("Model").
+		String(1, "abc", m.Abc).Required().
+		I64(4, "sd", m.Sd).Required().
+		Double(9, "f64", m.F64).Required().
+		List(10, "listI64", thrift.I64, m.ListI64).Required().
+		Map(11, "mapI64", thrift.I64, thrift.I64, m.MapI64).Required().
+		Map(12, "mapI32", thrift.I32, thrift.I32, m.MapI32).
+		Build()
+	is.NoError(err)
 
 	err = m2.Write(ctx, prot)
 	is.NoError(err)
@@ -266,8 +388,7 @@ func TestModelRebuild(t *testing.T) {
 	copy(actual, b.Bytes())
 	b.Reset()
 
-	fmt.Printf("%+#v\n%+#v\n - f1: %+#v\n - f2: %+#v\n - f3: %+#v\n - f4: %+#v\n",
-		m, m2, f1, f2, f3, f4)
+	fmt.Printf("%+#v\n%+#v\n", m, m2)
 
 	is.Equal(expected, actual)
 }
@@ -479,3 +600,30 @@ func TestModelReadWireData(t *testing.T) {
 	}
 	wg.Wait() // wait srv goroutine end.
 }
+
+type modelDTO struct {
+	Abc     string          `thrift:"abc,1"`
+	Sd      int64           `thrift:"sd,4"`
+	F64     float64         `thrift:"f64,9"`
+	ListI64 []int64         `thrift:"listI64,10"`
+	MapI64  map[int64]int64 `thrift:"mapI64,11"`
+	MapI32  map[int32]int32 `thrift:"mapI32,12"`
+}
+
+func TestModelTaggedStruct(t *testing.T) {
+	pf := th.ProtocolFactory(th.ProtocolType_Compact, &thrift.TConfiguration{})
+	enc, dec := th.NewEncoder(pf), th.NewDecoder(pf)
+
+	m := base.Model{Abc: "hello", Sd: 0xcafe, ListI64: []int64{1, 2, 3}, MapI64: map[int64]int64{1: 2}}
+	expected, err := enc.Encode(&m)
+	require.NoError(t, err)
+
+	dto := modelDTO{Abc: m.Abc, Sd: m.Sd, ListI64: m.ListI64, MapI64: m.MapI64}
+	actual, err := enc.Encode(&dto)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	var dto2 modelDTO
+	require.NoError(t, dec.Decode(expected, &dto2))
+	require.Equal(t, dto, dto2)
+}
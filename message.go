@@ -0,0 +1,99 @@
+package thrift_dyn
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"strings"
+)
+
+// TMessageHeader describes a thrift message header, with the service name
+// of TMultiplexedProtocol (`Service:method`) split from the method name.
+type TMessageHeader struct {
+	Service string
+	Method  string
+	Type    thrift.TMessageType
+	SeqID   int32
+}
+
+// SplitMessageName split multiplexed message name into service and method name.
+// service is empty if name has no service prefix, name with a leading separator
+// is a method name as is, so that JoinMessageName gives it back.
+func SplitMessageName(name string) (service, method string) {
+	if service, method, ok := strings.Cut(name, thrift.MULTIPLEXED_SEPARATOR); ok && service != "" {
+		return service, method
+	}
+	return "", name
+}
+
+// JoinMessageName join service and method into multiplexed message name.
+// method is returned as-is if service is empty.
+func JoinMessageName(service, method string) string {
+	if service == "" {
+		return method
+	}
+	return service + thrift.MULTIPLEXED_SEPARATOR + method
+}
+
+// NewTMessageHeader create new TMessageHeader from wire message name.
+func NewTMessageHeader(name string, typeId thrift.TMessageType, seqId int32) TMessageHeader {
+	service, method := SplitMessageName(name)
+	return TMessageHeader{
+		Service: service,
+		Method:  method,
+		Type:    typeId,
+		SeqID:   seqId,
+	}
+}
+
+// Name get message name as written to the wire.
+func (h TMessageHeader) Name() string {
+	return JoinMessageName(h.Service, h.Method)
+}
+
+// Write write message begin to the wire.
+func (h TMessageHeader) Write(ctx context.Context, p thrift.TProtocol) (err error) {
+	return p.WriteMessageBegin(ctx, h.Name(), h.Type, h.SeqID)
+}
+
+// Read read message begin from the wire.
+func (h *TMessageHeader) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	var (
+		name   string
+		typeId thrift.TMessageType
+		seqId  int32
+	)
+	if name, typeId, seqId, err = p.ReadMessageBegin(ctx); err != nil {
+		return
+	}
+	*h = NewTMessageHeader(name, typeId, seqId)
+	return
+}
+
+// WriteMessage write message header, body and message end to the wire.
+func WriteMessage(ctx context.Context, p thrift.TProtocol, h TMessageHeader, body thrift.TStruct) (err error) {
+	if err = h.Write(ctx, p); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s write message begin error: ", h.Name()), err)
+	}
+	if err = body.Write(ctx, p); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s write message body error: ", h.Name()), err)
+	}
+	if err = p.WriteMessageEnd(ctx); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s write message end error: ", h.Name()), err)
+	}
+	return
+}
+
+// ReadMessage read message header, body and message end from the wire.
+func ReadMessage(ctx context.Context, p thrift.TProtocol, body thrift.TStruct) (h TMessageHeader, err error) {
+	if err = h.Read(ctx, p); err != nil {
+		return h, thrift.PrependError("read message begin error: ", err)
+	}
+	if err = body.Read(ctx, p); err != nil {
+		return h, thrift.PrependError(fmt.Sprintf("%s read message body error: ", h.Name()), err)
+	}
+	if err = p.ReadMessageEnd(ctx); err != nil {
+		return h, thrift.PrependError(fmt.Sprintf("%s read message end error: ", h.Name()), err)
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"context"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitMessageName(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		service string
+		method  string
+	}{
+		{"echoRequest", "", "echoRequest"},
+		{"Example:echoRequest", "Example", "echoRequest"},
+		{"Example:ns:echoRequest", "Example", "ns:echoRequest"},
+		{":echoRequest", "", ":echoRequest"},
+		{"::echoRequest", "", "::echoRequest"},
+	} {
+		service, method := SplitMessageName(tc.name)
+		require.Equal(t, tc.service, service, tc.name)
+		require.Equal(t, tc.method, method, tc.name)
+		require.Equal(t, tc.name, JoinMessageName(service, method))
+	}
+	require.Equal(t, "echoRequest", JoinMessageName("", "echoRequest"))
+	require.Equal(t, "Example:echoRequest", JoinMessageName("Example", "echoRequest"))
+}
+
+func TestMessageMultiplexed(t *testing.T) {
+	var err error
+	ctx := context.Background()
+	m := base.Model{
+		Abc: "hello",
+		Sd:  0xcafe,
+		F64: 1.05,
+	}
+	withBytesTTransport(t, func(b *bytes.Buffer, trans thrift.TTransport) {
+		withProtocols(t, defaultTestTProtocols, nil, func(protofactory thrift.TProtocolFactory) {
+			prot := thrift.NewTMultiplexedProtocol(protofactory.GetProtocol(trans), "Example")
+			err = prot.WriteMessageBegin(ctx, "echoRequest", thrift.CALL, 7)
+			require.NoError(t, err)
+			err = m.Write(ctx, prot)
+			require.NoError(t, err)
+			err = prot.WriteMessageEnd(ctx)
+			require.NoError(t, err)
+			err = prot.Flush(ctx)
+			require.NoError(t, err)
+
+			expected := make([]byte, b.Len())
+			copy(expected, b.Bytes())
+			b.Reset()
+
+			var actual RPCStruct
+			dec := NewDecoder(protofactory)
+			h, err := dec.DecodeMessage(expected, &actual)
+			require.NoError(t, err)
+			require.Equal(t, TMessageHeader{
+				Service: "Example",
+				Method:  "echoRequest",
+				Type:    thrift.CALL,
+				SeqID:   7,
+			}, h)
+			require.Len(t, actual.Fields, 3)
+
+			enc := NewEncoder(protofactory)
+			bb, err := enc.EncodeMessage(h, &actual)
+			require.NoError(t, err)
+			require.Equal(t, expected, bb)
+
+			var out bytes.Buffer
+			_, err = enc.WriteMessageTo(&out, h, &actual)
+			require.NoError(t, err)
+			h, err = dec.ReadMessageFrom(&out, &RPCStruct{})
+			require.NoError(t, err)
+			require.Equal(t, "Example:echoRequest", h.Name())
+		})
+	})
+}
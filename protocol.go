@@ -1,6 +1,15 @@
 package thrift_dyn
 
-import "github.com/apache/thrift/lib/go/thrift"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+var (
+	ErrUnsupportedProtocol = errors.New("unsupported protocol type")
+)
 
 type ProtocolType = string
 
@@ -9,6 +18,7 @@ const (
 	ProtocolType_Binary     ProtocolType = "tbinary"
 	ProtocolType_SimpleJSON ProtocolType = "tsimplejson"
 	ProtocolType_JSON       ProtocolType = "tjson"
+	ProtocolType_Text       ProtocolType = "ttext"
 )
 
 var ProtocolType_VALUES = []ProtocolType{
@@ -16,19 +26,54 @@ var ProtocolType_VALUES = []ProtocolType{
 	ProtocolType_Binary,
 	ProtocolType_SimpleJSON,
 	ProtocolType_JSON,
+	ProtocolType_Text,
 }
 
+// ProtocolFactory get protocol factory of ptype, protocols of unknown ptype
+// fail every read and write with ErrUnsupportedProtocol.
 func ProtocolFactory(ptype ProtocolType, conf *thrift.TConfiguration) thrift.TProtocolFactory {
+	pf, err := NewProtocolFactory(ptype, conf)
+	if err != nil {
+		return unsupportedProtocolFactory{err: err, conf: conf}
+	}
+	return pf
+}
+
+// NewProtocolFactory get protocol factory of ptype, error if ptype is unknown.
+func NewProtocolFactory(ptype ProtocolType, conf *thrift.TConfiguration) (thrift.TProtocolFactory, error) {
 	switch ptype {
 	case ProtocolType_Compact:
-		return thrift.NewTCompactProtocolFactoryConf(conf)
+		return thrift.NewTCompactProtocolFactoryConf(conf), nil
 	case ProtocolType_Binary:
-		return thrift.NewTBinaryProtocolFactoryConf(conf)
+		return thrift.NewTBinaryProtocolFactoryConf(conf), nil
 	case ProtocolType_SimpleJSON:
-		return thrift.NewTSimpleJSONProtocolFactoryConf(conf)
+		return thrift.NewTSimpleJSONProtocolFactoryConf(conf), nil
 	case ProtocolType_JSON:
-		return thrift.NewTJSONProtocolFactory()
-	default:
-		panic("unsupported protocol type")
+		return thrift.NewTJSONProtocolFactory(), nil
+	case ProtocolType_Text:
+		return NewTTextProtocolFactoryConf(conf), nil
 	}
+	return nil, fmt.Errorf("%w %q", ErrUnsupportedProtocol, ptype)
 }
+
+// unsupportedProtocolFactory make protocols over a transport that fails every I/O.
+type unsupportedProtocolFactory struct {
+	err  error
+	conf *thrift.TConfiguration
+}
+
+func (f unsupportedProtocolFactory) GetProtocol(trans thrift.TTransport) thrift.TProtocol {
+	return thrift.NewTBinaryProtocolConf(unsupportedTransport{err: thrift.NewTTransportExceptionFromError(f.err)}, f.conf)
+}
+
+type unsupportedTransport struct {
+	err error
+}
+
+func (t unsupportedTransport) Read(p []byte) (int, error)         { return 0, t.err }
+func (t unsupportedTransport) Write(p []byte) (int, error)        { return 0, t.err }
+func (t unsupportedTransport) Close() error                       { return nil }
+func (t unsupportedTransport) Flush(ctx context.Context) error    { return t.err }
+func (t unsupportedTransport) RemainingBytes() (num_bytes uint64) { return 0 }
+func (t unsupportedTransport) Open() error                        { return t.err }
+func (t unsupportedTransport) IsOpen() bool                       { return false }
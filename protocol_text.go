@@ -0,0 +1,944 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"strconv"
+	"strings"
+)
+
+// TTextProtocol is a human-readable, debug-oriented protocol.
+// Unlike TSimpleJSONProtocol it can be read back, types are written explicitly:
+//
+//	struct {
+//	  1: string "hello"  // abc
+//	  4: i64 51966  // sd
+//	  10: list<i64> [
+//	    1,
+//	    2
+//	  ]
+//	  11: map<i64,string> {
+//	    1: "one"
+//	  }
+//	}
+//
+// Commas are optional on read, comments start with `//` or `#`, or are wrapped by `/* */`.
+type TTextProtocol struct {
+	trans         thrift.TRichTransport
+	origTransport thrift.TTransport
+	cfg           *thrift.TConfiguration
+
+	// writer state
+	wstack []textFrame
+	indent int
+
+	// reader state
+	rstack  []textFrame
+	ahead   []textToken
+	unread  byte
+	hasByte bool
+	broken  bool
+}
+
+type TTextProtocolFactory struct {
+	cfg *thrift.TConfiguration
+}
+
+type textFrameKind uint8
+
+const (
+	textFrameStruct textFrameKind = iota
+	textFrameList
+	textFrameMap
+)
+
+type textFrame struct {
+	kind  textFrameKind
+	count int
+	name  string // field name of struct
+}
+
+type textTokenKind uint8
+
+const (
+	textTokenPunct textTokenKind = iota
+	textTokenWord
+	textTokenString
+)
+
+type textToken struct {
+	kind  textTokenKind
+	value string
+}
+
+var textTypeNames = map[thrift.TType]string{
+	thrift.BOOL:   "bool",
+	thrift.BYTE:   "byte",
+	thrift.I16:    "i16",
+	thrift.I32:    "i32",
+	thrift.I64:    "i64",
+	thrift.DOUBLE: "double",
+	thrift.STRING: "string",
+	thrift.STRUCT: "struct",
+	thrift.MAP:    "map",
+	thrift.SET:    "set",
+	thrift.LIST:   "list",
+}
+
+var textTypeByName = map[string]thrift.TType{
+	"bool":   thrift.BOOL,
+	"byte":   thrift.BYTE,
+	"i8":     thrift.BYTE,
+	"i16":    thrift.I16,
+	"i32":    thrift.I32,
+	"i64":    thrift.I64,
+	"double": thrift.DOUBLE,
+	"string": thrift.STRING,
+	"binary": thrift.STRING,
+	"struct": thrift.STRUCT,
+	"map":    thrift.MAP,
+	"set":    thrift.SET,
+	"list":   thrift.LIST,
+}
+
+var textMessageTypeNames = map[thrift.TMessageType]string{
+	thrift.CALL:      "call",
+	thrift.REPLY:     "reply",
+	thrift.EXCEPTION: "exception",
+	thrift.ONEWAY:    "oneway",
+}
+
+func NewTTextProtocolFactoryConf(conf *thrift.TConfiguration) *TTextProtocolFactory {
+	return &TTextProtocolFactory{cfg: conf}
+}
+
+func (f *TTextProtocolFactory) GetProtocol(trans thrift.TTransport) thrift.TProtocol {
+	return NewTTextProtocolConf(trans, f.cfg)
+}
+
+func NewTTextProtocolConf(trans thrift.TTransport, conf *thrift.TConfiguration) *TTextProtocol {
+	p := &TTextProtocol{
+		origTransport: trans,
+		cfg:           conf,
+	}
+	if et, ok := trans.(thrift.TRichTransport); ok {
+		p.trans = et
+	} else {
+		p.trans = thrift.NewTRichTransport(trans)
+	}
+	return p
+}
+
+func textTypeName(t thrift.TType) string {
+	if name, ok := textTypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+//
+// Writing methods.
+//
+
+func (p *TTextProtocol) write(s string) error {
+	_, err := p.trans.WriteString(s)
+	return thrift.NewTProtocolException(err)
+}
+
+func (p *TTextProtocol) newline() error {
+	return p.write("\n" + strings.Repeat("  ", p.indent))
+}
+
+// beginValue write separator of container elements, and the type name
+// of the value when it is not known from the enclosing container.
+func (p *TTextProtocol) beginValue(t thrift.TType) (err error) {
+	typed := true
+	if n := len(p.wstack); n > 0 {
+		frame := &p.wstack[n-1]
+		switch frame.kind {
+		case textFrameList:
+			if frame.count > 0 {
+				err = p.write(",")
+			}
+			if err == nil {
+				err = p.newline()
+			}
+			frame.count++
+			typed = false
+		case textFrameMap:
+			if frame.count%2 == 1 {
+				err = p.write(": ")
+			} else {
+				if frame.count > 0 {
+					err = p.write(",")
+				}
+				if err == nil {
+					err = p.newline()
+				}
+			}
+			frame.count++
+			typed = false
+		}
+	}
+	if err != nil {
+		return
+	}
+	switch t {
+	case thrift.STRUCT, thrift.MAP, thrift.SET, thrift.LIST:
+		return p.write(textTypeName(t))
+	}
+	if typed {
+		return p.write(textTypeName(t) + " ")
+	}
+	return
+}
+
+// endValue terminate top-level value.
+func (p *TTextProtocol) endValue() error {
+	if len(p.wstack) == 0 {
+		return p.write("\n")
+	}
+	return nil
+}
+
+func (p *TTextProtocol) pushWrite(kind textFrameKind) {
+	p.wstack = append(p.wstack, textFrame{kind: kind})
+	p.indent++
+}
+
+func (p *TTextProtocol) popWrite(closing string) (err error) {
+	n := len(p.wstack)
+	if n == 0 {
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, errors.New("text: unbalanced end of value"))
+	}
+	frame := p.wstack[n-1]
+	p.wstack = p.wstack[:n-1]
+	p.indent--
+	if frame.count > 0 {
+		if err = p.newline(); err != nil {
+			return
+		}
+	}
+	if err = p.write(closing); err != nil {
+		return
+	}
+	return p.endValue()
+}
+
+func (p *TTextProtocol) WriteMessageBegin(ctx context.Context, name string, typeId thrift.TMessageType, seqid int32) error {
+	typeName, ok := textMessageTypeNames[typeId]
+	if !ok {
+		typeName = strconv.Itoa(int(typeId))
+	}
+	return p.write(fmt.Sprintf("message %s %s %d\n", strconv.Quote(name), typeName, seqid))
+}
+
+func (p *TTextProtocol) WriteMessageEnd(ctx context.Context) error {
+	return nil
+}
+
+func (p *TTextProtocol) WriteStructBegin(ctx context.Context, name string) (err error) {
+	if err = p.beginValue(thrift.STRUCT); err != nil {
+		return
+	}
+	if err = p.write(" {"); err != nil {
+		return
+	}
+	p.pushWrite(textFrameStruct)
+	return
+}
+
+func (p *TTextProtocol) WriteStructEnd(ctx context.Context) error {
+	return p.popWrite("}")
+}
+
+func (p *TTextProtocol) WriteFieldBegin(ctx context.Context, name string, typeId thrift.TType, id int16) (err error) {
+	if n := len(p.wstack); n > 0 {
+		p.wstack[n-1].count++
+		p.wstack[n-1].name = name
+	}
+	if err = p.newline(); err != nil {
+		return
+	}
+	return p.write(strconv.Itoa(int(id)) + ": ")
+}
+
+func (p *TTextProtocol) WriteFieldEnd(ctx context.Context) error {
+	n := len(p.wstack)
+	if n == 0 || p.wstack[n-1].name == "" {
+		return nil
+	}
+	name := p.wstack[n-1].name
+	p.wstack[n-1].name = ""
+	return p.write("  // " + name)
+}
+
+func (p *TTextProtocol) WriteFieldStop(ctx context.Context) error {
+	return nil
+}
+
+func (p *TTextProtocol) WriteMapBegin(ctx context.Context, keyType thrift.TType, valueType thrift.TType, size int) (err error) {
+	if err = p.beginValue(thrift.MAP); err != nil {
+		return
+	}
+	if err = p.write("<" + textTypeName(keyType) + "," + textTypeName(valueType) + "> {"); err != nil {
+		return
+	}
+	p.pushWrite(textFrameMap)
+	return
+}
+
+func (p *TTextProtocol) WriteMapEnd(ctx context.Context) error {
+	return p.popWrite("}")
+}
+
+func (p *TTextProtocol) writeCollectionBegin(t thrift.TType, elemType thrift.TType) (err error) {
+	if err = p.beginValue(t); err != nil {
+		return
+	}
+	if err = p.write("<" + textTypeName(elemType) + "> ["); err != nil {
+		return
+	}
+	p.pushWrite(textFrameList)
+	return
+}
+
+func (p *TTextProtocol) WriteListBegin(ctx context.Context, elemType thrift.TType, size int) error {
+	return p.writeCollectionBegin(thrift.LIST, elemType)
+}
+
+func (p *TTextProtocol) WriteListEnd(ctx context.Context) error {
+	return p.popWrite("]")
+}
+
+func (p *TTextProtocol) WriteSetBegin(ctx context.Context, elemType thrift.TType, size int) error {
+	return p.writeCollectionBegin(thrift.SET, elemType)
+}
+
+func (p *TTextProtocol) WriteSetEnd(ctx context.Context) error {
+	return p.popWrite("]")
+}
+
+func (p *TTextProtocol) writeScalar(t thrift.TType, s string) (err error) {
+	if err = p.beginValue(t); err != nil {
+		return
+	}
+	if err = p.write(s); err != nil {
+		return
+	}
+	return p.endValue()
+}
+
+func (p *TTextProtocol) WriteBool(ctx context.Context, value bool) error {
+	return p.writeScalar(thrift.BOOL, strconv.FormatBool(value))
+}
+
+func (p *TTextProtocol) WriteByte(ctx context.Context, value int8) error {
+	return p.writeScalar(thrift.BYTE, strconv.FormatInt(int64(value), 10))
+}
+
+func (p *TTextProtocol) WriteI16(ctx context.Context, value int16) error {
+	return p.writeScalar(thrift.I16, strconv.FormatInt(int64(value), 10))
+}
+
+func (p *TTextProtocol) WriteI32(ctx context.Context, value int32) error {
+	return p.writeScalar(thrift.I32, strconv.FormatInt(int64(value), 10))
+}
+
+// writeEnum write enum as i32 followed by its symbolic name in comment.
+func (p *TTextProtocol) writeEnum(e TEnum) error {
+	return p.writeScalar(thrift.I32, strconv.FormatInt(int64(e.Value), 10)+" /* "+e.Name+" */")
+}
+
+func (p *TTextProtocol) WriteI64(ctx context.Context, value int64) error {
+	return p.writeScalar(thrift.I64, strconv.FormatInt(value, 10))
+}
+
+func (p *TTextProtocol) WriteDouble(ctx context.Context, value float64) error {
+	return p.writeScalar(thrift.DOUBLE, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (p *TTextProtocol) WriteString(ctx context.Context, value string) error {
+	return p.writeScalar(thrift.STRING, strconv.Quote(value))
+}
+
+func (p *TTextProtocol) WriteBinary(ctx context.Context, value []byte) error {
+	return p.writeScalar(thrift.STRING, strconv.Quote(string(value)))
+}
+
+//
+// Reading methods.
+//
+
+// readError mark reader state as broken, it is reset on next payload.
+func (p *TTextProtocol) readError(format string, args ...any) error {
+	p.broken = true
+	return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, fmt.Errorf("text: "+format, args...))
+}
+
+func (p *TTextProtocol) readByteDirect() (c byte, err error) {
+	if p.hasByte {
+		p.hasByte = false
+		return p.unread, nil
+	}
+	return p.trans.ReadByte()
+}
+
+func (p *TTextProtocol) unreadByte(c byte) {
+	p.unread, p.hasByte = c, true
+}
+
+func isTextWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '+' || c == '-'
+}
+
+// scan read next token from transport, whitespace, commas and comments are skipped.
+func (p *TTextProtocol) scan() (tok textToken, err error) {
+	var c byte
+	for {
+		if c, err = p.readByteDirect(); err != nil {
+			p.broken = true
+			return tok, thrift.NewTProtocolException(err)
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n', ',':
+			continue
+		case '#':
+			err = p.skipLine()
+		case '/':
+			if c, err = p.readByteDirect(); err != nil {
+				p.broken = true
+				return tok, thrift.NewTProtocolException(err)
+			}
+			switch c {
+			case '/':
+				err = p.skipLine()
+			case '*':
+				err = p.skipBlockComment()
+			default:
+				return tok, p.readError("unexpected character %q after '/'", c)
+			}
+		case '{', '}', '[', ']', '<', '>', ':':
+			return textToken{kind: textTokenPunct, value: string(c)}, nil
+		case '"':
+			return p.scanString()
+		default:
+			if !isTextWordByte(c) {
+				return tok, p.readError("unexpected character %q", c)
+			}
+			return p.scanWord(c)
+		}
+		if err != nil {
+			p.broken = true
+			return tok, thrift.NewTProtocolException(err)
+		}
+	}
+}
+
+func (p *TTextProtocol) skipLine() error {
+	for {
+		c, err := p.readByteDirect()
+		if err != nil || c == '\n' {
+			return err
+		}
+	}
+}
+
+func (p *TTextProtocol) skipBlockComment() error {
+	var prev byte
+	for {
+		c, err := p.readByteDirect()
+		if err != nil {
+			return err
+		}
+		if prev == '*' && c == '/' {
+			return nil
+		}
+		prev = c
+	}
+}
+
+func (p *TTextProtocol) scanString() (tok textToken, err error) {
+	var (
+		sb      strings.Builder
+		c       byte
+		escaped bool
+	)
+	sb.WriteByte('"')
+	for {
+		if c, err = p.readByteDirect(); err != nil {
+			p.broken = true
+			return tok, thrift.NewTProtocolException(err)
+		}
+		sb.WriteByte(c)
+		if escaped {
+			escaped = false
+			continue
+		}
+		if c == '\\' {
+			escaped = true
+		} else if c == '"' {
+			break
+		}
+	}
+	var value string
+	if value, err = strconv.Unquote(sb.String()); err != nil {
+		return tok, p.readError("invalid string literal %s: %s", sb.String(), err)
+	}
+	return textToken{kind: textTokenString, value: value}, nil
+}
+
+func (p *TTextProtocol) scanWord(first byte) (tok textToken, err error) {
+	var (
+		sb strings.Builder
+		c  byte
+	)
+	sb.WriteByte(first)
+	for {
+		if c, err = p.readByteDirect(); err != nil {
+			break
+		}
+		if !isTextWordByte(c) {
+			p.unreadByte(c)
+			break
+		}
+		sb.WriteByte(c)
+	}
+	// end of input terminates the word.
+	return textToken{kind: textTokenWord, value: sb.String()}, nil
+}
+
+func (p *TTextProtocol) peekN(n int) (tok textToken, err error) {
+	for len(p.ahead) <= n {
+		if tok, err = p.scan(); err != nil {
+			return
+		}
+		p.ahead = append(p.ahead, tok)
+	}
+	return p.ahead[n], nil
+}
+
+func (p *TTextProtocol) peek() (textToken, error) {
+	return p.peekN(0)
+}
+
+func (p *TTextProtocol) next() (tok textToken, err error) {
+	if tok, err = p.peek(); err != nil {
+		return
+	}
+	p.ahead = p.ahead[1:]
+	return
+}
+
+func (p *TTextProtocol) expect(punct string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind != textTokenPunct || tok.value != punct {
+		return p.readError("expected '%s', got %q", punct, tok.value)
+	}
+	return nil
+}
+
+func (p *TTextProtocol) expectWord() (string, error) {
+	tok, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if tok.kind != textTokenWord {
+		return "", p.readError("expected word, got %q", tok.value)
+	}
+	return tok.value, nil
+}
+
+func (p *TTextProtocol) readType() (thrift.TType, error) {
+	name, err := p.expectWord()
+	if err != nil {
+		return thrift.STOP, err
+	}
+	if t, ok := textTypeByName[name]; ok {
+		return t, nil
+	}
+	return thrift.STOP, p.readError("unknown type '%s'", name)
+}
+
+// readValueBegin consume map key-value separator and the optional type name of the value.
+func (p *TTextProtocol) readValueBegin(ts ...thrift.TType) (err error) {
+	if n := len(p.rstack); n > 0 {
+		frame := &p.rstack[n-1]
+		if frame.kind == textFrameMap && frame.count%2 == 1 {
+			if err = p.expect(":"); err != nil {
+				return
+			}
+		}
+		frame.count++
+	}
+	tok, err := p.peek()
+	if err != nil {
+		return
+	}
+	if tok.kind != textTokenWord {
+		return
+	}
+	t, ok := textTypeByName[tok.value]
+	if !ok {
+		return
+	}
+	for _, expected := range ts {
+		if t == expected {
+			_, err = p.next()
+			return
+		}
+	}
+	return p.readError("expected %s, got %s", textTypeName(ts[0]), tok.value)
+}
+
+// resetRead drop reader state left by previous malformed input.
+func (p *TTextProtocol) resetRead() {
+	if p.broken {
+		p.rstack = p.rstack[:0]
+		p.ahead = p.ahead[:0]
+		p.hasByte = false
+		p.broken = false
+	}
+}
+
+func (p *TTextProtocol) pushRead(kind textFrameKind) {
+	p.rstack = append(p.rstack, textFrame{kind: kind})
+}
+
+func (p *TTextProtocol) popRead(closing string) error {
+	if n := len(p.rstack); n > 0 {
+		p.rstack = p.rstack[:n-1]
+	}
+	return p.expect(closing)
+}
+
+// countElements count values of the container until its closing token, without consuming them.
+func (p *TTextProtocol) countElements() (count int, err error) {
+	var (
+		depth int
+		cont  bool
+		tok   textToken
+	)
+	for i := 0; ; i++ {
+		if tok, err = p.peekN(i); err != nil {
+			return
+		}
+		opening := tok.kind == textTokenPunct && (tok.value == "{" || tok.value == "[" || tok.value == "<")
+		closing := tok.kind == textTokenPunct && (tok.value == "}" || tok.value == "]" || tok.value == ">")
+		if depth > 0 {
+			if opening {
+				depth++
+			} else if closing {
+				depth--
+				cont = depth == 0 && tok.value == ">"
+			}
+			continue
+		}
+		if closing {
+			return
+		}
+		if tok.kind == textTokenPunct && tok.value == ":" {
+			cont = false
+			continue
+		}
+		if !cont {
+			count++
+		}
+		_, isType := textTypeByName[tok.value]
+		switch {
+		case opening:
+			depth++
+			cont = false
+		case tok.kind == textTokenWord && isType:
+			cont = true
+		default:
+			cont = false
+		}
+	}
+}
+
+func (p *TTextProtocol) ReadMessageBegin(ctx context.Context) (name string, typeId thrift.TMessageType, seqid int32, err error) {
+	p.resetRead()
+	var word string
+	if word, err = p.expectWord(); err != nil {
+		return
+	}
+	if word != "message" {
+		err = p.readError("expected 'message', got %q", word)
+		return
+	}
+	var tok textToken
+	if tok, err = p.next(); err != nil {
+		return
+	}
+	if tok.kind != textTokenString {
+		err = p.readError("expected message name, got %q", tok.value)
+		return
+	}
+	name = tok.value
+	if word, err = p.expectWord(); err != nil {
+		return
+	}
+	typeId = thrift.INVALID_TMESSAGE_TYPE
+	for t, typeName := range textMessageTypeNames {
+		if typeName == word {
+			typeId = t
+		}
+	}
+	if typeId == thrift.INVALID_TMESSAGE_TYPE {
+		err = p.readError("unknown message type '%s'", word)
+		return
+	}
+	if word, err = p.expectWord(); err != nil {
+		return
+	}
+	var v int64
+	if v, err = strconv.ParseInt(word, 10, 32); err != nil {
+		err = p.readError("invalid sequence id %q", word)
+		return
+	}
+	seqid = int32(v)
+	return
+}
+
+func (p *TTextProtocol) ReadMessageEnd(ctx context.Context) error {
+	return nil
+}
+
+func (p *TTextProtocol) ReadStructBegin(ctx context.Context) (name string, err error) {
+	p.resetRead()
+	if err = p.readValueBegin(thrift.STRUCT); err != nil {
+		return
+	}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	p.pushRead(textFrameStruct)
+	return
+}
+
+func (p *TTextProtocol) ReadStructEnd(ctx context.Context) error {
+	return p.popRead("}")
+}
+
+func (p *TTextProtocol) ReadFieldBegin(ctx context.Context) (name string, typeId thrift.TType, id int16, err error) {
+	var tok textToken
+	if tok, err = p.peek(); err != nil {
+		return
+	}
+	if tok.kind == textTokenPunct && tok.value == "}" {
+		return "", thrift.STOP, 0, nil
+	}
+	var word string
+	if word, err = p.expectWord(); err != nil {
+		return
+	}
+	var v int64
+	if v, err = strconv.ParseInt(word, 10, 16); err != nil {
+		err = p.readError("invalid field id %q", word)
+		return
+	}
+	id = int16(v)
+	if err = p.expect(":"); err != nil {
+		return
+	}
+	// type name is consumed by the value reader.
+	if tok, err = p.peek(); err != nil {
+		return
+	}
+	var ok bool
+	if typeId, ok = textTypeByName[tok.value]; !ok || tok.kind != textTokenWord {
+		err = p.readError("field %d: expected type name, got %q", id, tok.value)
+	}
+	return
+}
+
+func (p *TTextProtocol) ReadFieldEnd(ctx context.Context) error {
+	return nil
+}
+
+func (p *TTextProtocol) ReadMapBegin(ctx context.Context) (keyType thrift.TType, valueType thrift.TType, size int, err error) {
+	if err = p.readValueBegin(thrift.MAP); err != nil {
+		return
+	}
+	if err = p.expect("<"); err != nil {
+		return
+	}
+	if keyType, err = p.readType(); err != nil {
+		return
+	}
+	if valueType, err = p.readType(); err != nil {
+		return
+	}
+	if err = p.expect(">"); err != nil {
+		return
+	}
+	if err = p.expect("{"); err != nil {
+		return
+	}
+	if size, err = p.countElements(); err != nil {
+		return
+	}
+	if size%2 != 0 {
+		err = p.readError("map entry without value")
+		return
+	}
+	size /= 2
+	p.pushRead(textFrameMap)
+	return
+}
+
+func (p *TTextProtocol) ReadMapEnd(ctx context.Context) error {
+	return p.popRead("}")
+}
+
+func (p *TTextProtocol) readCollectionBegin() (elemType thrift.TType, size int, err error) {
+	// list and set are interchangeable, as they are on the wire.
+	if err = p.readValueBegin(thrift.LIST, thrift.SET); err != nil {
+		return
+	}
+	if err = p.expect("<"); err != nil {
+		return
+	}
+	if elemType, err = p.readType(); err != nil {
+		return
+	}
+	if err = p.expect(">"); err != nil {
+		return
+	}
+	if err = p.expect("["); err != nil {
+		return
+	}
+	if size, err = p.countElements(); err != nil {
+		return
+	}
+	p.pushRead(textFrameList)
+	return
+}
+
+func (p *TTextProtocol) ReadListBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	return p.readCollectionBegin()
+}
+
+func (p *TTextProtocol) ReadListEnd(ctx context.Context) error {
+	return p.popRead("]")
+}
+
+func (p *TTextProtocol) ReadSetBegin(ctx context.Context) (elemType thrift.TType, size int, err error) {
+	return p.readCollectionBegin()
+}
+
+func (p *TTextProtocol) ReadSetEnd(ctx context.Context) error {
+	return p.popRead("]")
+}
+
+func (p *TTextProtocol) readScalar(t thrift.TType) (word string, err error) {
+	if err = p.readValueBegin(t); err != nil {
+		return
+	}
+	return p.expectWord()
+}
+
+func (p *TTextProtocol) readInt(t thrift.TType, bitSize int) (v int64, err error) {
+	var word string
+	if word, err = p.readScalar(t); err != nil {
+		return
+	}
+	if v, err = strconv.ParseInt(word, 0, bitSize); err != nil {
+		err = p.readError("invalid %s %q", textTypeName(t), word)
+	}
+	return
+}
+
+func (p *TTextProtocol) ReadBool(ctx context.Context) (value bool, err error) {
+	var word string
+	if word, err = p.readScalar(thrift.BOOL); err != nil {
+		return
+	}
+	if value, err = strconv.ParseBool(word); err != nil {
+		err = p.readError("invalid bool %q", word)
+	}
+	return
+}
+
+func (p *TTextProtocol) ReadByte(ctx context.Context) (value int8, err error) {
+	v, err := p.readInt(thrift.BYTE, 8)
+	return int8(v), err
+}
+
+func (p *TTextProtocol) ReadI16(ctx context.Context) (value int16, err error) {
+	v, err := p.readInt(thrift.I16, 16)
+	return int16(v), err
+}
+
+func (p *TTextProtocol) ReadI32(ctx context.Context) (value int32, err error) {
+	v, err := p.readInt(thrift.I32, 32)
+	return int32(v), err
+}
+
+func (p *TTextProtocol) ReadI64(ctx context.Context) (value int64, err error) {
+	return p.readInt(thrift.I64, 64)
+}
+
+func (p *TTextProtocol) ReadDouble(ctx context.Context) (value float64, err error) {
+	var word string
+	if word, err = p.readScalar(thrift.DOUBLE); err != nil {
+		return
+	}
+	if value, err = strconv.ParseFloat(word, 64); err != nil {
+		err = p.readError("invalid double %q", word)
+	}
+	return
+}
+
+func (p *TTextProtocol) ReadString(ctx context.Context) (value string, err error) {
+	if err = p.readValueBegin(thrift.STRING); err != nil {
+		return
+	}
+	var tok textToken
+	if tok, err = p.next(); err != nil {
+		return
+	}
+	if tok.kind != textTokenString {
+		err = p.readError("expected string literal, got %q", tok.value)
+		return
+	}
+	return tok.value, nil
+}
+
+func (p *TTextProtocol) ReadBinary(ctx context.Context) (value []byte, err error) {
+	var s string
+	if s, err = p.ReadString(ctx); err != nil {
+		return
+	}
+	return []byte(s), nil
+}
+
+func (p *TTextProtocol) Flush(ctx context.Context) (err error) {
+	return thrift.NewTProtocolException(p.trans.Flush(ctx))
+}
+
+func (p *TTextProtocol) Skip(ctx context.Context, fieldType thrift.TType) (err error) {
+	return thrift.SkipDefaultDepth(ctx, p, fieldType)
+}
+
+func (p *TTextProtocol) Transport() thrift.TTransport {
+	return p.origTransport
+}
+
+func (p *TTextProtocol) SetTConfiguration(conf *thrift.TConfiguration) {
+	thrift.PropagateTConfiguration(p.trans, conf)
+	thrift.PropagateTConfiguration(p.origTransport, conf)
+	p.cfg = conf
+}
+
+var (
+	_ thrift.TProtocol            = (*TTextProtocol)(nil)
+	_ thrift.TConfigurationSetter = (*TTextProtocol)(nil)
+)
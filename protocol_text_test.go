@@ -0,0 +1,168 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTextProtocolRoundTrip(t *testing.T) {
+	var err error
+	expected := base.Request{
+		Model: base.NewModel(),
+		Models: []*base.Model{
+			{Abc: "hello", Sd: 0xcafe, F64: 1.05, ListI64: []int64{1, 2, 3}},
+			{Abc: "\x00\xffbinary\"", MapI64: map[int64]int64{1: 2}},
+		},
+		ModelById: map[int64]*base.Model{
+			1234: base.NewModel(),
+		},
+		ModelByTime: map[int64][]*base.Model{
+			567: {base.NewModel()},
+			568: {},
+		},
+		Modset: []*base.Model{base.NewModel()},
+	}
+
+	pf := ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration)
+	enc := NewEncoder(pf)
+	dec := NewDecoder(pf)
+
+	bb, err := enc.Encode(&expected)
+	require.NoError(t, err)
+
+	var actual base.Request
+	err = dec.Decode(bb, &actual)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// rebuild from dynamic struct, field names are not part of the payload.
+	var st RPCStruct
+	err = dec.Decode(bb, &st)
+	require.NoError(t, err)
+	bb2, err := enc.Encode(&st)
+	require.NoError(t, err)
+	var actual2 base.Request
+	err = dec.Decode(bb2, &actual2)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual2)
+}
+
+func TestTextProtocolDecodeFixture(t *testing.T) {
+	var err error
+	fixture := `
+# handwritten fixture
+struct {
+  1: string "hello"  // abc
+  4: i64 0xcafe
+  9: double 1.05
+  10: list<i64> [1, 2, 3]
+  11: map<i64,i64> { 1: 2, 3: 4 }
+  12: map<i32,i32> {}
+}
+`
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration))
+	var actual base.Model
+	err = dec.Decode([]byte(fixture), &actual)
+	require.NoError(t, err)
+	require.Equal(t, base.Model{
+		Abc:     "hello",
+		Sd:      0xcafe,
+		F64:     1.05,
+		ListI64: []int64{1, 2, 3},
+		MapI64:  map[int64]int64{1: 2, 3: 4},
+		MapI32:  map[int32]int32{},
+	}, actual)
+
+	// same payload on the binary wire.
+	var st RPCStruct
+	err = dec.Decode([]byte(fixture), &st)
+	require.NoError(t, err)
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration))
+	expected, err := enc.Encode(&actual)
+	require.NoError(t, err)
+	bb, err := enc.Encode(&st)
+	require.NoError(t, err)
+	require.Equal(t, len(expected), len(bb)) // map order may differ
+}
+
+func TestTextProtocolNested(t *testing.T) {
+	var err error
+	fixture := `struct {
+  6: struct {
+    1: string "hello"
+    4: i64 51966
+  }
+  10: list<list> [
+    list<i32> [1, 2],
+    list<i32> []
+  ]
+  11: map<string,struct> {
+    "a": struct { 1: bool true },
+    "b": { }
+  }
+  12: set<struct> [ struct {}, {} ]
+}`
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration))
+	var st RPCStruct
+	err = dec.Decode([]byte(fixture), &st)
+	require.NoError(t, err)
+	require.Len(t, st.Fields, 4)
+
+	require.Equal(t, thrift.TType(thrift.STRUCT), st.Fields[0].Type)
+	inner := st.Fields[0].Value.(*RPCStruct)
+	require.Equal(t, []byte("hello"), inner.Fields[0].Value)
+	require.Equal(t, int64(51966), inner.Fields[1].Value)
+
+	lists := st.Fields[1].Value.(*TypeContainerList[TypeContainerImplementer])
+	require.Equal(t, 2, lists.GetSize())
+	require.Equal(t, []int32{1, 2}, lists.Value[0].(*TypeContainerList[int32]).Value)
+	require.Equal(t, 0, lists.Value[1].GetSize())
+
+	m := st.Fields[2].Value.(*TypeContainerMap[string, thrift.TStruct])
+	require.Equal(t, 2, m.GetSize())
+	require.Equal(t, "a", m.Value[0].Key)
+
+	set := st.Fields[3].Value.(*TypeContainerSet[thrift.TStruct])
+	require.Equal(t, 2, set.GetSize())
+}
+
+func TestTextProtocolMessage(t *testing.T) {
+	var err error
+	fixture := `message "Example:echoRequest" call 3
+struct {
+  2: struct {}
+}
+`
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration))
+	var st RPCStruct
+	h, err := dec.DecodeMessage([]byte(fixture), &st)
+	require.NoError(t, err)
+	require.Equal(t, TMessageHeader{Service: "Example", Method: "echoRequest", Type: thrift.CALL, SeqID: 3}, h)
+
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration))
+	bb, err := enc.EncodeMessage(h, &st)
+	require.NoError(t, err)
+	require.Equal(t, fixture, string(bb))
+}
+
+func TestTextProtocolInvalid(t *testing.T) {
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration))
+	for _, fixture := range []string{
+		`struct { 1: i64 "x" }`,
+		`struct { 1: i64 1`,
+		`struct { x: i64 1 }`,
+		`struct { 1: unknown 1 }`,
+		`struct { 1: map<i64,i64> { 1 } }`,
+		`struct { 1: i32 i64 1 }`,
+	} {
+		var st RPCStruct
+		require.Error(t, dec.Decode([]byte(fixture), &st), fixture)
+	}
+
+	// decoder is still usable after malformed input.
+	var st RPCStruct
+	require.NoError(t, dec.Decode([]byte(`struct { 1: i64 1 }`), &st))
+	require.Equal(t, int64(1), st.Fields[0].Value)
+}
@@ -0,0 +1,200 @@
+package thrift_dyn
+
+// Schema is a registry of definitions of a thrift IDL file.
+// Definitions are kept in declaration order, comments and annotations
+// of a parsed IDL are kept to write it back, see ParseIDL and Schema.WriteIDL.
+type Schema struct {
+	Comment     string // comment heading the file, separated from the rest by a blank line
+	Includes    []string
+	CppIncludes []string
+	Namespaces  []*NamespaceDesc
+	Definitions []Definition
+}
+
+// Definition is typedef, const, enum, struct or service of Schema.
+type Definition interface {
+	DefName() string
+	definition()
+}
+
+// Annotation is `key = "value"` annotation of IDL definition, field or type.
+type Annotation struct {
+	Key, Value string
+}
+
+// NamespaceDesc describes `namespace <scope> <name>`.
+type NamespaceDesc struct {
+	Scope, Name string
+	Annotations []Annotation
+}
+
+// TypedefDesc describes `typedef <type> <name>`.
+type TypedefDesc struct {
+	Name        string
+	Type        *TypeDesc
+	Comment     string
+	Annotations []Annotation
+}
+
+// ConstDesc describes `const <type> <name> = <value>`.
+type ConstDesc struct {
+	Name    string
+	Type    *TypeDesc
+	Value   string // literal as written in IDL
+	Comment string
+}
+
+// EnumDesc describes enum values.
+type EnumDesc struct {
+	Name        string
+	Values      []*EnumValueDesc
+	Comment     string
+	Annotations []Annotation
+}
+
+// EnumValueDesc describes an enum value.
+type EnumValueDesc struct {
+	Name        string
+	Value       int32
+	Comment     string
+	Trailing    string // comment on the same line
+	Annotations []Annotation
+}
+
+// ServiceDesc describes service functions.
+type ServiceDesc struct {
+	Name        string
+	Extends     string
+	Functions   []*FunctionDesc
+	Comment     string
+	Annotations []Annotation
+}
+
+// FunctionDesc describes a service function.
+type FunctionDesc struct {
+	Name        string
+	Oneway      bool
+	Result      *TypeDesc // nil if void
+	Args        []*FieldDesc
+	Throws      []*FieldDesc
+	Comment     string
+	Trailing    string // comment on the same line
+	Annotations []Annotation
+}
+
+func (d *TypedefDesc) DefName() string { return d.Name }
+func (d *ConstDesc) DefName() string   { return d.Name }
+func (d *EnumDesc) DefName() string    { return d.Name }
+func (d *StructDesc) DefName() string  { return d.Name }
+func (d *ServiceDesc) DefName() string { return d.Name }
+
+func (d *TypedefDesc) definition() {}
+func (d *ConstDesc) definition()   {}
+func (d *EnumDesc) definition()    {}
+func (d *StructDesc) definition()  {}
+func (d *ServiceDesc) definition() {}
+
+// NewSchema create new Schema of definitions.
+func NewSchema(defs ...Definition) *Schema {
+	return &Schema{Definitions: defs}
+}
+
+// Add add definitions to schema.
+func (s *Schema) Add(defs ...Definition) *Schema {
+	s.Definitions = append(s.Definitions, defs...)
+	return s
+}
+
+// Lookup get definition by name, nil if not found.
+func (s *Schema) Lookup(name string) Definition {
+	for _, def := range s.Definitions {
+		if def.DefName() == name {
+			return def
+		}
+	}
+	return nil
+}
+
+// Struct get struct, union or exception by name, nil if not found.
+func (s *Schema) Struct(name string) *StructDesc {
+	d, _ := s.Lookup(name).(*StructDesc)
+	return d
+}
+
+// Enum get enum by name, nil if not found.
+func (s *Schema) Enum(name string) *EnumDesc {
+	d, _ := s.Lookup(name).(*EnumDesc)
+	return d
+}
+
+// Typedef get typedef by name, nil if not found.
+func (s *Schema) Typedef(name string) *TypedefDesc {
+	d, _ := s.Lookup(name).(*TypedefDesc)
+	return d
+}
+
+// Service get service by name, nil if not found.
+func (s *Schema) Service(name string) *ServiceDesc {
+	d, _ := s.Lookup(name).(*ServiceDesc)
+	return d
+}
+
+// Structs get structs, unions and exceptions in declaration order.
+func (s *Schema) Structs() (descs []*StructDesc) {
+	for _, def := range s.Definitions {
+		if d, ok := def.(*StructDesc); ok {
+			descs = append(descs, d)
+		}
+	}
+	return
+}
+
+// Enums get enums in declaration order.
+func (s *Schema) Enums() (descs []*EnumDesc) {
+	for _, def := range s.Definitions {
+		if d, ok := def.(*EnumDesc); ok {
+			descs = append(descs, d)
+		}
+	}
+	return
+}
+
+// Services get services in declaration order.
+func (s *Schema) Services() (descs []*ServiceDesc) {
+	for _, def := range s.Definitions {
+		if d, ok := def.(*ServiceDesc); ok {
+			descs = append(descs, d)
+		}
+	}
+	return
+}
+
+// ValueByName get enum value by name, nil if not found.
+func (d *EnumDesc) ValueByName(name string) *EnumValueDesc {
+	for _, v := range d.Values {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// ValueByNumber get enum value by number, nil if not found.
+func (d *EnumDesc) ValueByNumber(n int32) *EnumValueDesc {
+	for _, v := range d.Values {
+		if v.Value == n {
+			return v
+		}
+	}
+	return nil
+}
+
+// Function get service function by name, nil if not found.
+func (d *ServiceDesc) Function(name string) *FunctionDesc {
+	for _, f := range d.Functions {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"sort"
+	"strconv"
+)
+
+// SchemaInferrer infer struct descriptors from sample payloads decoded as RPCStruct.
+// Samples are merged field by field, nested structs recursively, container element
+// types are taken from the wire and refined by later samples when a container is empty.
+type SchemaInferrer struct {
+	root *inferStruct
+}
+
+type inferStruct struct {
+	name    string
+	samples int
+	fields  map[TFieldID]*inferField
+}
+
+type inferField struct {
+	name    string
+	samples int
+	typ     *inferType
+}
+
+type inferType struct {
+	typ        thrift.TType // STOP if not known yet
+	key, value *inferType
+	st         *inferStruct
+}
+
+// NewSchemaInferrer create new SchemaInferrer, name is name of the top-level struct.
+func NewSchemaInferrer(name string) *SchemaInferrer {
+	return &SchemaInferrer{root: newInferStruct(name)}
+}
+
+func newInferStruct(name string) *inferStruct {
+	return &inferStruct{name: name, fields: map[TFieldID]*inferField{}}
+}
+
+// InferSchema infer struct descriptors from samples, see SchemaInferrer.
+func InferSchema(name string, samples ...*RPCStruct) ([]*StructDesc, error) {
+	in := NewSchemaInferrer(name)
+	for i, s := range samples {
+		if err := in.Add(s); err != nil {
+			return nil, fmt.Errorf("sample %d: %w", i, err)
+		}
+	}
+	return in.Structs(), nil
+}
+
+// Add merge sample into inferred schema. A field whose type conflicts with
+// previous samples keeps the type seen first, the conflict is returned as error
+// after the rest of the sample is merged.
+func (in *SchemaInferrer) Add(s *RPCStruct) error {
+	return in.root.merge(s, "")
+}
+
+func (st *inferStruct) merge(s *RPCStruct, path string) (err error) {
+	st.samples++
+	if st.name == "" && s.Name != "" {
+		st.name = s.Name
+	}
+	for _, f := range s.Fields {
+		field := st.fields[f.ID]
+		if field == nil {
+			field = &inferField{typ: &inferType{}}
+			st.fields[f.ID] = field
+		}
+		field.samples++
+		if field.name == "" {
+			field.name = f.Name
+		}
+		if ferr := field.typ.merge(f.Type, f.Value, joinWirePath(path, strconv.Itoa(int(f.ID)))); err == nil {
+			err = ferr
+		}
+	}
+	return
+}
+
+func (t *inferType) merge(typ thrift.TType, value any, path string) (err error) {
+	if typ == thrift.STOP {
+		return nil // element type of empty container is unknown.
+	}
+	if t.typ == thrift.STOP {
+		t.typ = typ
+	} else if t.typ != typ {
+		return fmt.Errorf("field %s: type %s conflicts with %s", path, textTypeName(typ), textTypeName(t.typ))
+	}
+	switch typ {
+	case thrift.STRUCT:
+		if t.st == nil {
+			t.st = newInferStruct("")
+		}
+		switch v := value.(type) {
+		case *RPCStruct:
+			return t.st.merge(v, path)
+		case *PlannedStruct:
+			return t.st.merge(v.RPCStruct, path)
+		case *RPCUnion:
+			return t.st.merge(&v.RPCStruct, path)
+		}
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		if t.key == nil {
+			t.key, t.value = &inferType{}, &inferType{}
+		}
+		typ, ok := value.(typeContainerDescriber)
+		if !ok {
+			return nil
+		}
+		desc := typ.GetDesc()
+		if err = t.key.merge(desc.Key, nil, path); err != nil {
+			return
+		}
+		if err = t.value.merge(desc.Value, nil, path); err != nil {
+			return
+		}
+		i := 0
+		containerEach(value, func(key, value any) {
+			elem := path + "[" + strconv.Itoa(i) + "]"
+			if kerr := t.key.merge(desc.Key, key, elem); err == nil {
+				err = kerr
+			}
+			if verr := t.value.merge(desc.Value, value, elem); err == nil {
+				err = verr
+			}
+			i++
+		})
+	}
+	return
+}
+
+// containerEach call fn with each element of container, key is nil except for map.
+func containerEach(c any, fn func(key, value any)) {
+	typ, ok := c.(TypeContainerImplementer)
+	if !ok {
+		return
+	}
+	isMap := false
+	if d, ok := c.(typeContainerDescriber); ok {
+		isMap = d.GetType() == thrift.MAP
+	}
+	typ.Range(func(k, v any) bool {
+		if !isMap {
+			k = nil
+		}
+		fn(k, v)
+		return true
+	})
+}
+
+// Structs get inferred struct descriptors, top-level struct first.
+// Nested structs are named after the field holding them, e.g. `RequestField44`,
+// fields are named after their id unless samples carry field names.
+// A field is optional when it is missing from some samples of its struct,
+// element type of containers that were empty in every sample is string.
+func (in *SchemaInferrer) Structs() []*StructDesc {
+	var descs []*StructDesc
+	in.root.desc(in.root.name, &descs)
+	return descs
+}
+
+func (st *inferStruct) desc(name string, descs *[]*StructDesc) *StructDesc {
+	if st.name != "" {
+		name = st.name
+	}
+	desc := NewStructDesc(name)
+	*descs = append(*descs, desc)
+	ids := make([]TFieldID, 0, len(st.fields))
+	for id := range st.fields {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		field := st.fields[id]
+		fieldName := field.name
+		if fieldName == "" {
+			fieldName = "field" + strconv.Itoa(int(id))
+		}
+		fd := NewFieldDesc(id, field.typ.desc(name+"Field"+strconv.Itoa(int(id)), descs), fieldName, false)
+		fd.Optional = field.samples < st.samples
+		desc.AddField(fd)
+	}
+	return desc
+}
+
+func (t *inferType) desc(name string, descs *[]*StructDesc) *TypeDesc {
+	switch t.typ {
+	case thrift.STOP:
+		return NewTypeDesc(thrift.STRING)
+	case thrift.STRUCT:
+		if t.st == nil {
+			t.st = newInferStruct("")
+		}
+		return NewTypeDescStruct(t.st.desc(name, descs))
+	case thrift.MAP:
+		return NewTypeDescMap(t.key.desc(name+"Key", descs), t.value.desc(name+"Value", descs))
+	case thrift.SET:
+		return NewTypeDescSet(t.value.desc(name, descs))
+	case thrift.LIST:
+		return NewTypeDescList(t.value.desc(name, descs))
+	}
+	return NewTypeDesc(t.typ)
+}
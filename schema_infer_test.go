@@ -0,0 +1,56 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+	var samples []*RPCStruct
+	for _, v := range []thrift.TStruct{
+		&base.Model{Abc: "a", ListI64: []int64{1}, MapI64: map[int64]int64{}},
+		&base.Model{Abc: "b", MapI64: map[int64]int64{1: 2}},
+		&base.Request{Models: []*base.Model{{Abc: "c", ListI64: []int64{1}}, {Abc: "d"}}},
+	} {
+		bb, err := enc.Encode(v)
+		require.NoError(t, err)
+		var st RPCStruct
+		require.NoError(t, dec.Decode(bb, &st))
+		samples = append(samples, &st)
+	}
+
+	descs, err := InferSchema("Model", samples[:2]...)
+	require.NoError(t, err)
+	var sb bytes.Buffer
+	require.NoError(t, WriteStructIDL(&sb, descs...))
+	require.Equal(t, `struct Model {
+  1: string field1
+  4: i64 field4
+  9: double field9
+  10: optional list<i64> field10
+  11: map<i64,i64> field11
+}
+`, sb.String())
+
+	descs, err = InferSchema("Request", samples[2])
+	require.NoError(t, err)
+	require.Len(t, descs, 3) // field 6 is an empty struct
+	require.Equal(t, "RequestField44", descs[2].Name)
+	require.Equal(t, "list<RequestField44>", descs[0].FieldByID(44).Type.String())
+	require.True(t, descs[2].FieldByID(10).Optional) // missing from the second element
+	require.False(t, descs[2].FieldByID(1).Optional)
+	require.NoError(t, descs[0].Validate())
+
+	// conflicting type keeps the first one.
+	in := NewSchemaInferrer("Model")
+	require.NoError(t, in.Add(samples[0]))
+	conflict := (&RPCStruct{}).AddField(NewTField(1, thrift.I32, "", false).SetValue(int32(1)))
+	require.EqualError(t, in.Add(conflict), "field 1: type i32 conflicts with string")
+	require.Equal(t, thrift.TType(thrift.STRING), in.Structs()[0].FieldByID(1).Type.Type)
+	require.True(t, in.Structs()[0].FieldByID(4).Optional)
+}
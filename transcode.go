@@ -0,0 +1,276 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"unicode/utf8"
+)
+
+// Transcode convert struct payload src from protocol from to protocol to.
+// Values are copied as they are read, no schema or RPCStruct is involved.
+// TSimpleJSON cannot be transcoded from, its payload carries no field ids.
+// STRING values that are not UTF-8 cannot be transcoded to TJSON and TSimpleJSON.
+func Transcode(src []byte, from, to ProtocolType) ([]byte, error) {
+	return transcode(src, from, to, CopyStruct)
+}
+
+// TranscodeMessage convert message payload src from protocol from to protocol to, see Transcode.
+func TranscodeMessage(src []byte, from, to ProtocolType) ([]byte, error) {
+	return transcode(src, from, to, CopyMessage)
+}
+
+func transcode(src []byte, from, to ProtocolType, copyFn func(ctx context.Context, in, out thrift.TProtocol) error) (bb []byte, err error) {
+	if from == ProtocolType_SimpleJSON {
+		return nil, fmt.Errorf("%w: cannot transcode from %s", ErrUnsupportedProtocol, from)
+	}
+	conf := &thrift.TConfiguration{}
+	ipf, err := NewProtocolFactory(from, conf)
+	if err != nil {
+		return
+	}
+	opf, err := NewProtocolFactory(to, conf)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	ctx := context.Background()
+	in := ipf.GetProtocol(thrift.NewStreamTransportR(bytes.NewReader(src)))
+	out := opf.GetProtocol(thrift.NewStreamTransportW(&buf))
+	if err = copyFn(ctx, in, out); err != nil {
+		return
+	}
+	if err = out.Flush(ctx); err != nil {
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// CopyMessage copy message header and body read from in to out.
+func CopyMessage(ctx context.Context, in, out thrift.TProtocol) (err error) {
+	name, typeId, seqId, err := in.ReadMessageBegin(ctx)
+	if err != nil {
+		return thrift.PrependError("read message begin error: ", err)
+	}
+	if err = out.WriteMessageBegin(ctx, name, typeId, seqId); err != nil {
+		return thrift.PrependError("write message begin error: ", err)
+	}
+	if err = CopyStruct(ctx, in, out); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s message body error: ", name), err)
+	}
+	if err = in.ReadMessageEnd(ctx); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s read message end error: ", name), err)
+	}
+	if err = out.WriteMessageEnd(ctx); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%s write message end error: ", name), err)
+	}
+	return
+}
+
+// CopyStruct copy struct read from in to out, nesting is limited
+// to thrift.DEFAULT_RECURSION_DEPTH as thrift.Skip does.
+func CopyStruct(ctx context.Context, in, out thrift.TProtocol) error {
+	return copyStruct(ctx, in, out, thrift.DEFAULT_RECURSION_DEPTH)
+}
+
+func copyStruct(ctx context.Context, in, out thrift.TProtocol, depth int) (err error) {
+	var (
+		name        string
+		fieldName   string
+		fieldTypeId thrift.TType
+		fieldId     int16
+	)
+	if depth <= 0 {
+		return thrift.NewTProtocolExceptionWithType(thrift.DEPTH_LIMIT, fmt.Errorf("depth limit exceeded"))
+	}
+	if name, err = in.ReadStructBegin(ctx); err != nil {
+		goto ReadStructBeginError
+	}
+	if err = out.WriteStructBegin(ctx, name); err != nil {
+		goto WriteStructBeginError
+	}
+	for {
+		fieldName, fieldTypeId, fieldId, err = in.ReadFieldBegin(ctx)
+		if err != nil {
+			goto ReadFieldBeginError
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		if err = out.WriteFieldBegin(ctx, fieldName, fieldTypeId, fieldId); err != nil {
+			goto WriteFieldError
+		}
+		if err = copyValue(ctx, in, out, fieldTypeId, depth); err != nil {
+			goto CopyFieldError
+		}
+		if err = in.ReadFieldEnd(ctx); err != nil {
+			goto ReadFieldEndError
+		}
+		if err = out.WriteFieldEnd(ctx); err != nil {
+			goto WriteFieldError
+		}
+	}
+	if err = out.WriteFieldStop(ctx); err != nil {
+		goto WriteFieldError
+	}
+	if err = in.ReadStructEnd(ctx); err != nil {
+		goto ReadStructEndError
+	}
+	if err = out.WriteStructEnd(ctx); err != nil {
+		goto WriteStructEndError
+	}
+	return nil
+ReadStructBeginError:
+	return thrift.PrependError("read struct begin error: ", err)
+WriteStructBeginError:
+	return thrift.PrependError("write struct begin error: ", err)
+ReadFieldBeginError:
+	return thrift.PrependError(fmt.Sprintf("read field %d begin error: ", fieldId), err)
+WriteFieldError:
+	return thrift.PrependError(fmt.Sprintf("write field %d error: ", fieldId), err)
+CopyFieldError:
+	return thrift.PrependError(fmt.Sprintf("copy field %d (%s) error: ", fieldId, fieldTypeId), err)
+ReadFieldEndError:
+	return thrift.PrependError(fmt.Sprintf("read field %d end error: ", fieldId), err)
+ReadStructEndError:
+	return thrift.PrependError("read struct end error: ", err)
+WriteStructEndError:
+	return thrift.PrependError("write struct end error: ", err)
+}
+
+func isJSONProtocol(p thrift.TProtocol) bool {
+	switch p.(type) {
+	case *thrift.TJSONProtocol, *thrift.TSimpleJSONProtocol:
+		return true
+	}
+	return false
+}
+
+// copyValue copy value of type t read from in to out.
+func copyValue(ctx context.Context, in, out thrift.TProtocol, t thrift.TType, depth int) (err error) {
+	switch t {
+	case thrift.BOOL:
+		var v bool
+		if v, err = in.ReadBool(ctx); err == nil {
+			err = out.WriteBool(ctx, v)
+		}
+	case thrift.BYTE:
+		var v int8
+		if v, err = in.ReadByte(ctx); err == nil {
+			err = out.WriteByte(ctx, v)
+		}
+	case thrift.I16:
+		var v int16
+		if v, err = in.ReadI16(ctx); err == nil {
+			err = out.WriteI16(ctx, v)
+		}
+	case thrift.I32:
+		var v int32
+		if v, err = in.ReadI32(ctx); err == nil {
+			err = out.WriteI32(ctx, v)
+		}
+	case thrift.I64:
+		var v int64
+		if v, err = in.ReadI64(ctx); err == nil {
+			err = out.WriteI64(ctx, v)
+		}
+	case thrift.DOUBLE:
+		var v float64
+		if v, err = in.ReadDouble(ctx); err == nil {
+			err = out.WriteDouble(ctx, v)
+		}
+	case thrift.STRING:
+		// string and binary share the type, JSON protocols carry binary
+		// as base64 text which is copied as is. Binary that is not UTF-8
+		// cannot be written to JSON as string without replacing its bytes.
+		var v string
+		if v, err = in.ReadString(ctx); err != nil {
+			return
+		}
+		if isJSONProtocol(out) && !utf8.ValidString(v) {
+			return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, fmt.Errorf("STRING of %d bytes is not UTF-8, binary cannot be transcoded to JSON", len(v)))
+		}
+		err = out.WriteString(ctx, v)
+	case thrift.STRUCT:
+		return copyStruct(ctx, in, out, depth-1)
+	case thrift.MAP:
+		var (
+			keyType, valueType thrift.TType
+			size               int
+		)
+		if keyType, valueType, size, err = in.ReadMapBegin(ctx); err != nil {
+			return
+		}
+		if err = out.WriteMapBegin(ctx, keyType, valueType, size); err != nil {
+			return
+		}
+		for i := 0; i < size; i++ {
+			if err = copyElem(ctx, in, out, keyType, depth); err != nil {
+				return
+			}
+			if err = copyElem(ctx, in, out, valueType, depth); err != nil {
+				return
+			}
+		}
+		if err = in.ReadMapEnd(ctx); err != nil {
+			return
+		}
+		return out.WriteMapEnd(ctx)
+	case thrift.SET:
+		var (
+			elemType thrift.TType
+			size     int
+		)
+		if elemType, size, err = in.ReadSetBegin(ctx); err != nil {
+			return
+		}
+		if err = out.WriteSetBegin(ctx, elemType, size); err != nil {
+			return
+		}
+		for i := 0; i < size; i++ {
+			if err = copyElem(ctx, in, out, elemType, depth); err != nil {
+				return
+			}
+		}
+		if err = in.ReadSetEnd(ctx); err != nil {
+			return
+		}
+		return out.WriteSetEnd(ctx)
+	case thrift.LIST:
+		var (
+			elemType thrift.TType
+			size     int
+		)
+		if elemType, size, err = in.ReadListBegin(ctx); err != nil {
+			return
+		}
+		if err = out.WriteListBegin(ctx, elemType, size); err != nil {
+			return
+		}
+		for i := 0; i < size; i++ {
+			if err = copyElem(ctx, in, out, elemType, depth); err != nil {
+				return
+			}
+		}
+		if err = in.ReadListEnd(ctx); err != nil {
+			return
+		}
+		return out.WriteListEnd(ctx)
+	default:
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, fmt.Errorf("unhandled type %s (%d)", t, t))
+	}
+	return
+}
+
+// copyElem copy container element, nested containers count toward depth.
+func copyElem(ctx context.Context, in, out thrift.TProtocol, t thrift.TType, depth int) error {
+	switch t {
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		if depth <= 1 {
+			return thrift.NewTProtocolExceptionWithType(thrift.DEPTH_LIMIT, fmt.Errorf("depth limit exceeded"))
+		}
+		return copyValue(ctx, in, out, t, depth-1)
+	}
+	return copyValue(ctx, in, out, t, depth)
+}
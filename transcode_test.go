@@ -0,0 +1,88 @@
+package thrift_dyn
+
+import (
+	"errors"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	encode := func(ptype ProtocolType) []byte {
+		bb, err := NewEncoder(ProtocolFactory(ptype, defaultTestTConfiguration)).Encode(testReuseRequest())
+		require.NoError(t, err)
+		return bb
+	}
+	for _, from := range ProtocolType_VALUES {
+		if from == ProtocolType_SimpleJSON {
+			continue
+		}
+		src := encode(from)
+		for _, to := range ProtocolType_VALUES {
+			bb, err := Transcode(src, from, to)
+			require.NoError(t, err, "%s -> %s", from, to)
+			if to == ProtocolType_SimpleJSON {
+				continue
+			}
+			// field names are not carried by every protocol, compare in binary.
+			actual, err := Transcode(bb, to, ProtocolType_Binary)
+			require.NoError(t, err, "%s -> %s -> binary", from, to)
+			require.Equal(t, encode(ProtocolType_Binary), actual, "%s -> %s -> binary", from, to)
+		}
+	}
+
+	// binary and compact are converted byte exact.
+	bb, err := Transcode(encode(ProtocolType_Binary), ProtocolType_Binary, ProtocolType_Compact)
+	require.NoError(t, err)
+	require.Equal(t, encode(ProtocolType_Compact), bb)
+}
+
+func TestTranscodeMessage(t *testing.T) {
+	h := NewTMessageHeader("Svc:call", thrift.REPLY, 7)
+	binary, err := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).EncodeMessage(h, base.NewModel())
+	require.NoError(t, err)
+	compact, err := NewEncoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).EncodeMessage(h, base.NewModel())
+	require.NoError(t, err)
+
+	bb, err := TranscodeMessage(binary, ProtocolType_Binary, ProtocolType_Compact)
+	require.NoError(t, err)
+	require.Equal(t, compact, bb)
+}
+
+func TestTranscodeMalformed(t *testing.T) {
+	_, err := Transcode([]byte("{}"), ProtocolType_SimpleJSON, ProtocolType_Binary)
+	require.True(t, errors.Is(err, ErrUnsupportedProtocol), err)
+	_, err = Transcode(nil, ProtocolType_Binary, "unknown")
+	require.True(t, errors.Is(err, ErrUnsupportedProtocol), err)
+
+	src, err := NewEncoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)).Encode(testReuseRequest())
+	require.NoError(t, err)
+	_, err = Transcode(src[:len(src)-3], ProtocolType_Compact, ProtocolType_Binary)
+	require.Error(t, err)
+
+	// compact: field 1 list<list>, nested beyond recursion depth.
+	nested := []byte{0x19}
+	for i := 0; i < 100000; i++ {
+		nested = append(nested, 0x19)
+	}
+	_, err = Transcode(nested, ProtocolType_Compact, ProtocolType_Binary)
+	var perr thrift.TProtocolException
+	require.True(t, errors.As(err, &perr), err)
+	require.Equal(t, thrift.DEPTH_LIMIT, perr.TypeId())
+
+	// binary that is not UTF-8 is not mangled into JSON.
+	src, err = NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).Encode(&base.Model{Abc: "\xff\xfe"})
+	require.NoError(t, err)
+	for _, to := range []ProtocolType{ProtocolType_JSON, ProtocolType_SimpleJSON} {
+		_, err = Transcode(src, ProtocolType_Binary, to)
+		require.True(t, errors.As(err, &perr), err)
+		require.Equal(t, thrift.INVALID_DATA, perr.TypeId())
+		require.ErrorContains(t, err, "copy field 1 (STRING) error: STRING of 2 bytes is not UTF-8")
+	}
+	bb, err := Transcode(src, ProtocolType_Binary, ProtocolType_Text)
+	require.NoError(t, err)
+	bb, err = Transcode(bb, ProtocolType_Text, ProtocolType_Binary)
+	require.NoError(t, err)
+	require.Equal(t, src, bb)
+}
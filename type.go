@@ -10,6 +10,11 @@ type TDataSpec struct {
 	Type     thrift.TType
 	Required bool
 	Protocol thrift.TProtocol
+
+	// Container is key and element type of MAP, SET and LIST,
+	// native Go values written as container are checked against it unless zero,
+	// empty and nil containers are written with its key and element type.
+	Container TypeContainerDesc
 }
 
 type TData[T any] struct {
@@ -22,8 +27,6 @@ func WriteDataGeneric(ctx context.Context, t TDataSpec, value any) (err error) {
 	case thrift.BOOL:
 		if value, ok := value.(bool); ok {
 			return t.Protocol.WriteBool(ctx, value)
-		} else if t.Required {
-			return t.Protocol.WriteBool(ctx, false)
 		}
 	case thrift.BYTE:
 		switch value := value.(type) {
@@ -32,22 +35,19 @@ func WriteDataGeneric(ctx context.Context, t TDataSpec, value any) (err error) {
 		case byte:
 			return t.Protocol.WriteByte(ctx, int8(value))
 		}
-		if t.Required {
-			return t.Protocol.WriteByte(ctx, 0)
-		}
 	case thrift.I16:
 		if value, ok := value.(int16); ok {
 			return t.Protocol.WriteI16(ctx, value)
 		}
-		if t.Required {
-			return t.Protocol.WriteI16(ctx, 0)
-		}
 	case thrift.I32:
-		if value, ok := value.(int32); ok {
+		switch value := value.(type) {
+		case int32:
 			return t.Protocol.WriteI32(ctx, value)
-		}
-		if t.Required {
-			return t.Protocol.WriteI32(ctx, 0)
+		case TEnum:
+			if value.Enum != nil && !value.Valid() {
+				return fmt.Errorf("%w %d of %s", ErrInvalidEnumValue, value.Value, value.Enum.Name)
+			}
+			return writeEnum(ctx, t.Protocol, value)
 		}
 	case thrift.I64:
 		switch value := value.(type) {
@@ -56,16 +56,10 @@ func WriteDataGeneric(ctx context.Context, t TDataSpec, value any) (err error) {
 		case int:
 			return t.Protocol.WriteI64(ctx, int64(value))
 		}
-		if t.Required {
-			return t.Protocol.WriteI64(ctx, 0)
-		}
 	case thrift.DOUBLE:
 		if value, ok := value.(float64); ok {
 			return t.Protocol.WriteDouble(ctx, value)
 		}
-		if t.Required {
-			return t.Protocol.WriteDouble(ctx, 0)
-		}
 	case thrift.STRING:
 		switch value := value.(type) {
 		case string:
@@ -73,26 +67,65 @@ func WriteDataGeneric(ctx context.Context, t TDataSpec, value any) (err error) {
 		case []byte:
 			return t.Protocol.WriteBinary(ctx, value)
 		}
-		if t.Required {
-			return t.Protocol.WriteBinary(ctx, []byte{})
-		}
 	case thrift.STRUCT:
 		if value, ok := value.(thrift.TStruct); ok {
 			return value.Write(ctx, t.Protocol)
 		}
-		if t.Required {
-			return (&RPCStruct{}).Write(ctx, t.Protocol)
-		}
-		return nil
 	case thrift.MAP, thrift.SET, thrift.LIST:
 		if value, ok := value.(TypeContainerImplementer); ok {
+			if value.GetSize() == 0 && t.Container.Value != thrift.STOP && !hasElemType(value) {
+				return writeEmptyContainer(ctx, t.Protocol, t.Type, t.Container)
+			}
 			return value.Write(ctx, t.Protocol)
 		}
+	}
+	// native Go value, e.g. named integer type, []int64 or map[int64]string.
+	if ok, err := writeGoValue(ctx, t, value); ok {
+		return err
+	}
+	if t.Required {
+		return writeZero(ctx, t)
+	}
+	switch t.Type {
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		if value == nil {
+			return writeZero(ctx, t)
+		}
+		return nil
+	case thrift.STRUCT:
 		return nil
 	}
 	return fmt.Errorf("expected %s, got %T", t.Type, value)
 }
 
+// writeZero write zero value of required field missing a value.
+func writeZero(ctx context.Context, t TDataSpec) error {
+	switch t.Type {
+	case thrift.BOOL:
+		return t.Protocol.WriteBool(ctx, false)
+	case thrift.BYTE:
+		return t.Protocol.WriteByte(ctx, 0)
+	case thrift.I16:
+		return t.Protocol.WriteI16(ctx, 0)
+	case thrift.I32:
+		return t.Protocol.WriteI32(ctx, 0)
+	case thrift.I64:
+		return t.Protocol.WriteI64(ctx, 0)
+	case thrift.DOUBLE:
+		return t.Protocol.WriteDouble(ctx, 0)
+	case thrift.STRING:
+		return t.Protocol.WriteBinary(ctx, []byte{})
+	case thrift.STRUCT:
+		return (&RPCStruct{}).Write(ctx, t.Protocol)
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		if t.Container.Value != thrift.STOP {
+			return writeEmptyContainer(ctx, t.Protocol, t.Type, t.Container)
+		}
+		return nil
+	}
+	return fmt.Errorf("expected %s, got nil", t.Type)
+}
+
 func WriteData[T any](ctx context.Context, t TData[T]) (err error) {
 	if t.Value == nil {
 		return
@@ -104,7 +137,7 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 	if value == nil {
 		return
 	}
-	if value, ok := (*value).(thrift.TStruct); ok { // cache hit
+	if value, ok := (*value).(thrift.TStruct); ok && t.Type == thrift.STRUCT { // cache hit
 		return value.Read(ctx, t.Protocol)
 	}
 	switch t.Type {
@@ -129,18 +162,15 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 	case thrift.STRING:
 		switch ((any)(*value)).(type) {
 		case string:
-			*value, err = t.Protocol.ReadString(ctx)
+			*value, err = readStringValue(ctx, t.Protocol)
 		default: // fallback
-			*value, err = t.Protocol.ReadBinary(ctx)
+			*value, err = readBinaryValue(ctx, t.Protocol)
 		}
 		return
 	case thrift.STRUCT:
 		st := &RPCStruct{}
 		err = st.Read(ctx, t.Protocol)
-		if err != nil {
-			return
-		}
-		*value = st
+		*value = st // partial on error
 		return
 	case thrift.MAP:
 		var (
@@ -150,17 +180,25 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 		if desc.Key, desc.Value, size, err = t.Protocol.ReadMapBegin(ctx); err != nil {
 			return
 		}
+		if err = checkDeclaredSize(t.Protocol, size); err != nil {
+			return
+		}
+		desc = t.Container.resolve(desc, size)
 		// if size > 0 {
 		var typ TypeContainerImplementer
-		typ, err = NewTypeContainerMapOfTType(desc, t.Required)
-		if err != nil {
-			return
+		if typ = reusableContainer(t.Protocol, *value, thrift.MAP, desc, size); typ == nil {
+			typ, err = newTypeContainerMapOfWire(desc, size, t.Required)
+			if err != nil {
+				skipInvalid(t.Protocol, func() error { return skipElems(ctx, t.Protocol, thrift.MAP, desc, size) })
+				return
+			}
 		}
 		typ.SetSize(size)
-		if err = typ.Read(ctx, t.Protocol); err != nil {
+		err = typ.Read(ctx, t.Protocol)
+		*value = typ // partial on error
+		if err != nil {
 			return
 		}
-		*value = typ
 		// }
 		if err = t.Protocol.ReadMapEnd(ctx); err != nil {
 			return
@@ -174,17 +212,25 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 		if elemType, size, err = t.Protocol.ReadSetBegin(ctx); err != nil {
 			return
 		}
+		if err = checkDeclaredSize(t.Protocol, size); err != nil {
+			return
+		}
+		desc := t.Container.resolve(TypeContainerDesc{Value: elemType}, size)
 		// if size > 0 {
 		var typ TypeContainerImplementer
-		typ, err = NewTypeContainerSetOfTType(TypeContainerDesc{Value: elemType}, t.Required)
-		if err != nil {
-			return
+		if typ = reusableContainer(t.Protocol, *value, thrift.SET, desc, size); typ == nil {
+			typ, err = NewTypeContainerSetOfTType(desc, t.Required)
+			if err != nil {
+				skipInvalid(t.Protocol, func() error { return skipElems(ctx, t.Protocol, thrift.SET, desc, size) })
+				return
+			}
 		}
 		typ.SetSize(size)
-		if err = typ.Read(ctx, t.Protocol); err != nil {
+		err = typ.Read(ctx, t.Protocol)
+		*value = typ // partial on error
+		if err != nil {
 			return
 		}
-		*value = typ
 		// }
 		if err = t.Protocol.ReadSetEnd(ctx); err != nil {
 			return
@@ -198,17 +244,25 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 		if elemType, size, err = t.Protocol.ReadListBegin(ctx); err != nil {
 			return
 		}
+		if err = checkDeclaredSize(t.Protocol, size); err != nil {
+			return
+		}
+		desc := t.Container.resolve(TypeContainerDesc{Value: elemType}, size)
 		// if size > 0 {
 		var typ TypeContainerImplementer
-		typ, err = NewTypeContainerListOfTType(TypeContainerDesc{Value: elemType}, t.Required)
-		if err != nil {
-			return
+		if typ = reusableContainer(t.Protocol, *value, thrift.LIST, desc, size); typ == nil {
+			typ, err = NewTypeContainerListOfTType(desc, t.Required)
+			if err != nil {
+				skipInvalid(t.Protocol, func() error { return skipElems(ctx, t.Protocol, thrift.LIST, desc, size) })
+				return
+			}
 		}
 		typ.SetSize(size)
-		if err = typ.Read(ctx, t.Protocol); err != nil {
+		err = typ.Read(ctx, t.Protocol)
+		*value = typ // partial on error
+		if err != nil {
 			return
 		}
-		*value = typ
 		// }
 		if err = t.Protocol.ReadListEnd(ctx); err != nil {
 			return
@@ -216,7 +270,7 @@ func ReadDataGeneric(ctx context.Context, t TDataSpec, value *any) (err error) {
 		return
 
 	default:
-		return fmt.Errorf("expected %s, got %T", t.Type, *value)
+		return fmt.Errorf("unhandled type %s (%d)", t.Type, t.Type)
 	}
 	return
 }
@@ -225,8 +279,10 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 	if t.Value == nil {
 		return
 	}
-	if value, ok := ((any)(*t.Value)).(thrift.TStruct); ok { // cache hit
-		return value.Read(ctx, t.Protocol)
+	if t.Type == thrift.STRUCT {
+		if value, ok := ((any)(*t.Value)).(thrift.TStruct); ok { // cache hit
+			return value.Read(ctx, t.Protocol)
+		}
 	}
 	switch value := (any)(t.Value).(type) {
 	case *bool:
@@ -246,6 +302,9 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 	case *int32:
 		*value, err = t.Protocol.ReadI32(ctx)
 		return
+	case *TEnum:
+		*value, err = readEnum(ctx, t.Protocol, value.Enum)
+		return
 	case *int64:
 		*value, err = t.Protocol.ReadI64(ctx)
 		return
@@ -253,18 +312,23 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 		*value, err = t.Protocol.ReadDouble(ctx)
 		return
 	case *string:
-		*value, err = t.Protocol.ReadString(ctx)
+		if dp := decodeProtocolOf(t.Protocol); dp != nil && dp.reuse {
+			*value, err = dp.readString(ctx, *value)
+			return
+		}
+		*value, err = readStringValue(ctx, t.Protocol)
 		return
 	case *[]byte:
-		*value, err = t.Protocol.ReadBinary(ctx)
+		if dp := decodeProtocolOf(t.Protocol); dp != nil && dp.reuse && !dp.zeroCopy {
+			*value, err = readBinaryInto(ctx, t.Protocol, *value)
+			return
+		}
+		*value, err = readBinaryValue(ctx, t.Protocol)
 		return
 	case *thrift.TStruct:
 		st := &RPCStruct{}
 		err = st.Read(ctx, t.Protocol)
-		if err != nil {
-			return
-		}
-		*value = st
+		*value = st // partial on error
 		return
 	case *TypeContainerImplementer:
 		var (
@@ -277,12 +341,16 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 			if desc.Key, desc.Value, size, err = t.Protocol.ReadMapBegin(ctx); err != nil {
 				return
 			}
-			typ, err = NewTypeContainerMapOfTType(desc, t.Required)
-			if err != nil {
-				return
+			desc = t.Container.resolve(desc, size)
+			if typ = reusableContainer(t.Protocol, *value, thrift.MAP, desc, size); typ == nil {
+				typ, err = newTypeContainerMapOfWire(desc, size, t.Required)
+				if err != nil {
+					return
+				}
 			}
 			typ.SetSize(size)
 			if err = typ.Read(ctx, t.Protocol); err != nil {
+				*value = typ // partial on error
 				return
 			}
 			if err = t.Protocol.ReadMapEnd(ctx); err != nil {
@@ -292,12 +360,16 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 			if desc.Value, size, err = t.Protocol.ReadSetBegin(ctx); err != nil {
 				return
 			}
-			typ, err = NewTypeContainerSetOfTType(desc, t.Required)
-			if err != nil {
-				return
+			desc = t.Container.resolve(desc, size)
+			if typ = reusableContainer(t.Protocol, *value, thrift.SET, desc, size); typ == nil {
+				typ, err = NewTypeContainerSetOfTType(desc, t.Required)
+				if err != nil {
+					return
+				}
 			}
 			typ.SetSize(size)
 			if err = typ.Read(ctx, t.Protocol); err != nil {
+				*value = typ // partial on error
 				return
 			}
 			if err = t.Protocol.ReadSetEnd(ctx); err != nil {
@@ -307,21 +379,51 @@ func ReadData[T any](ctx context.Context, t TData[T]) (err error) {
 			if desc.Value, size, err = t.Protocol.ReadListBegin(ctx); err != nil {
 				return
 			}
-			typ, err = NewTypeContainerListOfTType(desc, t.Required)
-			if err != nil {
-				return
+			desc = t.Container.resolve(desc, size)
+			if typ = reusableContainer(t.Protocol, *value, thrift.LIST, desc, size); typ == nil {
+				typ, err = NewTypeContainerListOfTType(desc, t.Required)
+				if err != nil {
+					return
+				}
 			}
 			typ.SetSize(size)
 			if err = typ.Read(ctx, t.Protocol); err != nil {
+				*value = typ // partial on error
 				return
 			}
 			if err = t.Protocol.ReadListEnd(ctx); err != nil {
 				return
 			}
+		default:
+			return fmt.Errorf("cannot read %s into %T", t.Type, value)
 		}
 		*value = typ
 	default:
-		return fmt.Errorf("expected %s, got %T (generic)", t.Type, value)
+		return fmt.Errorf("cannot read %s into %T", t.Type, value)
 	}
 	return
 }
+
+// hasElemType report whether container value knows its element type.
+func hasElemType(value TypeContainerImplementer) bool {
+	d, ok := value.(typeContainerDescriber)
+	return ok && d.GetDesc().Value != thrift.STOP
+}
+
+// reusableContainer get value as container to be read again in decoder reuse mode,
+// nil if reuse is disabled or value shape does not match.
+func reusableContainer(p thrift.TProtocol, value any, type_ thrift.TType, desc TypeContainerDesc, size int) TypeContainerImplementer {
+	if !isDecodeReuse(p) {
+		return nil
+	}
+	typ, ok := value.(TypeContainerImplementer)
+	if !ok {
+		return nil
+	}
+	if d, ok := typ.(typeContainerDescriber); !ok || d.GetType() != type_ {
+		return nil
+	} else if size > 0 && !d.GetDesc().sameWire(desc) {
+		return nil // empty container may not carry element type on the wire.
+	}
+	return typ
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
-	"reflect"
 )
 
 var (
@@ -23,6 +22,51 @@ type Sliceable interface {
 type TypeContainerDesc struct {
 	Key   thrift.TType
 	Value thrift.TType
+
+	// KeyDesc and ValueDesc describe key and element type recursively, nil if
+	// only the wire type is known, e.g. container read without a schema.
+	KeyDesc   *TypeDesc
+	ValueDesc *TypeDesc
+}
+
+// sameWire report whether key and element type on the wire are the same, nested descriptors are ignored.
+func (d TypeContainerDesc) sameWire(o TypeContainerDesc) bool {
+	return d.Key == o.Key && d.Value == o.Value
+}
+
+// resolve get desc of container read with wire header wire, nested descriptors
+// are kept when the wire matches d, empty container takes key and element type of d
+// since compact protocol does not carry them for empty map.
+func (d TypeContainerDesc) resolve(wire TypeContainerDesc, size int) TypeContainerDesc {
+	if d.Value == thrift.STOP {
+		return wire
+	}
+	if d.sameWire(wire) || size == 0 {
+		return d
+	}
+	return wire
+}
+
+// writeEmptyContainer write empty container of key and element type desc.
+func writeEmptyContainer(ctx context.Context, p thrift.TProtocol, type_ thrift.TType, desc TypeContainerDesc) (err error) {
+	switch type_ {
+	case thrift.MAP:
+		if err = p.WriteMapBegin(ctx, desc.Key, desc.Value, 0); err != nil {
+			return
+		}
+		return p.WriteMapEnd(ctx)
+	case thrift.SET:
+		if err = p.WriteSetBegin(ctx, desc.Value, 0); err != nil {
+			return
+		}
+		return p.WriteSetEnd(ctx)
+	case thrift.LIST:
+		if err = p.WriteListBegin(ctx, desc.Value, 0); err != nil {
+			return
+		}
+		return p.WriteListEnd(ctx)
+	}
+	return fmt.Errorf("expected container, got %s", type_)
 }
 
 type TypeContainerImplementer interface {
@@ -35,47 +79,70 @@ type TypeContainerImplementer interface {
 
 	Read(ctx context.Context, p thrift.TProtocol) (err error)
 	Write(ctx context.Context, p thrift.TProtocol) (err error)
-}
 
-type TypeContainer struct {
-	Type  thrift.TType
-	Desc  TypeContainerDesc
-	Size  int
-	Value reflect.Value
+	// Range call fn with each key and value until fn returns false,
+	// key is the element index for list and set.
+	Range(fn func(k, v any) bool)
 }
 
-func (t *TypeContainer) Init() *TypeContainer {
-	if t.Value.Kind() == reflect.Invalid {
-		switch t.Type {
-		case thrift.SET, thrift.LIST:
-			valType := TTypeToReflectType[t.Desc.Value]
-			t.Value = reflect.New(reflect.SliceOf(valType))
-		case thrift.MAP:
-			switch t.Desc.Key {
-			case thrift.MAP, thrift.LIST, thrift.SET:
-				goto InvalidType
-			}
-			keyType := TTypeToReflectType[t.Desc.Key]
-			valType := TTypeToReflectType[t.Desc.Value]
-			t.Value = reflect.MakeMapWithSize(reflect.MapOf(keyType, valType), 0)
-		default:
-			goto InvalidType
-		}
+// growReuse extend vv to hold element i, the element previously stored in
+// backing array is kept for reuse, zeroed otherwise.
+func growReuse[T any](vv []T, i int, reuse bool) []T {
+	var zero T
+	if i >= cap(vv) {
+		return append(vv, zero)
+	}
+	vv = vv[:i+1]
+	if !reuse {
+		vv[i] = zero
 	}
-	return t
-InvalidType:
-	panic(fmt.Sprintf("unhandled container type"))
+	return vv
 }
 
-// func (t *TypeContainer) Add(fs) {
-// }
+// partialElems get elements read before failure of element i,
+// a struct or container element that failed midway is kept with what was read.
+func partialElems[T any](vv []T, i int) []T {
+	if _, ok := any(vv[i]).(thrift.TStruct); ok {
+		return vv[:i+1]
+	}
+	return vv[:i]
+}
 
-func (t *TypeContainer) GetValue() any {
-	return t.Value.Interface()
+// partialEntries get entries read before failure of entry value i, see partialElems.
+func partialEntries[K comparable, V any](vv []TypeContainerMapItem[K, V], i int) []TypeContainerMapItem[K, V] {
+	if _, ok := any(vv[i].Value).(thrift.TStruct); ok {
+		return vv[:i+1]
+	}
+	return vv[:i]
 }
-func (t *TypeContainer) SetSize(sz int) {
-	t.Size = sz
+
+// typeContainerDescriber is implemented by containers that report their shape.
+type typeContainerDescriber interface {
+	GetType() thrift.TType
+	GetDesc() TypeContainerDesc
 }
-func (t *TypeContainer) GetSize() int {
-	return t.Value.Len()
+
+// NewTypeContainerOf create empty container of MAP, SET or LIST type d, elements
+// are of Go type of their thrift type, enum elements of list and set are TEnum
+// and nested containers are TypeContainerImplementer.
+func NewTypeContainerOf(d *TypeDesc, required bool) (TypeContainerImplementer, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	desc := d.ContainerDesc()
+	switch d.Type {
+	case thrift.SET:
+		if d.Value.Enum != nil {
+			return NewTypeContainerSet[TEnum](desc, required), nil
+		}
+		return NewTypeContainerSetOfTType(desc, required)
+	case thrift.LIST:
+		if d.Value.Enum != nil {
+			return NewTypeContainerList[TEnum](desc, required), nil
+		}
+		return NewTypeContainerListOfTType(desc, required)
+	case thrift.MAP:
+		return NewTypeContainerMapOfTType(desc, required)
+	}
+	return nil, fmt.Errorf("unhandled container type %s", d.Type)
 }
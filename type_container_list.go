@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
+	"iter"
 )
 
 type TypeContainerList[T Sliceable] struct {
@@ -27,15 +28,17 @@ func (t *TypeContainerList[T]) Add(vs ...T) {
 
 func (t *TypeContainerList[T]) Write(ctx context.Context, p thrift.TProtocol) (err error) {
 	size := t.GetSize()
+	elem := t.Desc.ValueDesc.ContainerDesc()
 	if err = p.WriteListBegin(ctx, t.Desc.Value, size); err != nil {
 		return
 	}
 	for i := 0; i < size; i++ {
 		if err = WriteData[T](ctx, TData[T]{
 			TDataSpec: TDataSpec{
-				Type:     t.Desc.Value,
-				Required: t.Required,
-				Protocol: p,
+				Type:      t.Desc.Value,
+				Required:  t.Required,
+				Protocol:  p,
+				Container: elem,
 			},
 			Value: &t.Value[i],
 		}); err != nil {
@@ -49,21 +52,25 @@ func (t *TypeContainerList[T]) Write(ctx context.Context, p thrift.TProtocol) (e
 }
 
 func (t *TypeContainerList[T]) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	reuse := isDecodeReuse(p)
+	elem := t.Desc.ValueDesc.ContainerDesc()
 	vv := t.Value[:0]
 	for i := 0; i < t.Size; i++ {
-		var value T
+		vv = growReuse(vv, i, reuse)
 		data := TData[T]{
 			TDataSpec: TDataSpec{
-				Type:     t.Desc.Value,
-				Required: t.Required,
-				Protocol: p,
+				Type:      t.Desc.Value,
+				Required:  t.Required,
+				Protocol:  p,
+				Container: elem,
 			},
-			Value: &value,
+			Value: &vv[i],
 		}
 		if err = ReadData(ctx, data); err != nil {
+			t.Value = partialElems(vv, i)
+			diagnoseElem(p, i, t.Size)
 			return
 		}
-		vv = append(vv, value)
 	}
 	t.Value = vv
 	return
@@ -76,6 +83,58 @@ func (t *TypeContainerList[T]) GetSize() int {
 	return len(t.Value)
 }
 
+// GetType get container type.
+func (t *TypeContainerList[T]) GetType() thrift.TType {
+	return thrift.LIST
+}
+
+// GetDesc get key and value type of container.
+func (t *TypeContainerList[T]) GetDesc() TypeContainerDesc {
+	return t.Desc
+}
+
+// All get iterator over index and element of list.
+func (t *TypeContainerList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, v := range t.Value {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// Keys get iterator over index of list elements.
+func (t *TypeContainerList[T]) Keys() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range t.Value {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// Values get iterator over element of list.
+func (t *TypeContainerList[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range t.Value {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Range call fn with index and element of list until fn returns false.
+func (t *TypeContainerList[T]) Range(fn func(k, v any) bool) {
+	for i, v := range t.Value {
+		if !fn(i, v) {
+			return
+		}
+	}
+}
+
 func NewTypeContainerListOfTType(desc TypeContainerDesc, required bool) (TypeContainerImplementer, error) {
 	switch desc.Value {
 	case thrift.BOOL:
@@ -97,5 +156,5 @@ func NewTypeContainerListOfTType(desc TypeContainerDesc, required bool) (TypeCon
 	case thrift.MAP, thrift.LIST, thrift.SET:
 		return NewTypeContainerList[TypeContainerImplementer](desc, required), nil
 	}
-	return nil, fmt.Errorf("unhandled type %T", desc.Value)
+	return nil, fmt.Errorf("unhandled element type %s", desc.Value)
 }
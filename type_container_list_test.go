@@ -72,3 +72,72 @@ func TestTypeContainer_List_Set(t *testing.T) {
 		})
 	})
 }
+
+func TestTypeContainer_ListIter(t *testing.T) {
+	l := NewTypeContainerList[string](TypeContainerDesc{Value: thrift.STRING}, false)
+	l.Add("a", "b", "c")
+	var (
+		keys   []int
+		values []string
+	)
+	for i, v := range l.All() {
+		keys = append(keys, i)
+		values = append(values, v)
+	}
+	require.Equal(t, []int{0, 1, 2}, keys)
+	require.Equal(t, []string{"a", "b", "c"}, values)
+
+	keys = keys[:0]
+	for i := range l.Keys() {
+		keys = append(keys, i)
+	}
+	require.Equal(t, []int{0, 1, 2}, keys)
+	values = values[:0]
+	for v := range l.Values() {
+		if v == "b" {
+			break
+		}
+		values = append(values, v)
+	}
+	require.Equal(t, []string{"a"}, values)
+
+	s := NewTypeContainerSet[int64](TypeContainerDesc{Value: thrift.I64}, false)
+	s.Add(7, 8)
+	var sum int64
+	for _, v := range s.All() {
+		sum += v
+	}
+	require.Equal(t, int64(15), sum)
+}
+
+func TestTypeContainer_Range(t *testing.T) {
+	l := NewTypeContainerList[string](TypeContainerDesc{Value: thrift.STRING}, false)
+	l.Add("a", "b")
+	m := NewTypeContainerMap[int64, TypeContainerImplementer](TypeContainerDesc{Key: thrift.I64, Value: thrift.LIST}, false)
+	m.AddKV(1, l)
+	u := NewTypeContainerMapUnordered[string, int32](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I32}, false)
+	u.AddKV("x", 1)
+
+	var walk func(c TypeContainerImplementer) []any
+	walk = func(c TypeContainerImplementer) (out []any) {
+		c.Range(func(k, v any) bool {
+			out = append(out, k)
+			if v, ok := v.(TypeContainerImplementer); ok {
+				out = append(out, walk(v))
+				return true
+			}
+			out = append(out, v)
+			return true
+		})
+		return
+	}
+	require.Equal(t, []any{int64(1), []any{0, "a", 1, "b"}}, walk(m))
+	require.Equal(t, []any{"x", int32(1)}, walk(u))
+
+	n := 0
+	l.Range(func(k, v any) bool {
+		n++
+		return false
+	})
+	require.Equal(t, 1, n)
+}
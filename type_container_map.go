@@ -1,10 +1,12 @@
 package thrift_dyn
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
-	"golang.org/x/exp/slices"
+	"iter"
+	"sort"
 )
 
 type TypeContainerMapItem[K comparable, V any] struct {
@@ -68,15 +70,18 @@ func (t *TypeContainerMap[K, V]) FromMapOrdered(m map[K]V) {
 	for k := range m {
 		keys = append(keys, k)
 	}
-	t.mapKeySorter(keys)
-	for _, k := range keys {
+	for _, k := range pickOrder(keys, mapKeySorter(keys, false)) {
 		v := m[k]
 		t.AddKV(k, v)
 	}
 }
 
 func (t *TypeContainerMap[K, V]) Write(ctx context.Context, p thrift.TProtocol) (err error) {
-	size := t.GetSize()
+	entries := t.Value
+	if isCanonical(p) {
+		entries = canonicalEntries(entries)
+	}
+	size := len(entries)
 	if err = p.WriteMapBegin(ctx, t.Desc.Key, t.Desc.Value, size); err != nil {
 		return
 	}
@@ -84,9 +89,10 @@ func (t *TypeContainerMap[K, V]) Write(ctx context.Context, p thrift.TProtocol)
 		Required: t.Required,
 		Protocol: p,
 	}
+	keyDesc, valueDesc := t.Desc.KeyDesc.ContainerDesc(), t.Desc.ValueDesc.ContainerDesc()
 	for i := 0; i < size; i++ {
-		item := t.Value[i]
-		spec.Type = t.Desc.Key
+		item := entries[i]
+		spec.Type, spec.Container = t.Desc.Key, keyDesc
 		if err = WriteData(ctx, TData[K]{
 			TDataSpec: spec,
 			Value:     &item.Key,
@@ -94,7 +100,7 @@ func (t *TypeContainerMap[K, V]) Write(ctx context.Context, p thrift.TProtocol)
 			return
 		}
 
-		spec.Type = t.Desc.Value
+		spec.Type, spec.Container = t.Desc.Value, valueDesc
 		if err = WriteData(ctx, TData[V]{
 			TDataSpec: spec,
 			Value:     &item.Value,
@@ -109,31 +115,35 @@ func (t *TypeContainerMap[K, V]) Write(ctx context.Context, p thrift.TProtocol)
 }
 
 func (t *TypeContainerMap[K, V]) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	reuse := isDecodeReuse(p)
 	vv := t.Value[:0]
 	spec := TDataSpec{
 		Required: t.Required,
 		Protocol: p,
 	}
+	keyDesc, valueDesc := t.Desc.KeyDesc.ContainerDesc(), t.Desc.ValueDesc.ContainerDesc()
 	for i := 0; i < t.Size; i++ {
-		var value TypeContainerMapItem[K, V]
+		vv = growReuse(vv, i, reuse)
 
-		spec.Type = t.Desc.Key
+		spec.Type, spec.Container = t.Desc.Key, keyDesc
 		if err = ReadData(ctx, TData[K]{
 			TDataSpec: spec,
-			Value:     &value.Key,
+			Value:     &vv[i].Key,
 		}); err != nil {
+			t.Value = vv[:i] // partial on error
+			diagnoseElem(p, i, t.Size)
 			return
 		}
 
-		spec.Type = t.Desc.Value
+		spec.Type, spec.Container = t.Desc.Value, valueDesc
 		if err = ReadData(ctx, TData[V]{
 			TDataSpec: spec,
-			Value:     &value.Value,
+			Value:     &vv[i].Value,
 		}); err != nil {
+			t.Value = partialEntries(vv, i)
+			diagnoseElem(p, i, t.Size)
 			return
 		}
-
-		vv = append(vv, value)
 	}
 	t.Value = vv
 	return
@@ -146,28 +156,125 @@ func (t *TypeContainerMap[K, V]) GetSize() int {
 	return len(t.Value)
 }
 
-func (t *TypeContainerMap[K, V]) mapKeySorter(keys []K) {
+// GetType get container type.
+func (t *TypeContainerMap[K, V]) GetType() thrift.TType {
+	return thrift.MAP
+}
+
+// GetDesc get key and value type of container.
+func (t *TypeContainerMap[K, V]) GetDesc() TypeContainerDesc {
+	return t.Desc
+}
+
+// All get iterator over key and value of map in wire order.
+func (t *TypeContainerMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, item := range t.Value {
+			if !yield(item.Key, item.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Keys get iterator over key of map in wire order.
+func (t *TypeContainerMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for _, item := range t.Value {
+			if !yield(item.Key) {
+				return
+			}
+		}
+	}
+}
+
+// Values get iterator over value of map in wire order.
+func (t *TypeContainerMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, item := range t.Value {
+			if !yield(item.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Range call fn with key and value of map in wire order until fn returns false.
+func (t *TypeContainerMap[K, V]) Range(fn func(k, v any) bool) {
+	for _, item := range t.Value {
+		if !fn(item.Key, item.Value) {
+			return
+		}
+	}
+}
+
+// mapKeySorter get indexes of keys in canonical order, of equal keys only the last
+// is kept if dedup, nil if keys are already in that order. It is the one ordering
+// of map keys and set elements, see FromMapOrdered and Encoder.SetCanonical.
+func mapKeySorter[K any](keys []K, dedup bool) []int {
+	compare := mapKeyCompare(keys)
+	ordered := true
+	for i := 1; i < len(keys) && ordered; i++ {
+		c := compare(i-1, i)
+		ordered = c < 0 || c == 0 && !dedup
+	}
+	if ordered {
+		return nil
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return compare(order[i], order[j]) < 0
+	})
+	if dedup {
+		out := order[:0]
+		for i, j := range order {
+			if i+1 < len(order) && compare(j, order[i+1]) == 0 {
+				continue
+			}
+			out = append(out, j)
+		}
+		order = out
+	}
+	return order
+}
+
+// mapKeyCompare get comparator of keys at two indexes: false before true, numbers
+// ascending, see compareFloat, strings bytewise, struct, container and binary keys
+// by their canonical binary encoding.
+func mapKeyCompare[K any](keys []K) func(i, j int) int {
 	switch v := (any)(keys).(type) {
 	case []bool:
-		slices.SortFunc(v, func(a, b bool) bool {
-			if a {
-				return true
-			}
-			return false
-		})
+		return func(i, j int) int { return compareBool(v[i], v[j]) }
 	case []int8:
-		slices.Sort(v)
+		return func(i, j int) int { return cmp.Compare(v[i], v[j]) }
 	case []int16:
-		slices.Sort(v)
+		return func(i, j int) int { return cmp.Compare(v[i], v[j]) }
 	case []int32:
-		slices.Sort(v)
+		return func(i, j int) int { return cmp.Compare(v[i], v[j]) }
 	case []int64:
-		slices.Sort(v)
+		return func(i, j int) int { return cmp.Compare(v[i], v[j]) }
 	case []float64:
-		slices.Sort(v)
+		return func(i, j int) int { return compareFloat(v[i], v[j]) }
 	case []string:
-		slices.Sort(v)
+		return func(i, j int) int { return cmp.Compare(v[i], v[j]) }
+	}
+	ck := make([]any, len(keys))
+	for i := range keys {
+		ck[i] = canonicalKey(keys[i])
+	}
+	return func(i, j int) int { return compareValues(ck[i], ck[j]) }
+}
+
+// newTypeContainerMapOfWire create map container of key and value type read from the wire,
+// empty map does not carry its key and value type in compact protocol.
+func newTypeContainerMapOfWire(desc TypeContainerDesc, size int, required bool) (TypeContainerImplementer, error) {
+	if size == 0 && (desc.Key == thrift.STOP || desc.Value == thrift.STOP) {
+		return NewTypeContainerMap[bool, Container](TypeContainerDesc{}, required), nil
 	}
+	return NewTypeContainerMapOfTType(desc, required)
 }
 
 func NewTypeContainerMapOfTType(desc TypeContainerDesc, required bool) (TypeContainerImplementer, error) {
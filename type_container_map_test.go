@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/stretchr/testify/require"
+	"math"
 	"testing"
 )
 
@@ -46,7 +47,7 @@ func TestTypeContainer_Map(t *testing.T) {
 
 			// try to rebuild the same data.
 
-			typ := NewTypeContainerMap[int64, int64](TypeContainerDesc{thrift.I64, thrift.I64}, true)
+			typ := NewTypeContainerMap[int64, int64](TypeContainerDesc{Key: thrift.I64, Value: thrift.I64}, true)
 			typ.SetSize(len(data))
 
 			for _, k := range order {
@@ -69,3 +70,62 @@ func TestTypeContainer_Map(t *testing.T) {
 		})
 	})
 }
+
+func TestTypeContainer_MapIter(t *testing.T) {
+	m := NewTypeContainerMap[string, int64](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I64}, false)
+	m.AddKV("b", 2)
+	m.AddKV("a", 1)
+	var keys []string
+	for k := range m.Keys() {
+		keys = append(keys, k)
+	}
+	require.Equal(t, []string{"b", "a"}, keys)
+	var values []int64
+	for v := range m.Values() {
+		values = append(values, v)
+	}
+	require.Equal(t, []int64{2, 1}, values)
+	got := map[string]int64{}
+	for k, v := range m.All() {
+		got[k] = v
+	}
+	require.Equal(t, map[string]int64{"a": 1, "b": 2}, got)
+
+	u := NewTypeContainerMapUnordered[string, int64](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I64}, false)
+	u.FromMap(got)
+	got2 := map[string]int64{}
+	for k, v := range u.All() {
+		got2[k] = v
+	}
+	require.Equal(t, got, got2)
+	n := 0
+	for range u.Keys() {
+		n++
+		break
+	}
+	require.Equal(t, 1, n)
+}
+
+func TestTypeContainer_MapFromMapOrdered(t *testing.T) {
+	b := NewTypeContainerMap[bool, int32](TypeContainerDesc{Key: thrift.BOOL, Value: thrift.I32}, false)
+	b.FromMapOrdered(map[bool]int32{true: 1, false: 0})
+	require.Equal(t, []TypeContainerMapItem[bool, int32]{{false, 0}, {true, 1}}, b.Value)
+
+	elem := func(x int64) thrift.TStruct {
+		return (&RPCStruct{}).AddField(NewTField(1, thrift.I64, "x", false).SetValue(x))
+	}
+	e1, e2, e3 := elem(1), elem(2), elem(3)
+	s := NewTypeContainerMap[thrift.TStruct, int32](TypeContainerDesc{Key: thrift.STRUCT, Value: thrift.I32}, false)
+	s.FromMapOrdered(map[thrift.TStruct]int32{e3: 3, e1: 1, e2: 2})
+	var keys []thrift.TStruct
+	for k := range s.Keys() {
+		keys = append(keys, k)
+	}
+	require.Equal(t, []thrift.TStruct{e1, e2, e3}, keys)
+
+	f := NewTypeContainerMap[float64, int32](TypeContainerDesc{Key: thrift.DOUBLE, Value: thrift.I32}, false)
+	f.FromMapOrdered(map[float64]int32{1: 1, math.NaN(): 0, -1: -1, 0: 2})
+	require.Len(t, f.Value, 4)
+	require.True(t, math.IsNaN(f.Value[0].Key))
+	require.Equal(t, []TypeContainerMapItem[float64, int32]{{-1, -1}, {0, 2}, {1, 1}}, f.Value[1:])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
+	"iter"
 )
 
 type TypeContainerMapUnordered[K comparable, V any] struct {
@@ -47,8 +48,19 @@ func (t *TypeContainerMapUnordered[K, V]) Write(ctx context.Context, p thrift.TP
 		Required: t.Required,
 		Protocol: p,
 	}
-	for k, v := range t.Value {
-		spec.Type = t.Desc.Key
+	keyDesc, valueDesc := t.Desc.KeyDesc.ContainerDesc(), t.Desc.ValueDesc.ContainerDesc()
+	entries := t.All()
+	if isCanonical(p) {
+		entries = func(yield func(K, V) bool) {
+			for _, k := range canonicalKeys(t.Value) {
+				if !yield(k, t.Value[k]) {
+					return
+				}
+			}
+		}
+	}
+	for k, v := range entries {
+		spec.Type, spec.Container = t.Desc.Key, keyDesc
 		if err = WriteData(ctx, TData[K]{
 			TDataSpec: spec,
 			Value:     &k,
@@ -56,7 +68,7 @@ func (t *TypeContainerMapUnordered[K, V]) Write(ctx context.Context, p thrift.TP
 			return
 		}
 
-		spec.Type = t.Desc.Value
+		spec.Type, spec.Container = t.Desc.Value, valueDesc
 		if err = WriteData(ctx, TData[V]{
 			TDataSpec: spec,
 			Value:     &v,
@@ -64,31 +76,47 @@ func (t *TypeContainerMapUnordered[K, V]) Write(ctx context.Context, p thrift.TP
 			return
 		}
 	}
-	return
+	return p.WriteMapEnd(ctx)
 }
 
 func (t *TypeContainerMapUnordered[K, V]) Read(ctx context.Context, p thrift.TProtocol) (err error) {
-	vv := map[K]V{} // new map header :/
+	var vv map[K]V
+	if isDecodeReuse(p) && t.Value != nil {
+		vv = t.Value
+		for k := range vv {
+			delete(vv, k)
+		}
+	} else {
+		vv = map[K]V{} // new map header :/
+	}
 	spec := TDataSpec{
 		Required: t.Required,
 		Protocol: p,
 	}
+	keyDesc, valueDesc := t.Desc.KeyDesc.ContainerDesc(), t.Desc.ValueDesc.ContainerDesc()
 	for i := 0; i < t.Size; i++ {
 		var key K
 		var value V
-		spec.Type = t.Desc.Key
+		spec.Type, spec.Container = t.Desc.Key, keyDesc
 		if err = ReadData(ctx, TData[K]{
 			TDataSpec: spec,
 			Value:     &key,
 		}); err != nil {
+			t.Value = vv // partial on error
+			diagnoseElem(p, i, t.Size)
 			return
 		}
 
-		spec.Type = t.Desc.Value
+		spec.Type, spec.Container = t.Desc.Value, valueDesc
 		if err = ReadData(ctx, TData[V]{
 			TDataSpec: spec,
 			Value:     &value,
 		}); err != nil {
+			if _, ok := any(value).(thrift.TStruct); ok {
+				vv[key] = value // partial on error
+			}
+			t.Value = vv
+			diagnoseElem(p, i, t.Size)
 			return
 		}
 		vv[key] = value
@@ -104,6 +132,58 @@ func (t *TypeContainerMapUnordered[K, V]) GetSize() int {
 	return len(t.Value)
 }
 
+// GetType get container type.
+func (t *TypeContainerMapUnordered[K, V]) GetType() thrift.TType {
+	return thrift.MAP
+}
+
+// GetDesc get key and value type of container.
+func (t *TypeContainerMapUnordered[K, V]) GetDesc() TypeContainerDesc {
+	return t.Desc
+}
+
+// All get iterator over key and value of map in no particular order.
+func (t *TypeContainerMapUnordered[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for k, v := range t.Value {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Keys get iterator over key of map in no particular order.
+func (t *TypeContainerMapUnordered[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range t.Value {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values get iterator over value of map in no particular order.
+func (t *TypeContainerMapUnordered[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range t.Value {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Range call fn with key and value of map in no particular order until fn returns false.
+func (t *TypeContainerMapUnordered[K, V]) Range(fn func(k, v any) bool) {
+	for k, v := range t.Value {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
 func NewTypeContainerMapUnorderedOfTType(desc TypeContainerDesc, required bool) (TypeContainerImplementer, error) {
 	switch desc.Key {
 	case thrift.BOOL:
@@ -46,7 +46,7 @@ func TestTypeContainer_MapUnordered(t *testing.T) {
 
 			// try to rebuild the same data.
 
-			typ := NewTypeContainerMapUnordered[int64, int64](TypeContainerDesc{thrift.I64, thrift.I64}, true)
+			typ := NewTypeContainerMapUnordered[int64, int64](TypeContainerDesc{Key: thrift.I64, Value: thrift.I64}, true)
 			typ.SetSize(len(data))
 
 			for _, k := range order {
@@ -69,3 +69,21 @@ func TestTypeContainer_MapUnordered(t *testing.T) {
 		})
 	})
 }
+
+func TestTypeContainer_MapUnorderedJSON(t *testing.T) {
+	// JSON and text protocol close the map on WriteMapEnd.
+	m := NewTypeContainerMapUnordered[string, int64](TypeContainerDesc{Key: thrift.STRING, Value: thrift.I64}, false)
+	m.AddKV("a", 1)
+	st := (&RPCStruct{}).AddField(
+		NewTField(1, thrift.MAP, "m", false).SetValue(m),
+		NewTField(2, thrift.I64, "n", false).SetValue(int64(2)),
+	)
+	for _, proto := range []ProtocolType{ProtocolType_JSON, ProtocolType_Text} {
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		bb, err := NewEncoder(pf).Encode(st)
+		require.NoError(t, err)
+		var out RPCStruct
+		require.NoError(t, NewDecoder(pf).Decode(bb, &out), "%s: %s", proto, bb)
+		require.Equal(t, int64(2), testField(&out, 2).Value)
+	}
+}
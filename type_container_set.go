@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/apache/thrift/lib/go/thrift"
+	"iter"
 )
 
 type TypeContainerSet[T Sliceable] struct {
@@ -26,18 +27,24 @@ func (t *TypeContainerSet[T]) Add(vs ...T) {
 }
 
 func (t *TypeContainerSet[T]) Write(ctx context.Context, p thrift.TProtocol) (err error) {
-	size := t.GetSize()
+	values := t.Value
+	if isCanonical(p) {
+		values = canonicalElems(values, true)
+	}
+	size := len(values)
+	elem := t.Desc.ValueDesc.ContainerDesc()
 	if err = p.WriteSetBegin(ctx, t.Desc.Value, size); err != nil {
 		return
 	}
 	for i := 0; i < size; i++ {
 		if err = WriteData[T](ctx, TData[T]{
 			TDataSpec: TDataSpec{
-				Type:     t.Desc.Value,
-				Required: t.Required,
-				Protocol: p,
+				Type:      t.Desc.Value,
+				Required:  t.Required,
+				Protocol:  p,
+				Container: elem,
 			},
-			Value: &t.Value[i],
+			Value: &values[i],
 		}); err != nil {
 			return
 		}
@@ -49,21 +56,25 @@ func (t *TypeContainerSet[T]) Write(ctx context.Context, p thrift.TProtocol) (er
 }
 
 func (t *TypeContainerSet[T]) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	reuse := isDecodeReuse(p)
+	elem := t.Desc.ValueDesc.ContainerDesc()
 	vv := t.Value[:0]
 	for i := 0; i < t.Size; i++ {
-		var value T
+		vv = growReuse(vv, i, reuse)
 		data := TData[T]{
 			TDataSpec: TDataSpec{
-				Type:     t.Desc.Value,
-				Required: t.Required,
-				Protocol: p,
+				Type:      t.Desc.Value,
+				Required:  t.Required,
+				Protocol:  p,
+				Container: elem,
 			},
-			Value: &value,
+			Value: &vv[i],
 		}
 		if err = ReadData[T](ctx, data); err != nil {
+			t.Value = partialElems(vv, i)
+			diagnoseElem(p, i, t.Size)
 			return
 		}
-		vv = append(vv, value)
 	}
 	t.Value = vv
 	return
@@ -76,6 +87,58 @@ func (t *TypeContainerSet[T]) GetSize() int {
 	return len(t.Value)
 }
 
+// GetType get container type.
+func (t *TypeContainerSet[T]) GetType() thrift.TType {
+	return thrift.SET
+}
+
+// GetDesc get key and value type of container.
+func (t *TypeContainerSet[T]) GetDesc() TypeContainerDesc {
+	return t.Desc
+}
+
+// All get iterator over index and element of set.
+func (t *TypeContainerSet[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, v := range t.Value {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// Keys get iterator over index of set elements.
+func (t *TypeContainerSet[T]) Keys() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range t.Value {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// Values get iterator over element of set.
+func (t *TypeContainerSet[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range t.Value {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Range call fn with index and element of set until fn returns false.
+func (t *TypeContainerSet[T]) Range(fn func(k, v any) bool) {
+	for i, v := range t.Value {
+		if !fn(i, v) {
+			return
+		}
+	}
+}
+
 func NewTypeContainerSetOfTType(desc TypeContainerDesc, required bool) (TypeContainerImplementer, error) {
 	switch desc.Value {
 	case thrift.BOOL:
@@ -97,5 +160,5 @@ func NewTypeContainerSetOfTType(desc TypeContainerDesc, required bool) (TypeCont
 	case thrift.MAP, thrift.LIST, thrift.SET:
 		return NewTypeContainerSet[TypeContainerImplementer](desc, required), nil
 	}
-	return nil, fmt.Errorf("unhandled type %T", desc.Value)
+	return nil, fmt.Errorf("unhandled element type %s", desc.Value)
 }
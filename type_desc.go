@@ -0,0 +1,225 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"reflect"
+	"strings"
+)
+
+// TypeDesc describes a thrift type, container and struct types are described recursively.
+type TypeDesc struct {
+	Type   thrift.TType
+	Key    *TypeDesc   // MAP key type
+	Value  *TypeDesc   // MAP value type, SET and LIST element type
+	Struct *StructDesc // STRUCT descriptor, nil if unknown
+	Enum   *EnumDesc   // I32 enum descriptor, nil if not an enum
+
+	// Name is IDL name of typedef, enum or struct the type refers to,
+	// or alias of base type such as `binary` and `i8`.
+	Name        string
+	Annotations []Annotation
+}
+
+// NewTypeDesc create new TypeDesc of base type.
+func NewTypeDesc(type_ thrift.TType) *TypeDesc {
+	return &TypeDesc{Type: type_}
+}
+
+// NewTypeDescList create new TypeDesc of list<elem>.
+func NewTypeDescList(elem *TypeDesc) *TypeDesc {
+	return &TypeDesc{Type: thrift.LIST, Value: elem}
+}
+
+// NewTypeDescSet create new TypeDesc of set<elem>.
+func NewTypeDescSet(elem *TypeDesc) *TypeDesc {
+	return &TypeDesc{Type: thrift.SET, Value: elem}
+}
+
+// NewTypeDescMap create new TypeDesc of map<key,value>.
+func NewTypeDescMap(key, value *TypeDesc) *TypeDesc {
+	return &TypeDesc{Type: thrift.MAP, Key: key, Value: value}
+}
+
+// NewTypeDescStruct create new TypeDesc of struct.
+func NewTypeDescStruct(desc *StructDesc) *TypeDesc {
+	return &TypeDesc{Type: thrift.STRUCT, Struct: desc}
+}
+
+// NewTypeDescEnum create new TypeDesc of enum.
+func NewTypeDescEnum(desc *EnumDesc) *TypeDesc {
+	return &TypeDesc{Type: thrift.I32, Enum: desc}
+}
+
+// IsContainer report whether type is MAP, SET or LIST.
+func (d *TypeDesc) IsContainer() bool {
+	switch d.Type {
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		return true
+	}
+	return false
+}
+
+// ContainerDesc get key and value type of container with their descriptors, zero if d is nil.
+func (d *TypeDesc) ContainerDesc() (desc TypeContainerDesc) {
+	if d == nil {
+		return
+	}
+	if d.Key != nil {
+		desc.Key, desc.KeyDesc = d.Key.Type, d.Key
+	}
+	if d.Value != nil {
+		desc.Value, desc.ValueDesc = d.Value.Type, d.Value
+	}
+	return
+}
+
+// String get IDL notation of the type, e.g. `map<i64,list<Model>>`.
+func (d *TypeDesc) String() string {
+	var sb strings.Builder
+	d.writeString(&sb)
+	return sb.String()
+}
+
+func (d *TypeDesc) writeString(sb *strings.Builder) {
+	if d == nil {
+		sb.WriteString("void")
+		return
+	}
+	if d.Name != "" {
+		sb.WriteString(d.Name)
+		return
+	}
+	switch d.Type {
+	case thrift.STRUCT:
+		if d.Struct != nil && d.Struct.Name != "" {
+			sb.WriteString(d.Struct.Name)
+			return
+		}
+	case thrift.I32:
+		if d.Enum != nil && d.Enum.Name != "" {
+			sb.WriteString(d.Enum.Name)
+			return
+		}
+	case thrift.MAP:
+		sb.WriteString("map<")
+		d.Key.writeString(sb)
+		sb.WriteString(",")
+		d.Value.writeString(sb)
+		sb.WriteString(">")
+		return
+	case thrift.SET, thrift.LIST:
+		sb.WriteString(textTypeName(d.Type))
+		sb.WriteString("<")
+		d.Value.writeString(sb)
+		sb.WriteString(">")
+		return
+	}
+	sb.WriteString(textTypeName(d.Type))
+}
+
+var goBaseTypes = [_BT_SIZE]reflect.Type{
+	thrift.BOOL:   reflect.TypeOf(false),
+	thrift.BYTE:   reflect.TypeOf(int8(0)),
+	thrift.I16:    reflect.TypeOf(int16(0)),
+	thrift.I32:    reflect.TypeOf(int32(0)),
+	thrift.I64:    reflect.TypeOf(int64(0)),
+	thrift.DOUBLE: reflect.TypeOf(float64(0)),
+	thrift.STRING: reflect.TypeOf(""),
+	thrift.STRUCT: reflect.TypeOf(&RPCStruct{}),
+}
+
+// GoType get native Go type of the type, e.g. `map[int64][]int32` of `map<i64,list<i32>>`,
+// as written by Encoder and by TaggedStruct. Binary is []byte, enum is TEnum, struct is
+// *RPCStruct or *RPCUnion of union descriptor. Error if map key has no comparable Go type.
+func (d *TypeDesc) GoType() (reflect.Type, error) {
+	if d == nil {
+		return nil, fmt.Errorf("missing type")
+	}
+	switch d.Type {
+	case thrift.I32:
+		if d.Enum != nil {
+			return tenumType, nil
+		}
+	case thrift.STRING:
+		if d.Name == "binary" {
+			return bytesType, nil
+		}
+	case thrift.STRUCT:
+		if d.Struct != nil && d.Struct.Kind == StructKindUnion {
+			return reflect.TypeOf(&RPCUnion{}), nil
+		}
+	case thrift.SET, thrift.LIST:
+		elem, err := d.Value.GoType()
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case thrift.MAP:
+		key, err := d.Key.GoType()
+		if err != nil {
+			return nil, err
+		}
+		// struct keys would be compared by pointer, container and binary keys are not comparable.
+		switch d.Key.Type {
+		case thrift.STRUCT, thrift.MAP, thrift.SET, thrift.LIST:
+			key = nil
+		}
+		if key == nil || key == bytesType {
+			return nil, fmt.Errorf("map key %s has no comparable Go type", d.Key)
+		}
+		value, err := d.Value.GoType()
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, value), nil
+	}
+	if int(d.Type) < len(goBaseTypes) && goBaseTypes[d.Type] != nil {
+		return goBaseTypes[d.Type], nil
+	}
+	return nil, fmt.Errorf("unhandled type %s", d.Type)
+}
+
+// TypeDescOf get type of native Go type t, the reverse of GoType, e.g. `list<i64>` of []int64.
+// Go structs are described by their `thrift` tags, see TaggedStruct, slices are lists.
+// Types implementing thrift.TStruct are structs of unknown fields.
+func TypeDescOf(t reflect.Type) (*TypeDesc, error) {
+	c, err := goCodecOf(t, false)
+	if err != nil {
+		return nil, err
+	}
+	return typeDescOfCodec(t, c, map[*taggedStructInfo]*StructDesc{}), nil
+}
+
+func typeDescOfCodec(t reflect.Type, c *taggedCodec, structs map[*taggedStructInfo]*StructDesc) *TypeDesc {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch c.ttype {
+	case thrift.SET, thrift.LIST:
+		return &TypeDesc{Type: c.ttype, Value: typeDescOfCodec(t.Elem(), c.elem, structs)}
+	case thrift.MAP:
+		return NewTypeDescMap(typeDescOfCodec(t.Key(), c.key, structs), typeDescOfCodec(t.Elem(), c.elem, structs))
+	case thrift.STRING:
+		if t.Kind() == reflect.Slice {
+			return &TypeDesc{Type: thrift.STRING, Name: "binary"}
+		}
+	case thrift.STRUCT:
+		if c.info == nil {
+			return NewTypeDesc(thrift.STRUCT)
+		}
+		if desc, ok := structs[c.info]; ok {
+			return NewTypeDescStruct(desc)
+		}
+		desc := NewStructDesc(c.info.name)
+		structs[c.info] = desc
+		for i := range c.info.fields {
+			f := &c.info.fields[i]
+			fd := NewFieldDesc(f.id, typeDescOfCodec(t.FieldByIndex(f.index).Type, f.codec, structs), f.name, f.required)
+			fd.Optional = f.optional
+			desc.AddField(fd)
+		}
+		return NewTypeDescStruct(desc)
+	}
+	return NewTypeDesc(c.ttype)
+}
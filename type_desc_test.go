@@ -0,0 +1,91 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypeDescGoType(t *testing.T) {
+	status := &EnumDesc{Name: "Status"}
+	for _, tc := range []struct {
+		desc *TypeDesc
+		typ  any
+	}{
+		{NewTypeDesc(thrift.BOOL), false},
+		{NewTypeDesc(thrift.BYTE), int8(0)},
+		{NewTypeDesc(thrift.DOUBLE), float64(0)},
+		{NewTypeDesc(thrift.STRING), ""},
+		{&TypeDesc{Type: thrift.STRING, Name: "binary"}, []byte(nil)},
+		{NewTypeDescEnum(status), TEnum{}},
+		{NewTypeDesc(thrift.STRUCT), &RPCStruct{}},
+		{NewTypeDescStruct(&StructDesc{Kind: StructKindUnion}), &RPCUnion{}},
+		{NewTypeDescList(NewTypeDesc(thrift.I64)), []int64(nil)},
+		{NewTypeDescSet(NewTypeDescEnum(status)), []TEnum(nil)},
+		{NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDescList(NewTypeDesc(thrift.I32))), map[int64][]int32(nil)},
+		{NewTypeDescMap(NewTypeDesc(thrift.STRING), NewTypeDescMap(NewTypeDesc(thrift.I16), NewTypeDesc(thrift.STRUCT))), map[string]map[int16]*RPCStruct(nil)},
+	} {
+		typ, err := tc.desc.GoType()
+		require.NoError(t, err, tc.desc)
+		require.Equal(t, reflect.TypeOf(tc.typ), typ, tc.desc)
+	}
+
+	for _, key := range []*TypeDesc{
+		NewTypeDescList(NewTypeDesc(thrift.I64)),
+		NewTypeDescSet(NewTypeDesc(thrift.I64)),
+		NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDesc(thrift.I64)),
+		NewTypeDesc(thrift.STRUCT),
+		NewTypeDescStruct(&StructDesc{Name: "Point"}),
+		{Type: thrift.STRING, Name: "binary"},
+	} {
+		_, err := NewTypeDescMap(key, NewTypeDesc(thrift.I64)).GoType()
+		require.EqualError(t, err, "map key "+key.String()+" has no comparable Go type")
+	}
+	_, err := NewTypeDescMap(NewTypeDescEnum(status), NewTypeDesc(thrift.I64)).GoType()
+	require.NoError(t, err)
+	_, err = NewTypeDescList(nil).GoType()
+	require.EqualError(t, err, "missing type")
+	_, err = NewTypeDesc(thrift.VOID).GoType()
+	require.Error(t, err)
+}
+
+func TestTypeDescOf(t *testing.T) {
+	for _, tc := range []struct {
+		typ  any
+		desc string
+	}{
+		{[]int64(nil), "list<i64>"},
+		{map[int64][]int32(nil), "map<i64,list<i32>>"},
+		{map[string]map[int16]*RPCStruct(nil), "map<string,map<i16,struct>>"},
+		{[]byte(nil), "binary"},
+		{(*base.Model)(nil), "struct"},
+	} {
+		d, err := TypeDescOf(reflect.TypeOf(tc.typ))
+		require.NoError(t, err)
+		require.Equal(t, tc.desc, d.String())
+		typ, err := d.GoType()
+		require.NoError(t, err)
+		if reflect.TypeOf(tc.typ) != reflect.TypeOf((*base.Model)(nil)) {
+			require.Equal(t, reflect.TypeOf(tc.typ), typ)
+		}
+	}
+
+	d, err := TypeDescOf(reflect.TypeOf(taggedJob{}))
+	require.NoError(t, err)
+	require.NotNil(t, d.Struct)
+	require.Equal(t, "taggedJob", d.Struct.Name)
+	parent := d.Struct.FieldByName("parent")
+	require.Same(t, d.Struct, parent.Type.Struct)
+	require.Equal(t, "set<string>", d.Struct.FieldByName("tags").Type.String())
+	require.True(t, d.Struct.FieldByName("name").Required)
+	require.True(t, d.Struct.FieldByName("retries").Optional)
+	var sb strings.Builder
+	require.NoError(t, WriteStructIDL(&sb, d.Struct))
+	require.Contains(t, sb.String(), "  4: map<string,i32> scores\n")
+
+	_, err = TypeDescOf(reflect.TypeOf([]uint32(nil)))
+	require.EqualError(t, err, "unsupported type uint32")
+}
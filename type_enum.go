@@ -0,0 +1,103 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+	"strconv"
+)
+
+var (
+	ErrInvalidEnumValue = errors.New("invalid enum value")
+)
+
+// TEnum is enum value decoded with schema, it goes over the wire as i32.
+type TEnum struct {
+	Value int32
+	Name  string    // symbolic name, empty if value is not defined by the enum
+	Enum  *EnumDesc // nil if unknown
+}
+
+// NewTEnum create new TEnum of value, name is looked up from desc.
+func NewTEnum(desc *EnumDesc, value int32) TEnum {
+	e := TEnum{Value: value, Enum: desc}
+	if desc == nil {
+		return e
+	}
+	if v := desc.ValueByNumber(value); v != nil {
+		e.Name = v.Name
+	}
+	return e
+}
+
+// String get symbolic name of enum value, or its number if not defined.
+func (e TEnum) String() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return strconv.Itoa(int(e.Value))
+}
+
+// Valid report whether value is defined by the enum.
+func (e TEnum) Valid() bool {
+	return e.Enum != nil && e.Enum.ValueByNumber(e.Value) != nil
+}
+
+// Parse get enum value of symbolic name or number, error if it is not defined by the enum.
+func (d *EnumDesc) Parse(value any) (e TEnum, err error) {
+	var n int64
+	switch v := value.(type) {
+	case TEnum:
+		n = int64(v.Value)
+	case string:
+		if ev := d.ValueByName(v); ev != nil {
+			return TEnum{Value: ev.Value, Name: ev.Name, Enum: d}, nil
+		}
+		return e, fmt.Errorf("%w %q of %s", ErrInvalidEnumValue, v, d.Name)
+	case int32:
+		n = int64(v)
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	case int16:
+		n = int64(v)
+	case int8:
+		n = int64(v)
+	default:
+		return e, fmt.Errorf("%w %T of %s", ErrInvalidEnumValue, value, d.Name)
+	}
+	if n < math.MinInt32 || n > math.MaxInt32 || d.ValueByNumber(int32(n)) == nil {
+		return e, fmt.Errorf("%w %d of %s", ErrInvalidEnumValue, n, d.Name)
+	}
+	return NewTEnum(d, int32(n)), nil
+}
+
+// writeEnum write enum value as i32, protocols meant to be read by humans
+// show its symbolic name instead.
+func writeEnum(ctx context.Context, p thrift.TProtocol, e TEnum) error {
+	if e.Name != "" {
+		inner := p
+		if ep := encodeProtocolOf(p); ep != nil {
+			inner = ep.TProtocol
+		}
+		switch p := inner.(type) {
+		case *TTextProtocol:
+			return p.writeEnum(e)
+		case *thrift.TSimpleJSONProtocol:
+			return p.WriteString(ctx, e.Name)
+		}
+	}
+	return p.WriteI32(ctx, e.Value)
+}
+
+// readEnum read i32 as value of enum desc.
+func readEnum(ctx context.Context, p thrift.TProtocol, desc *EnumDesc) (e TEnum, err error) {
+	var v int32
+	if v, err = p.ReadI32(ctx); err != nil {
+		return
+	}
+	return NewTEnum(desc, v), nil
+}
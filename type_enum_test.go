@@ -0,0 +1,114 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testEnumIDL = `
+enum Status { PENDING, RUNNING, FAILED = 5 }
+struct Job {
+  1: i64 id
+  2: Status status
+  3: list<Status> history
+  4: map<Status,i64> counts
+}
+`
+
+func TestEnum(t *testing.T) {
+	schema := MustParseIDL(testEnumIDL)
+	status := schema.Enum("Status")
+	pl, err := CompileStruct(schema.Struct("Job"))
+	require.NoError(t, err)
+
+	history := NewTypeContainerList[TEnum](TypeContainerDesc{Value: thrift.I32}, false)
+	history.Add(NewTEnum(status, 0), NewTEnum(status, 1))
+	counts := NewTypeContainerMap[TEnum, int64](TypeContainerDesc{Key: thrift.I32, Value: thrift.I64}, false)
+	counts.Value = append(counts.Value, TypeContainerMapItem[TEnum, int64]{Key: NewTEnum(status, 5), Value: 3})
+	job := (&RPCStruct{}).AddField(
+		NewTField(1, thrift.I64, "", false).SetValue(int64(1)),
+		NewTField(2, thrift.I32, "", false).SetValue("RUNNING"), // by name
+		NewTField(3, thrift.LIST, "", false).SetValue(history),
+		NewTField(4, thrift.MAP, "", false).SetValue(counts),
+	)
+
+	for _, proto := range defaultTestTProtocols {
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		bb, err := NewEncoder(pf).Encode(pl.Bind(job))
+		require.NoError(t, err, proto)
+
+		// without schema enum is i32.
+		var st RPCStruct
+		require.NoError(t, NewDecoder(pf).Decode(bb, &st), proto)
+		require.Equal(t, int32(1), testField(&st, 2).Value)
+
+		st = RPCStruct{}
+		require.NoError(t, NewDecoder(pf).Decode(bb, pl.Bind(&st)), proto)
+		require.Equal(t, TEnum{Value: 1, Name: "RUNNING", Enum: status}, testField(&st, 2).Value)
+		require.Equal(t, []TEnum{NewTEnum(status, 0), NewTEnum(status, 1)}, testField(&st, 3).Value.(*TypeContainerList[TEnum]).Value)
+		require.Equal(t, "FAILED", testField(&st, 4).Value.(*TypeContainerMap[TEnum, int64]).Value[0].Key.String())
+	}
+
+	// value undefined by the enum is decoded without name.
+	bb, err := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).
+		Encode((&RPCStruct{}).AddField(NewTField(2, thrift.I32, "", false).SetValue(int32(7))))
+	require.NoError(t, err)
+	var st RPCStruct
+	require.NoError(t, NewDecoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).Decode(bb, pl.Bind(&st)))
+	e := testField(&st, 2).Value.(TEnum)
+	require.False(t, e.Valid())
+	require.Equal(t, "7", e.String())
+}
+
+func TestEnumEncode(t *testing.T) {
+	schema := MustParseIDL(testEnumIDL)
+	pl, err := CompileStruct(schema.Struct("Job"))
+	require.NoError(t, err)
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration))
+	encode := func(value any) error {
+		_, err := enc.Encode(pl.Bind((&RPCStruct{}).AddField(NewTField(2, thrift.I32, "", false).SetValue(value))))
+		return err
+	}
+	require.NoError(t, encode("FAILED"))
+	require.NoError(t, encode(int32(5)))
+	require.NoError(t, encode(5))
+	require.NoError(t, encode(TEnum{Value: 1}))
+	for value, msg := range map[any]string{
+		"DONE":         `invalid enum value "DONE" of Status`,
+		int32(2):       "invalid enum value 2 of Status",
+		int64(1 << 40): "invalid enum value 1099511627776 of Status",
+		1.5:            "invalid enum value float64 of Status",
+	} {
+		require.ErrorContains(t, encode(value), msg)
+		_, err := schema.Enum("Status").Parse(value)
+		require.ErrorIs(t, err, ErrInvalidEnumValue)
+	}
+
+	// TEnum is validated without plan as well.
+	_, err = enc.Encode((&RPCStruct{}).AddField(NewTField(2, thrift.I32, "", false).SetValue(TEnum{Value: 2, Enum: schema.Enum("Status")})))
+	require.ErrorContains(t, err, "invalid enum value 2 of Status")
+}
+
+func TestEnumOutput(t *testing.T) {
+	schema := MustParseIDL(testEnumIDL)
+	pl, err := CompileStruct(schema.Struct("Job"))
+	require.NoError(t, err)
+	job := pl.Bind((&RPCStruct{}).AddField(NewTField(2, thrift.I32, "status", false).SetValue("FAILED")))
+
+	bb, err := NewEncoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration)).Encode(job)
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "2: i32 5 /* FAILED */  // status")
+	var st RPCStruct
+	require.NoError(t, NewDecoder(ProtocolFactory(ProtocolType_Text, defaultTestTConfiguration)).Decode(bb, pl.Bind(&st)))
+	require.Equal(t, "FAILED", testField(&st, 2).Value.(TEnum).Name)
+
+	bb, err = NewEncoder(ProtocolFactory(ProtocolType_SimpleJSON, defaultTestTConfiguration)).Encode(job)
+	require.NoError(t, err)
+	require.Equal(t, `{"status":"FAILED"}`, string(bb))
+
+	// wire protocols keep the number.
+	bb, err = NewEncoder(ProtocolFactory(ProtocolType_JSON, defaultTestTConfiguration)).Encode(job)
+	require.NoError(t, err)
+	require.Contains(t, string(bb), `{"i32":5}`)
+}
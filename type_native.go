@@ -0,0 +1,107 @@
+package thrift_dyn
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"reflect"
+	"sync"
+)
+
+type goCodecKey struct {
+	t   reflect.Type
+	set bool
+}
+
+type goCodec struct {
+	c   *taggedCodec
+	err error
+}
+
+var goCodecs sync.Map // goCodecKey -> goCodec
+
+// goCodecOf get codec of native Go type, slice of set is encoded as SET.
+// Codecs and compile errors are cached.
+func goCodecOf(t reflect.Type, set bool) (*taggedCodec, error) {
+	key := goCodecKey{t, set}
+	if v, ok := goCodecs.Load(key); ok {
+		gc := v.(goCodec)
+		return gc.c, gc.err
+	}
+	taggedStructMu.Lock()
+	defer taggedStructMu.Unlock()
+	c, err := compileTaggedCodec(t, set)
+	goCodecs.Store(key, goCodec{c, err})
+	return c, err
+}
+
+// writeGoValue write native Go value of type t, such as a named integer type,
+// []int64, map[int64]string or map[string][]int32, see TaggedStruct for the
+// mapping of Go types. Container key and element types are checked against
+// t.Container. ok is false if the value is not a Go value of type t.
+func writeGoValue(ctx context.Context, t TDataSpec, value any) (ok bool, err error) {
+	rt := reflect.TypeOf(value)
+	if rt == nil {
+		return false, nil
+	}
+	switch rt.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		// fail with the reason Go container type is not supported.
+		ok = t.Type == thrift.MAP || t.Type == thrift.SET || t.Type == thrift.LIST
+	}
+	c, err := goCodecOf(rt, t.Type == thrift.SET)
+	if err != nil {
+		if ok {
+			err = fmt.Errorf("cannot write %s as %s: %w", rt, textTypeName(t.Type), err)
+		}
+		return ok, err
+	}
+	if c.ttype != t.Type {
+		if ok {
+			return true, fmt.Errorf("cannot write %s as %s", rt, textTypeName(t.Type))
+		}
+		return false, nil
+	}
+	if !goContainerMatch(c, t.Container) {
+		return true, fmt.Errorf("cannot write %s as %s", rt, containerTypeName(t.Type, t.Container))
+	}
+	return true, c.write(ctx, t.Protocol, reflect.ValueOf(value))
+}
+
+// goContainerMatch report whether key and element type of codec match desc,
+// nested containers are checked against KeyDesc and ValueDesc, zero desc matches any.
+func goContainerMatch(c *taggedCodec, desc TypeContainerDesc) bool {
+	if c.key != nil && desc.Key != thrift.STOP && (c.key.ttype != desc.Key || !goCodecMatch(c.key, desc.KeyDesc)) {
+		return false
+	}
+	if c.elem != nil && desc.Value != thrift.STOP && (c.elem.ttype != desc.Value || !goCodecMatch(c.elem, desc.ValueDesc)) {
+		return false
+	}
+	return true
+}
+
+// goCodecMatch report whether codec is of type d, nil d matches any.
+func goCodecMatch(c *taggedCodec, d *TypeDesc) bool {
+	if d == nil {
+		return true
+	}
+	if c.ttype != d.Type {
+		return false
+	}
+	if d.IsContainer() {
+		return goContainerMatch(c, d.ContainerDesc())
+	}
+	return true
+}
+
+// containerTypeName get IDL notation of container, e.g. `map<i64,list<i32>>`,
+// or `map<i64,list>` when only top-level key and element type are known.
+func containerTypeName(t thrift.TType, desc TypeContainerDesc) string {
+	if desc.ValueDesc != nil && (t != thrift.MAP || desc.KeyDesc != nil) {
+		return (&TypeDesc{Type: t, Key: desc.KeyDesc, Value: desc.ValueDesc}).String()
+	}
+	if t == thrift.MAP {
+		return "map<" + textTypeName(desc.Key) + "," + textTypeName(desc.Value) + ">"
+	}
+	return textTypeName(t) + "<" + textTypeName(desc.Value) + ">"
+}
@@ -0,0 +1,97 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWriteDataGenericNative(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	enc := NewEncoder(pf)
+
+	m := base.MapOnly{
+		ListById:   map[int64][]int32{1: {1, 2, 3}},
+		StringById: map[int64]string{123: "hello"},
+		ModelById:  map[int64]*base.Model{886: {Abc: "x", Sd: 1}},
+	}
+	expected, err := enc.Encode(&m)
+	require.NoError(t, err)
+
+	st := (&RPCStruct{}).AddField(
+		NewTField(10, thrift.MAP, "listById", false).SetValue(m.ListById),
+		NewTField(77, thrift.MAP, "stringById", false).SetValue(m.StringById),
+		NewTField(88, thrift.MAP, "modelById", false).SetValue(m.ModelById),
+	)
+	actual, err := enc.Encode(st)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// checked against plan descriptor.
+	pl, err := CompileStruct(NewStructDesc("MapOnly",
+		NewFieldDesc(10, NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDescList(NewTypeDesc(thrift.I32))), "listById", false),
+	))
+	require.NoError(t, err)
+	actual, err = enc.Encode(pl.Bind((&RPCStruct{}).AddField(
+		NewTField(10, thrift.MAP, "listById", false).SetValue(m.ListById),
+	)))
+	require.NoError(t, err)
+	require.Equal(t, expected[:len(actual)-1], actual[:len(actual)-1])
+	_, err = enc.Encode(pl.Bind((&RPCStruct{}).AddField(
+		NewTField(10, thrift.MAP, "listById", false).SetValue(map[string][]int32{"a": {1}}),
+	)))
+	require.ErrorContains(t, err, "cannot write map[string][]int32 as map<i64,list<i32>>")
+	_, err = enc.Encode(pl.Bind((&RPCStruct{}).AddField(
+		NewTField(10, thrift.MAP, "listById", false).SetValue(map[int64][]int64{1: {1}}),
+	)))
+	require.ErrorContains(t, err, "cannot write map[int64][]int64 as map<i64,list<i32>>") // nested mismatch
+	_, err = enc.Encode(pl.Bind((&RPCStruct{}).AddField(
+		NewTField(10, thrift.MAP, "listById", false).SetValue(map[int64][][]int32{1: {{1}}}),
+	)))
+	require.ErrorContains(t, err, "cannot write map[int64][][]int32 as map<i64,list<i32>>")
+
+	// keys are written in order, slices as set.
+	type status int32
+	st = (&RPCStruct{}).AddField(
+		NewTField(1, thrift.MAP, "m", false).SetValue(map[string]int64{"b": 2, "a": 1}),
+		NewTField(2, thrift.SET, "s", false).SetValue([]string{"x"}),
+		NewTField(3, thrift.I32, "status", false).SetValue(status(5)),
+	)
+	bb, err := enc.Encode(st)
+	require.NoError(t, err)
+	var st2 RPCStruct
+	require.NoError(t, NewDecoder(pf).Decode(bb, &st2))
+	entries, err := testField(&st2, 1).GetMap()
+	require.NoError(t, err)
+	require.Equal(t, []MapEntry{{"a", int64(1)}, {"b", int64(2)}}, entries)
+	require.Equal(t, thrift.TType(thrift.SET), testField(&st2, 2).Type)
+	require.Equal(t, int32(5), testField(&st2, 3).Value)
+
+	_, err = enc.Encode((&RPCStruct{}).AddField(NewTField(1, thrift.LIST, "l", false).SetValue([]uint32{1})))
+	require.ErrorContains(t, err, "cannot write []uint32 as list: unsupported type uint32")
+	_, err = enc.Encode((&RPCStruct{}).AddField(NewTField(1, thrift.LIST, "l", false).SetValue(map[int64]int64{})))
+	require.ErrorContains(t, err, "cannot write map[int64]int64 as list")
+}
+
+func TestGoCodecCache(t *testing.T) {
+	typ := reflect.TypeOf(map[string][]uint32(nil))
+	_, err := goCodecOf(typ, false)
+	require.Error(t, err)
+	v, ok := goCodecs.Load(goCodecKey{typ, false})
+	require.True(t, ok, "compile error is cached")
+	require.Equal(t, err, v.(goCodec).err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := NewEncoder(ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)).Encode(taggedJob{Name: "x"})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
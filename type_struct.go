@@ -20,11 +20,15 @@ func (s *RPCStruct) AddField(fs ...*TField) *RPCStruct {
 // Write writes fields to the wire
 func (s *RPCStruct) Write(ctx context.Context, p thrift.TProtocol) (err error) {
 	var fieldId TFieldID
+	fields := s.Fields
+	if isCanonical(p) {
+		fields = canonicalFields(fields)
+	}
 	if err = p.WriteStructBegin(ctx, s.Name); err != nil {
 		goto WriteStructBeginError
 	}
 
-	for _, field := range s.Fields {
+	for _, field := range fields {
 		fieldId = field.GetID()
 		if err = field.Write(ctx, p); err != nil {
 			goto WriteFieldError
@@ -39,13 +43,13 @@ func (s *RPCStruct) Write(ctx context.Context, p thrift.TProtocol) (err error) {
 	}
 	return nil
 WriteStructBeginError:
-	return thrift.PrependError(fmt.Sprintf("%T write struct begin error: ", s.Name), err)
+	return thrift.PrependError(fmt.Sprintf("%s write struct begin error: ", s.Name), err)
 WriteFieldError:
-	return thrift.PrependError(fmt.Sprintf("%T write field %d error: ", p, fieldId), err)
+	return thrift.PrependError(fmt.Sprintf("%s write field %d error: ", s.Name, fieldId), err)
 WriteFieldStopError:
-	return thrift.PrependError(fmt.Sprintf("%T write field stop error: ", s.Name), err)
+	return thrift.PrependError(fmt.Sprintf("%s write field stop error: ", s.Name), err)
 WriteStructEndError:
-	return thrift.PrependError(fmt.Sprintf("%T write struct end error: ", s.Name), err)
+	return thrift.PrependError(fmt.Sprintf("%s write struct end error: ", s.Name), err)
 }
 
 // Read reads fields from wire
@@ -54,12 +58,17 @@ func (s *RPCStruct) Read(ctx context.Context, p thrift.TProtocol) (err error) {
 		fieldName   string
 		fieldTypeId thrift.TType
 		fieldId     TFieldID
+		field       *TField
+		old         = s.Fields
 		vv          = s.Fields[:0]
+		reuse       = isDecodeReuse(p)
+		depth       int
 	)
 
 	if _, err = p.ReadStructBegin(ctx); err != nil {
 		goto ReadStructBeginError
 	}
+	depth = decodeDepth(p)
 
 	for {
 		fieldName, fieldTypeId, fieldId, err = p.ReadFieldBegin(ctx)
@@ -70,13 +79,25 @@ func (s *RPCStruct) Read(ctx context.Context, p thrift.TProtocol) (err error) {
 			break
 		}
 
-		var field = NewTField(fieldId, fieldTypeId, fieldName, false)
+		if reuse && len(vv) < len(old) && old[len(vv)] != nil {
+			field = old[len(vv)]
+			field.recycle(fieldId, fieldTypeId, fieldName)
+		} else {
+			field = NewTField(fieldId, fieldTypeId, fieldName, false)
+		}
 		if err = field.Read(ctx, p); errors.Is(err, ErrSkipField) {
 			if err = p.Skip(ctx, fieldTypeId); err != nil {
 				goto SkipFieldError
 			}
 		} else if err != nil {
-			goto ReadFieldError
+			if !recoverField(p, depth, field, err) {
+				goto ReadFieldError
+			}
+			// invalid value is skipped in best-effort mode.
+			if err = p.ReadFieldEnd(ctx); err != nil {
+				goto ReadFieldEndError
+			}
+			continue
 		}
 
 		if err = p.ReadFieldEnd(ctx); err != nil {
@@ -93,15 +114,30 @@ func (s *RPCStruct) Read(ctx context.Context, p thrift.TProtocol) (err error) {
 
 	return nil
 ReadStructBeginError:
+	s.Fields = vv
+	diagnoseField(p, 0, nil, err)
 	return thrift.PrependError(fmt.Sprintf("%s read struct begin error: ", s.Name), err)
 ReadFieldBeginError:
+	s.Fields = vv
+	diagnoseField(p, 0, nil, err)
 	return thrift.PrependError(fmt.Sprintf("%s read field %d begin error: ", s.Name, fieldId), err)
 ReadFieldError:
+	// keep partially read value for best-effort decoding.
+	if _, ok := field.Value.(thrift.TStruct); ok {
+		vv = append(vv, field)
+	}
+	s.Fields = vv
+	diagnoseField(p, fieldId, field, err)
 	return thrift.PrependError(fmt.Sprintf("%s read field %d '%s' (%d) error: ", s.Name, fieldId, fieldName, fieldTypeId), err)
 SkipFieldError:
+	s.Fields = vv
+	diagnoseField(p, fieldId, field, err)
 	return thrift.PrependError(fmt.Sprintf("%s field %d skip type %d error: ", s.Name, fieldId, fieldTypeId), err)
 ReadFieldEndError:
+	s.Fields = append(vv, field)
+	diagnoseField(p, 0, nil, err)
 	return thrift.PrependError(fmt.Sprintf("%s read field end error", s.Name), err)
 ReadStructEndError:
+	diagnoseField(p, 0, nil, err)
 	return thrift.PrependError(fmt.Sprintf("%s read struct end error: ", s.Name), err)
 }
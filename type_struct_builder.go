@@ -0,0 +1,401 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+	"reflect"
+)
+
+// StructBuilder build RPCStruct field by field, e.g.
+//
+//	st, err := NewStruct("Model").
+//		String(1, "abc", "hello").
+//		I64(4, "sd", 0xcafe).
+//		List(10, "listI64", thrift.I64, 1, 2, 3).
+//		Build()
+//
+// Values are converted to the Go type decoded for the field type, containers
+// are built as TypeContainerList, TypeContainerSet and TypeContainerMap.
+// The first error is kept and returned by Build.
+type StructBuilder struct {
+	st   *RPCStruct
+	desc *StructDesc
+	err  error
+}
+
+// NewStruct create new StructBuilder of struct name.
+func NewStruct(name string) *StructBuilder {
+	return &StructBuilder{st: &RPCStruct{Name: name}}
+}
+
+// NewStructOf create new StructBuilder of schema struct, fields are added by name
+// with Put, field ID, type and requiredness are taken from desc.
+func NewStructOf(desc *StructDesc) *StructBuilder {
+	return &StructBuilder{st: &RPCStruct{Name: desc.Name}, desc: desc}
+}
+
+// Build get built struct, error of the first field that failed to build if any.
+func (b *StructBuilder) Build() (*RPCStruct, error) {
+	return b.st, b.err
+}
+
+// MustBuild get built struct, panic on error.
+func (b *StructBuilder) MustBuild() *RPCStruct {
+	if b.err != nil {
+		panic(b.err)
+	}
+	return b.st
+}
+
+// Required mark last added field required.
+func (b *StructBuilder) Required() *StructBuilder {
+	if n := len(b.st.Fields); n > 0 {
+		b.st.Fields[n-1].Required = true
+	}
+	return b
+}
+
+// Field add field of type t, value is converted as described by t.
+func (b *StructBuilder) Field(id TFieldID, name string, t *TypeDesc, value any) *StructBuilder {
+	if b.err != nil {
+		return b
+	}
+	required := false
+	if b.desc != nil {
+		if f := b.desc.FieldByID(id); f != nil {
+			if f.Type.Type != t.Type {
+				b.err = fmt.Errorf("%s: field %d '%s': %s is not %s", b.st.Name, id, name, t, f.Type)
+				return b
+			}
+			t, required = f.Type, f.Required
+		}
+	}
+	v, err := buildValue(t, value)
+	if err != nil {
+		b.err = fmt.Errorf("%s: field %d '%s': %w", b.st.Name, id, name, err)
+		return b
+	}
+	b.st.AddField(NewTField(id, t.Type, name, required).SetValue(v))
+	return b
+}
+
+// Put add field of schema struct by name, see NewStructOf.
+func (b *StructBuilder) Put(name string, value any) *StructBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.desc == nil {
+		b.err = fmt.Errorf("%s: put field '%s' without schema", b.st.Name, name)
+		return b
+	}
+	f := b.desc.FieldByName(name)
+	if f == nil {
+		b.err = fmt.Errorf("%s has no field '%s'", b.st.Name, name)
+		return b
+	}
+	return b.Field(f.ID, f.Name, f.Type, value)
+}
+
+// Bool add BOOL field.
+func (b *StructBuilder) Bool(id TFieldID, name string, v bool) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.BOOL), v)
+}
+
+// Byte add BYTE field.
+func (b *StructBuilder) Byte(id TFieldID, name string, v int8) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.BYTE), v)
+}
+
+// I16 add I16 field.
+func (b *StructBuilder) I16(id TFieldID, name string, v int16) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.I16), v)
+}
+
+// I32 add I32 field.
+func (b *StructBuilder) I32(id TFieldID, name string, v int32) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.I32), v)
+}
+
+// I64 add I64 field.
+func (b *StructBuilder) I64(id TFieldID, name string, v int64) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.I64), v)
+}
+
+// Double add DOUBLE field.
+func (b *StructBuilder) Double(id TFieldID, name string, v float64) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.DOUBLE), v)
+}
+
+// String add STRING field of string value.
+func (b *StructBuilder) String(id TFieldID, name string, v string) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.STRING), v)
+}
+
+// Binary add STRING field of []byte value.
+func (b *StructBuilder) Binary(id TFieldID, name string, v []byte) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.STRING), v)
+}
+
+// Struct add STRUCT field, v is thrift.TStruct or *StructBuilder.
+func (b *StructBuilder) Struct(id TFieldID, name string, v any) *StructBuilder {
+	return b.Field(id, name, NewTypeDesc(thrift.STRUCT), v)
+}
+
+// List add LIST field of elements of type elem, vs is the elements or a single
+// slice of them, e.g. `1, 2, 3` or `[]int64{1, 2, 3}`.
+func (b *StructBuilder) List(id TFieldID, name string, elem thrift.TType, vs ...any) *StructBuilder {
+	return b.Field(id, name, NewTypeDescList(NewTypeDesc(elem)), builderElems(elem, vs))
+}
+
+// Set add SET field of elements of type elem, vs is as described by List.
+func (b *StructBuilder) Set(id TFieldID, name string, elem thrift.TType, vs ...any) *StructBuilder {
+	return b.Field(id, name, NewTypeDescSet(NewTypeDesc(elem)), builderElems(elem, vs))
+}
+
+// Map add MAP field of key and value type, kvs is key and value pairs in order, e.g. `1, 2, 3, 4`,
+// or a single Go map or []MapEntry, e.g. `map[int64]int64{1: 2, 3: 4}`.
+func (b *StructBuilder) Map(id TFieldID, name string, key, value thrift.TType, kvs ...any) *StructBuilder {
+	desc := NewTypeDescMap(NewTypeDesc(key), NewTypeDesc(value))
+	if len(kvs) == 1 {
+		switch v := kvs[0].(type) {
+		case []MapEntry, TypeContainerImplementer:
+			return b.Field(id, name, desc, v)
+		}
+		if reflect.ValueOf(kvs[0]).Kind() == reflect.Map {
+			return b.Field(id, name, desc, kvs[0])
+		}
+	}
+	if len(kvs)%2 != 0 {
+		if b.err == nil {
+			b.err = fmt.Errorf("%s: field %d '%s': odd number of map keys and values", b.st.Name, id, name)
+		}
+		return b
+	}
+	entries := make([]MapEntry, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		entries = append(entries, MapEntry{Key: kvs[i], Value: kvs[i+1]})
+	}
+	return b.Field(id, name, desc, entries)
+}
+
+// builderElems get elements of List and Set, a single slice or container is used as is,
+// but not a []byte element of STRING or a nested container element.
+func builderElems(elem thrift.TType, vs []any) any {
+	if len(vs) != 1 {
+		return vs
+	}
+	switch elem {
+	case thrift.LIST, thrift.SET, thrift.MAP:
+		return vs
+	case thrift.STRING:
+		if _, ok := vs[0].([]byte); ok {
+			return vs
+		}
+	}
+	if _, ok := vs[0].(TypeContainerImplementer); ok {
+		return vs[0]
+	}
+	if k := reflect.ValueOf(vs[0]).Kind(); k == reflect.Slice || k == reflect.Array {
+		return vs[0]
+	}
+	return vs
+}
+
+// buildValue convert value to Go type decoded for type t.
+// Field value of STRING type keeps []byte, container elements are string.
+func buildValue(t *TypeDesc, value any) (any, error) {
+	return buildValueOf(t, value, false)
+}
+
+func buildValueOf(t *TypeDesc, value any, elem bool) (any, error) {
+	if value == nil {
+		return nil, fmt.Errorf("nil %s value", t)
+	}
+	switch t.Type {
+	case thrift.BOOL:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case thrift.BYTE, thrift.I16, thrift.I32, thrift.I64:
+		if t.Enum != nil {
+			return t.Enum.Parse(value)
+		}
+		if v, ok := value.(TEnum); ok && t.Type == thrift.I32 {
+			return v, nil
+		}
+		n, ok := buildInt(value)
+		if !ok {
+			break
+		}
+		switch t.Type {
+		case thrift.BYTE:
+			if n >= math.MinInt8 && n <= math.MaxInt8 {
+				return int8(n), nil
+			}
+		case thrift.I16:
+			if n >= math.MinInt16 && n <= math.MaxInt16 {
+				return int16(n), nil
+			}
+		case thrift.I32:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int32(n), nil
+			}
+		default:
+			return n, nil
+		}
+		return nil, fmt.Errorf("%d overflows %s", n, t)
+	case thrift.DOUBLE:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		}
+		if n, ok := buildInt(value); ok {
+			return float64(n), nil
+		}
+	case thrift.STRING:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			if elem {
+				return string(v), nil
+			}
+			return v, nil
+		}
+	case thrift.STRUCT:
+		switch v := value.(type) {
+		case *StructBuilder:
+			return v.Build()
+		case thrift.TStruct:
+			return v, nil
+		case map[string]any:
+			if t.Struct != nil {
+				return buildStruct(t.Struct, v)
+			}
+		}
+	case thrift.LIST, thrift.SET:
+		return buildList(t, value)
+	case thrift.MAP:
+		return buildMap(t, value)
+	}
+	return nil, fmt.Errorf("invalid %s value %v (%T)", t, value, value)
+}
+
+// buildStruct build struct of desc from field values by name, in order of desc fields.
+func buildStruct(desc *StructDesc, values map[string]any) (*RPCStruct, error) {
+	b := NewStructOf(desc)
+	for _, f := range desc.Fields {
+		if v, ok := values[f.Name]; ok {
+			b.Put(f.Name, v)
+		}
+	}
+	if b.err == nil && len(b.st.Fields) != len(values) {
+		for name := range values {
+			if desc.FieldByName(name) == nil {
+				return nil, fmt.Errorf("%s has no field '%s'", desc.Name, name)
+			}
+		}
+	}
+	return b.Build()
+}
+
+// buildInt get integer of any Go integer kind, false if out of int64 range.
+func buildInt(value any) (int64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := rv.Uint(); n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// buildContainer check container value is of type t.
+func buildContainer(t *TypeDesc, value any) (TypeContainerImplementer, bool) {
+	c, ok := value.(TypeContainerImplementer)
+	if !ok {
+		return nil, false
+	}
+	d, ok := c.(typeContainerDescriber)
+	return c, ok && d.GetType() == t.Type && d.GetDesc().sameWire(t.ContainerDesc())
+}
+
+// buildList build list or set of type t from container of the same type or a slice.
+func buildList(t *TypeDesc, value any) (TypeContainerImplementer, error) {
+	if c, ok := buildContainer(t, value); ok {
+		return c, nil
+	}
+	rv := reflect.ValueOf(value)
+	if k := rv.Kind(); k != reflect.Slice && k != reflect.Array {
+		return nil, fmt.Errorf("invalid %s value %v (%T)", t, value, value)
+	}
+	c, err := NewTypeContainerOf(t, false)
+	if err != nil {
+		return nil, err
+	}
+	vv := reflect.ValueOf(c).Elem().FieldByName("Value")
+	for i := 0; i < rv.Len(); i++ {
+		v, err := buildValueOf(t.Value, rv.Index(i).Interface(), true)
+		if err != nil {
+			return nil, fmt.Errorf("element [%d]: %w", i, err)
+		}
+		vv.Set(reflect.Append(vv, buildElem(vv.Type().Elem(), v)))
+	}
+	return c, nil
+}
+
+// buildMap build map of type t from container of the same type, []MapEntry or a Go map.
+// Go map entries are ordered by key.
+func buildMap(t *TypeDesc, value any) (TypeContainerImplementer, error) {
+	if c, ok := buildContainer(t, value); ok {
+		return c, nil
+	}
+	entries, ok := value.([]MapEntry)
+	if !ok {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Map {
+			return nil, fmt.Errorf("invalid %s value %v (%T)", t, value, value)
+		}
+		var keys []any
+		iter := rv.MapRange()
+		for iter.Next() {
+			keys = append(keys, iter.Key().Interface())
+			entries = append(entries, MapEntry{Key: iter.Key().Interface(), Value: iter.Value().Interface()})
+		}
+		entries = pickOrder(entries, mapKeySorter(keys, false))
+	}
+	c, err := NewTypeContainerOf(t, false)
+	if err != nil {
+		return nil, err
+	}
+	vv := reflect.ValueOf(c).Elem().FieldByName("Value")
+	for _, e := range entries {
+		key, err := buildValueOf(t.Key, e.Key, true)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", e.Key, err)
+		}
+		v, err := buildValueOf(t.Value, e.Value, true)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", e.Key, err)
+		}
+		item := reflect.New(vv.Type().Elem()).Elem()
+		item.Field(0).Set(buildElem(item.Field(0).Type(), key))
+		item.Field(1).Set(buildElem(item.Field(1).Type(), v))
+		vv.Set(reflect.Append(vv, item))
+	}
+	return c, nil
+}
+
+// buildElem get container element of Go type t, enum element of i32 container is its number.
+func buildElem(t reflect.Type, v any) reflect.Value {
+	if e, ok := v.(TEnum); ok && t.Kind() == reflect.Int32 {
+		return reflect.ValueOf(e.Value)
+	}
+	return reflect.ValueOf(v)
+}
@@ -0,0 +1,103 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStructBuilder(t *testing.T) {
+	st, err := NewStruct("Model").
+		String(1, "abc", "hello").Required().
+		I64(4, "sd", 0xcafe).
+		List(10, "listI64", thrift.I64, 1, 2, 3).
+		Set(13, "tags", thrift.STRING, "a", []byte("b")).
+		Map(11, "mapI64", thrift.I64, thrift.I64, 1, 2, 3, 4).
+		Struct(12, "sub", NewStruct("Sub").Bool(1, "ok", true)).
+		Build()
+	require.NoError(t, err)
+	require.True(t, testField(st, 1).Required)
+	require.Equal(t, int64(0xcafe), testField(st, 4).Value)
+	require.Equal(t, []int64{1, 2, 3}, testField(st, 10).Value.(*TypeContainerList[int64]).Value)
+	require.Equal(t, []string{"a", "b"}, testField(st, 13).Value.(*TypeContainerSet[string]).Value)
+	require.Equal(t, []TypeContainerMapItem[int64, int64]{{1, 2}, {3, 4}},
+		testField(st, 11).Value.(*TypeContainerMap[int64, int64]).Value)
+	require.Equal(t, true, testField(testField(st, 12).Value.(*RPCStruct), 1).Value)
+
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(st)
+	require.NoError(t, err)
+	var st2 RPCStruct
+	require.NoError(t, NewDecoder(pf).Decode(bb, &st2))
+	require.Equal(t, []int64{1, 2, 3}, testField(&st2, 10).Value.(*TypeContainerList[int64]).Value)
+
+	// a single slice or map holds the elements.
+	st, err = NewStruct("Model").
+		List(10, "listI64", thrift.I64, []int64{1, 2, 3}).
+		Set(13, "tags", thrift.STRING, []byte("a")).
+		Set(14, "bytes", thrift.BYTE, []byte{1, 2}).
+		Map(11, "mapI64", thrift.I64, thrift.I64, map[int64]int64{3: 4, 1: 2}).
+		Build()
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3}, testField(st, 10).Value.(*TypeContainerList[int64]).Value)
+	require.Equal(t, []string{"a"}, testField(st, 13).Value.(*TypeContainerSet[string]).Value)
+	require.Equal(t, []int8{1, 2}, testField(st, 14).Value.(*TypeContainerSet[int8]).Value)
+	require.Equal(t, []TypeContainerMapItem[int64, int64]{{1, 2}, {3, 4}},
+		testField(st, 11).Value.(*TypeContainerMap[int64, int64]).Value)
+
+	_, err = NewStruct("Model").List(10, "listI64", thrift.BYTE, 1, 300).Build()
+	require.EqualError(t, err, "Model: field 10 'listI64': element [1]: 300 overflows byte")
+	_, err = NewStruct("Model").Map(11, "mapI64", thrift.I64, thrift.I64, 1).Build()
+	require.EqualError(t, err, "Model: field 11 'mapI64': odd number of map keys and values")
+	_, err = NewStruct("Model").Struct(12, "sub", NewStruct("Sub").I32(1, "x", 1).Put("y", 1)).Build()
+	require.EqualError(t, err, "Model: field 12 'sub': Sub: put field 'y' without schema")
+}
+
+func TestStructBuilderSchema(t *testing.T) {
+	schema := MustParseIDL(`
+enum Status { OK = 0, FAILED = 5 }
+struct Sub { 1: bool ok }
+struct Job {
+  1: required string name
+  2: Status status
+  3: list<list<i16>> matrix
+  4: map<string,Sub> subs
+  5: set<Status> history
+}
+`)
+	job, err := NewStructOf(schema.Struct("Job")).
+		Put("name", "build").
+		Put("status", "FAILED").
+		Put("matrix", [][]int{{1, 2}, {3}}).
+		Put("subs", map[string]any{"b": map[string]any{"ok": true}, "a": NewStructOf(schema.Struct("Sub"))}).
+		Put("history", []any{0, "FAILED"}).
+		Build()
+	require.NoError(t, err)
+	require.True(t, testField(job, 1).Required)
+	require.Equal(t, "FAILED", testField(job, 2).Value.(TEnum).Name)
+	matrix := testField(job, 3).Value.(*TypeContainerList[TypeContainerImplementer]).Value
+	require.Equal(t, []int16{1, 2}, matrix[0].(*TypeContainerList[int16]).Value)
+	subs := testField(job, 4).Value.(*TypeContainerMap[string, thrift.TStruct]).Value
+	require.Equal(t, "a", subs[0].Key)
+	require.Equal(t, true, testField(subs[1].Value.(*RPCStruct), 1).Value)
+	history := testField(job, 5).Value.(*TypeContainerSet[TEnum]).Value
+	require.Equal(t, "FAILED", history[1].Name)
+
+	plan, err := CompileStruct(schema.Struct("Job"))
+	require.NoError(t, err)
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	bb, err := NewEncoder(pf).Encode(plan.Bind(job))
+	require.NoError(t, err)
+	var st RPCStruct
+	require.NoError(t, NewDecoder(pf).Decode(bb, plan.Bind(&st)))
+	require.Equal(t, "FAILED", testField(&st, 2).Value.(TEnum).Name)
+
+	_, err = NewStructOf(schema.Struct("Job")).Put("status", "UNKNOWN").Build()
+	require.ErrorIs(t, err, ErrInvalidEnumValue)
+	_, err = NewStructOf(schema.Struct("Job")).Put("title", "x").Build()
+	require.EqualError(t, err, "Job has no field 'title'")
+	_, err = NewStructOf(schema.Struct("Job")).I64(1, "name", 1).Build()
+	require.EqualError(t, err, "Job: field 1 'name': i64 is not string")
+	_, err = NewStructOf(schema.Struct("Job")).Put("subs", map[string]any{"a": map[string]any{"no": 1}}).Build()
+	require.EqualError(t, err, "Job: field 4 'subs': key a: Sub has no field 'no'")
+}
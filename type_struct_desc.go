@@ -0,0 +1,125 @@
+package thrift_dyn
+
+import (
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// StructKind is kind of struct declared in IDL.
+type StructKind uint8
+
+const (
+	StructKindStruct StructKind = iota
+	StructKindUnion
+	StructKindException
+)
+
+func (k StructKind) String() string {
+	switch k {
+	case StructKindUnion:
+		return "union"
+	case StructKindException:
+		return "exception"
+	}
+	return "struct"
+}
+
+// StructDesc describes fields of a struct.
+type StructDesc struct {
+	Name   string
+	Fields []*FieldDesc
+
+	Kind        StructKind
+	Comment     string
+	Annotations []Annotation
+}
+
+// FieldDesc describes a struct field.
+type FieldDesc struct {
+	ID       TFieldID
+	Name     string
+	Type     *TypeDesc
+	Required bool
+	Optional bool // declared optional in IDL, neither required nor optional means default
+
+	Default      string // default value literal as written in IDL, empty if none
+	DefaultValue any    // default value evaluated, see EvalConst; Default is evaluated if nil
+	Comment      string
+	Trailing     string // comment on the same line
+	Annotations  []Annotation
+}
+
+// NewStructDesc create new StructDesc.
+func NewStructDesc(name string, fs ...*FieldDesc) *StructDesc {
+	return &StructDesc{Name: name, Fields: fs}
+}
+
+// NewFieldDesc create new FieldDesc.
+func NewFieldDesc(id TFieldID, type_ *TypeDesc, name string, required bool) *FieldDesc {
+	return &FieldDesc{ID: id, Name: name, Type: type_, Required: required}
+}
+
+func (s *StructDesc) AddField(fs ...*FieldDesc) *StructDesc {
+	s.Fields = append(s.Fields, fs...)
+	return s
+}
+
+// FieldByID get field descriptor by field ID, nil if not found.
+func (s *StructDesc) FieldByID(id TFieldID) *FieldDesc {
+	for _, f := range s.Fields {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// FieldByName get field descriptor by field name, nil if not found.
+func (s *StructDesc) FieldByName(name string) *FieldDesc {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Validate check field IDs are unique and field types are complete.
+func (s *StructDesc) Validate() error {
+	seen := map[TFieldID]bool{}
+	for _, f := range s.Fields {
+		if seen[f.ID] {
+			return fmt.Errorf("%s: duplicate field id %d", s.Name, f.ID)
+		}
+		seen[f.ID] = true
+		if err := f.Type.validate(); err != nil {
+			return fmt.Errorf("%s: field %d '%s': %w", s.Name, f.ID, f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *TypeDesc) validate() error {
+	if d == nil {
+		return fmt.Errorf("missing type")
+	}
+	switch d.Type {
+	case thrift.MAP:
+		if d.Key == nil || d.Value == nil {
+			return fmt.Errorf("map without key or value type")
+		}
+		if err := d.Key.validate(); err != nil {
+			return err
+		}
+		return d.Value.validate()
+	case thrift.SET, thrift.LIST:
+		if d.Value == nil {
+			return fmt.Errorf("%s without element type", textTypeName(d.Type))
+		}
+		return d.Value.validate()
+	case thrift.BOOL, thrift.BYTE, thrift.I16, thrift.I32, thrift.I64,
+		thrift.DOUBLE, thrift.STRING, thrift.STRUCT:
+		return nil
+	}
+	return fmt.Errorf("unhandled type %s", d.Type)
+}
@@ -1,9 +1,11 @@
 package thrift_dyn
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"github.com/apache/thrift/lib/go/thrift"
+	"math"
 )
 
 var (
@@ -62,6 +64,11 @@ func (f *TField) Write(ctx context.Context, p thrift.TProtocol) (err error) {
 
 // Read read struct field with its value.
 func (f *TField) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	if isDecodeReuse(p) {
+		if ok, err := f.readReused(ctx, p); ok {
+			return err
+		}
+	}
 	spec := TDataSpec{
 		Type:     f.Type,
 		Required: f.Required,
@@ -74,6 +81,82 @@ func (f *TField) Read(ctx context.Context, p thrift.TProtocol) (err error) {
 	return
 }
 
+// readReused read scalar and STRING value, previous value is kept when the
+// payload matches it so that steady-state decoding does not box values again,
+// ok is false for types that are not read here.
+func (f *TField) readReused(ctx context.Context, p thrift.TProtocol) (ok bool, err error) {
+	switch f.Type {
+	case thrift.BOOL:
+		var v bool
+		v, err = p.ReadBool(ctx)
+		f.Value = reuseValue(f.Value, v, err)
+	case thrift.BYTE:
+		var v int8
+		v, err = p.ReadByte(ctx)
+		f.Value = reuseValue(f.Value, v, err)
+	case thrift.I16:
+		var v int16
+		v, err = p.ReadI16(ctx)
+		f.Value = reuseValue(f.Value, v, err)
+	case thrift.I32:
+		var v int32
+		v, err = p.ReadI32(ctx)
+		f.Value = reuseValue(f.Value, v, err)
+	case thrift.I64:
+		var v int64
+		v, err = p.ReadI64(ctx)
+		f.Value = reuseValue(f.Value, v, err)
+	case thrift.DOUBLE:
+		var v float64
+		v, err = p.ReadDouble(ctx)
+		if old, ok := f.Value.(float64); err == nil && (!ok || math.Float64bits(old) != math.Float64bits(v)) {
+			f.Value = v
+		}
+	case thrift.STRING:
+		dp := decodeProtocolOf(p)
+		if old, ok := f.Value.(string); ok {
+			var v string
+			if v, err = dp.readString(ctx, old); err == nil {
+				f.Value = v
+			}
+			return true, err
+		}
+		if dp.zeroCopy {
+			return false, nil
+		}
+		if dp.scratch, err = readBinaryInto(ctx, p, dp.scratch[:0]); err != nil {
+			return true, err
+		}
+		if old, ok := f.Value.([]byte); !ok || !bytes.Equal(old, dp.scratch) {
+			f.Value = append([]byte{}, dp.scratch...)
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// reuseValue get old if it holds v, v otherwise, old is kept on read error.
+func reuseValue[T comparable](old any, v T, err error) any {
+	if err != nil {
+		return old
+	}
+	if o, ok := old.(T); ok && o == v {
+		return old
+	}
+	return v
+}
+
+// recycle prepare field to be read again, value is dropped when its type changed.
+func (f *TField) recycle(id TFieldID, type_ thrift.TType, name string) {
+	if f.ID != id || f.Type != type_ {
+		f.ID, f.Type, f.Required, f.Value = id, type_, false, nil
+		f.Name = name
+	} else if name != "" {
+		f.Name = name
+	}
+}
+
 // GetID get field ID.
 func (f TField) GetID() TFieldID {
 	return f.ID
@@ -0,0 +1,347 @@
+package thrift_dyn
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+)
+
+var (
+	ErrFieldType     = errors.New("type mismatch")
+	ErrFieldUnset    = errors.New("value not set")
+	ErrFieldOverflow = errors.New("value out of range")
+)
+
+// MapEntry is entry of map field value, see TField.GetMap.
+type MapEntry struct {
+	Key, Value any
+}
+
+func (f *TField) typeError(want string) error {
+	return fmt.Errorf("field %d: %w: %s field accessed as %s", f.ID, ErrFieldType, textTypeName(f.Type), want)
+}
+
+func (f *TField) valueError() error {
+	if f.Value == nil {
+		return fmt.Errorf("field %d: %w", f.ID, ErrFieldUnset)
+	}
+	return fmt.Errorf("field %d: %w: %s field holds %T", f.ID, ErrFieldType, textTypeName(f.Type), f.Value)
+}
+
+func (f *TField) overflowError(v any, want string) error {
+	return fmt.Errorf("field %d: %w: %v as %s", f.ID, ErrFieldOverflow, v, want)
+}
+
+// GetBool get value of BOOL field.
+func (f *TField) GetBool() (bool, error) {
+	if f.Type != thrift.BOOL {
+		return false, f.typeError("bool")
+	}
+	if v, ok := f.Value.(bool); ok {
+		return v, nil
+	}
+	return false, f.valueError()
+}
+
+// intValue get value of integer field, enum value is its number.
+func (f *TField) intValue(want string) (int64, error) {
+	switch f.Type {
+	case thrift.BYTE, thrift.I16, thrift.I32, thrift.I64:
+	default:
+		return 0, f.typeError(want)
+	}
+	switch v := f.Value.(type) {
+	case int8:
+		return int64(v), nil
+	case byte:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case TEnum:
+		return int64(v.Value), nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	}
+	return 0, f.valueError()
+}
+
+// GetByte get value of integer field as int8, error if it does not fit.
+func (f *TField) GetByte() (int8, error) {
+	v, err := f.intValue("byte")
+	if err == nil && (v < math.MinInt8 || v > math.MaxInt8) {
+		err = f.overflowError(v, "byte")
+	}
+	return int8(v), err
+}
+
+// GetI16 get value of integer field as int16, error if it does not fit.
+func (f *TField) GetI16() (int16, error) {
+	v, err := f.intValue("i16")
+	if err == nil && (v < math.MinInt16 || v > math.MaxInt16) {
+		err = f.overflowError(v, "i16")
+	}
+	return int16(v), err
+}
+
+// GetI32 get value of integer field as int32, error if it does not fit.
+func (f *TField) GetI32() (int32, error) {
+	v, err := f.intValue("i32")
+	if err == nil && (v < math.MinInt32 || v > math.MaxInt32) {
+		err = f.overflowError(v, "i32")
+	}
+	return int32(v), err
+}
+
+// GetI64 get value of integer field as int64.
+func (f *TField) GetI64() (int64, error) {
+	return f.intValue("i64")
+}
+
+// GetDouble get value of DOUBLE field, value of integer field up to i32 is converted.
+func (f *TField) GetDouble() (float64, error) {
+	switch f.Type {
+	case thrift.DOUBLE:
+		if v, ok := f.Value.(float64); ok {
+			return v, nil
+		}
+		return 0, f.valueError()
+	case thrift.BYTE, thrift.I16, thrift.I32:
+		v, err := f.intValue("double")
+		return float64(v), err
+	}
+	return 0, f.typeError("double")
+}
+
+// GetEnum get value of I32 field as enum, TEnum of plain i32 value has no name.
+func (f *TField) GetEnum() (TEnum, error) {
+	if f.Type != thrift.I32 {
+		return TEnum{}, f.typeError("enum")
+	}
+	switch v := f.Value.(type) {
+	case TEnum:
+		return v, nil
+	case int32:
+		return TEnum{Value: v}, nil
+	}
+	return TEnum{}, f.valueError()
+}
+
+// GetString get value of STRING field as string, binary value is copied.
+func (f *TField) GetString() (string, error) {
+	if f.Type != thrift.STRING {
+		return "", f.typeError("string")
+	}
+	switch v := f.Value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	return "", f.valueError()
+}
+
+// GetBinary get value of STRING field as []byte, string value is copied.
+func (f *TField) GetBinary() ([]byte, error) {
+	if f.Type != thrift.STRING {
+		return nil, f.typeError("binary")
+	}
+	switch v := f.Value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, f.valueError()
+}
+
+// GetStruct get value of STRUCT field, union and planned struct are unwrapped.
+func (f *TField) GetStruct() (*RPCStruct, error) {
+	if f.Type != thrift.STRUCT {
+		return nil, f.typeError("struct")
+	}
+	switch v := f.Value.(type) {
+	case *RPCStruct:
+		return v, nil
+	case *RPCUnion:
+		return &v.RPCStruct, nil
+	case *PlannedStruct:
+		return v.RPCStruct, nil
+	}
+	return nil, f.valueError()
+}
+
+// GetUnion get value of STRUCT field decoded as union.
+func (f *TField) GetUnion() (*RPCUnion, error) {
+	if f.Type != thrift.STRUCT {
+		return nil, f.typeError("union")
+	}
+	if v, ok := f.Value.(*RPCUnion); ok {
+		return v, nil
+	}
+	return nil, f.valueError()
+}
+
+// containerValue get container of field of type t.
+func (f *TField) containerValue(t thrift.TType) (any, error) {
+	if f.Type != t {
+		return nil, f.typeError(textTypeName(t))
+	}
+	if typ, ok := f.Value.(typeContainerDescriber); ok && typ.GetType() == t {
+		return typ, nil
+	}
+	return nil, f.valueError()
+}
+
+// GetList get elements of LIST field.
+func (f *TField) GetList() ([]any, error) {
+	return f.collectionValue(thrift.LIST)
+}
+
+// GetSet get elements of SET field.
+func (f *TField) GetSet() ([]any, error) {
+	return f.collectionValue(thrift.SET)
+}
+
+func (f *TField) collectionValue(t thrift.TType) (vs []any, err error) {
+	c, err := f.containerValue(t)
+	if err != nil {
+		return
+	}
+	vs = make([]any, 0, c.(TypeContainerImplementer).GetSize())
+	containerEach(c, func(_, value any) {
+		vs = append(vs, value)
+	})
+	return
+}
+
+// GetMap get entries of MAP field, in wire order unless the map is unordered.
+func (f *TField) GetMap() (items []MapEntry, err error) {
+	c, err := f.containerValue(thrift.MAP)
+	if err != nil {
+		return
+	}
+	items = make([]MapEntry, 0, c.(TypeContainerImplementer).GetSize())
+	containerEach(c, func(key, value any) {
+		items = append(items, MapEntry{Key: key, Value: value})
+	})
+	return
+}
+
+// SetBool set value of BOOL field.
+func (f *TField) SetBool(v bool) error {
+	if f.Type != thrift.BOOL {
+		return f.typeError("bool")
+	}
+	f.Value = v
+	return nil
+}
+
+// setInt set value of integer field in its type, error if it does not fit.
+func (f *TField) setInt(v int64, from string) error {
+	switch f.Type {
+	case thrift.BYTE:
+		if v >= math.MinInt8 && v <= math.MaxInt8 {
+			f.Value = int8(v)
+			return nil
+		}
+	case thrift.I16:
+		if v >= math.MinInt16 && v <= math.MaxInt16 {
+			f.Value = int16(v)
+			return nil
+		}
+	case thrift.I32:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			f.Value = int32(v)
+			return nil
+		}
+	case thrift.I64:
+		f.Value = v
+		return nil
+	default:
+		return f.typeError(from)
+	}
+	return f.overflowError(v, textTypeName(f.Type))
+}
+
+// SetByte set value of integer field.
+func (f *TField) SetByte(v int8) error {
+	return f.setInt(int64(v), "byte")
+}
+
+// SetI16 set value of integer field, error if it does not fit.
+func (f *TField) SetI16(v int16) error {
+	return f.setInt(int64(v), "i16")
+}
+
+// SetI32 set value of integer field, error if it does not fit.
+func (f *TField) SetI32(v int32) error {
+	return f.setInt(int64(v), "i32")
+}
+
+// SetI64 set value of integer field, error if it does not fit.
+func (f *TField) SetI64(v int64) error {
+	return f.setInt(v, "i64")
+}
+
+// SetDouble set value of DOUBLE field.
+func (f *TField) SetDouble(v float64) error {
+	if f.Type != thrift.DOUBLE {
+		return f.typeError("double")
+	}
+	f.Value = v
+	return nil
+}
+
+// SetEnum set value of I32 field, enum of known desc is validated.
+func (f *TField) SetEnum(v TEnum) error {
+	if f.Type != thrift.I32 {
+		return f.typeError("enum")
+	}
+	if v.Enum != nil && !v.Valid() {
+		return fmt.Errorf("field %d: %w %d of %s", f.ID, ErrInvalidEnumValue, v.Value, v.Enum.Name)
+	}
+	f.Value = v
+	return nil
+}
+
+// SetString set value of STRING field.
+func (f *TField) SetString(v string) error {
+	if f.Type != thrift.STRING {
+		return f.typeError("string")
+	}
+	f.Value = v
+	return nil
+}
+
+// SetBinary set value of STRING field.
+func (f *TField) SetBinary(v []byte) error {
+	if f.Type != thrift.STRING {
+		return f.typeError("binary")
+	}
+	f.Value = v
+	return nil
+}
+
+// SetStruct set value of STRUCT field.
+func (f *TField) SetStruct(v thrift.TStruct) error {
+	if f.Type != thrift.STRUCT {
+		return f.typeError("struct")
+	}
+	f.Value = v
+	return nil
+}
+
+// SetContainer set value of MAP, SET or LIST field, container type must match the field.
+func (f *TField) SetContainer(v TypeContainerImplementer) error {
+	typ, ok := v.(typeContainerDescriber)
+	if !ok || typ.GetType() != f.Type {
+		return f.typeError(fmt.Sprintf("%T", v))
+	}
+	f.Value = v
+	return nil
+}
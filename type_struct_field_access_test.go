@@ -0,0 +1,120 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTFieldAccess(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+
+	list := NewTypeContainerList[int64](TypeContainerDesc{Value: thrift.I64}, false)
+	list.Add(1, 2, 3)
+	m := NewTypeContainerMap[int64, int64](TypeContainerDesc{Key: thrift.I64, Value: thrift.I64}, false)
+	m.AddKV(1, 2)
+	st := (&RPCStruct{}).AddField(
+		NewTField(1, thrift.STRING, "abc", true).SetValue("hello"),
+		NewTField(3, thrift.I16, "small", false).SetValue(int16(300)),
+		NewTField(4, thrift.I64, "sd", false).SetValue(int64(0xcafe)),
+		NewTField(9, thrift.DOUBLE, "f64", false).SetValue(0.5),
+		NewTField(10, thrift.LIST, "listI64", false).SetValue(list),
+		NewTField(11, thrift.MAP, "mapI64", false).SetValue(m),
+		NewTField(12, thrift.STRUCT, "sub", false).SetValue((&RPCStruct{}).AddField(
+			NewTField(1, thrift.BOOL, "ok", false).SetValue(true),
+		)),
+	)
+	bb, err := enc.Encode(st)
+	require.NoError(t, err)
+	var st2 RPCStruct
+	require.NoError(t, dec.Decode(bb, &st2))
+
+	s, err := testField(&st2, 1).GetString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+	b, err := testField(&st2, 1).GetBinary()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), b)
+
+	// lossless width conversion.
+	i64, err := testField(&st2, 3).GetI64()
+	require.NoError(t, err)
+	require.Equal(t, int64(300), i64)
+	i32, err := testField(&st2, 4).GetI32()
+	require.NoError(t, err)
+	require.Equal(t, int32(0xcafe), i32)
+	_, err = testField(&st2, 4).GetByte()
+	require.ErrorIs(t, err, ErrFieldOverflow)
+	require.EqualError(t, err, "field 4: value out of range: 51966 as byte")
+	f64, err := testField(&st2, 3).GetDouble()
+	require.NoError(t, err)
+	require.Equal(t, 300.0, f64)
+	f64, err = testField(&st2, 9).GetDouble()
+	require.NoError(t, err)
+	require.Equal(t, 0.5, f64)
+
+	_, err = testField(&st2, 4).GetString()
+	require.ErrorIs(t, err, ErrFieldType)
+	require.EqualError(t, err, "field 4: type mismatch: i64 field accessed as string")
+	_, err = testField(&st2, 4).GetDouble()
+	require.ErrorIs(t, err, ErrFieldType)
+
+	vs, err := testField(&st2, 10).GetList()
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(1), int64(2), int64(3)}, vs)
+	_, err = testField(&st2, 10).GetSet()
+	require.ErrorIs(t, err, ErrFieldType)
+	entries, err := testField(&st2, 11).GetMap()
+	require.NoError(t, err)
+	require.Equal(t, []MapEntry{{Key: int64(1), Value: int64(2)}}, entries)
+
+	sub, err := testField(&st2, 12).GetStruct()
+	require.NoError(t, err)
+	ok, err := testField(sub, 1).GetBool()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = NewTField(5, thrift.I32, "unset", false).GetI32()
+	require.ErrorIs(t, err, ErrFieldUnset)
+	require.EqualError(t, err, "field 5: value not set")
+	_, err = NewTField(5, thrift.I32, "bad", false).SetValue("x").GetI32()
+	require.EqualError(t, err, "field 5: type mismatch: i32 field holds string")
+}
+
+func TestTFieldAccessSet(t *testing.T) {
+	f := NewTField(1, thrift.I32, "n", false)
+	require.NoError(t, f.SetByte(-1))
+	require.Equal(t, int32(-1), f.Value)
+	require.NoError(t, f.SetI64(1<<20))
+	require.Equal(t, int32(1<<20), f.Value)
+	err := f.SetI64(1 << 40)
+	require.ErrorIs(t, err, ErrFieldOverflow)
+	require.Equal(t, int32(1<<20), f.Value)
+	require.ErrorIs(t, f.SetString("x"), ErrFieldType)
+	require.ErrorIs(t, f.SetDouble(1), ErrFieldType)
+
+	desc := &EnumDesc{Name: "Status", Values: []*EnumValueDesc{{Name: "OK", Value: 0}, {Name: "FAILED", Value: 5}}}
+	require.NoError(t, f.SetEnum(NewTEnum(desc, 5)))
+	e, err := f.GetEnum()
+	require.NoError(t, err)
+	require.Equal(t, "FAILED", e.Name)
+	i32, err := f.GetI32()
+	require.NoError(t, err)
+	require.Equal(t, int32(5), i32)
+	require.ErrorIs(t, f.SetEnum(NewTEnum(desc, 3)), ErrInvalidEnumValue)
+
+	f = NewTField(2, thrift.STRING, "s", false)
+	require.NoError(t, f.SetBinary([]byte("abc")))
+	s, err := f.GetString()
+	require.NoError(t, err)
+	require.Equal(t, "abc", s)
+	require.ErrorIs(t, f.SetI32(1), ErrFieldType)
+
+	f = NewTField(3, thrift.LIST, "l", false)
+	list := NewTypeContainerList[int64](TypeContainerDesc{Value: thrift.I64}, false)
+	require.NoError(t, f.SetContainer(list))
+	set := NewTypeContainerSet[int64](TypeContainerDesc{Value: thrift.I64}, false)
+	require.ErrorIs(t, f.SetContainer(set), ErrFieldType)
+	require.ErrorIs(t, f.SetStruct(&RPCStruct{}), ErrFieldType)
+}
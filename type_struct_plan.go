@@ -0,0 +1,1012 @@
+package thrift_dyn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"math"
+)
+
+// StructPlan is a StructDesc compiled into a flat list of field ops.
+// Each op has its write and read handler resolved at compile time,
+// so encoding and decoding skip the per-value type switches of
+// WriteDataGeneric and ReadDataGeneric, and container elements are
+// written without boxing them into interface.
+type StructPlan struct {
+	Desc     *StructDesc
+	ops      []*planOp
+	index    []int16 // op index by field id, -1 if absent
+	byID     map[TFieldID]int
+	defaults []*planOp // ops of optional fields with declared default
+}
+
+type planWriteFunc func(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error
+type planReadFunc func(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) error
+
+type planOp struct {
+	ID       TFieldID
+	Name     string
+	Type     thrift.TType
+	Required bool
+	Desc     *TypeDesc
+
+	write planWriteFunc
+	read  planReadFunc
+	sub   *StructPlan  // STRUCT
+	def   *planDefault // nil if no default is declared
+}
+
+// planDefault is default value of field, kept encoded in Binary protocol
+// so that every struct read gets its own copy of container and struct values.
+type planDefault struct {
+	bytes []byte
+	value any // decoded value of scalar types, nil otherwise
+}
+
+// planElem write and read container element of type T.
+type planElem[T any] struct {
+	write func(ctx context.Context, p thrift.TProtocol, v T) error
+	read  func(ctx context.Context, p thrift.TProtocol) (T, error)
+}
+
+const planIndexSize = 256
+const planPreallocSize = 1 << 10
+
+var (
+	planScalarWriteOps [_BT_SIZE]planWriteFunc
+	planScalarReadOps  [_BT_SIZE]planReadFunc
+)
+
+func init() {
+	planScalarWriteOps[thrift.BOOL] = planWriteBool
+	planScalarWriteOps[thrift.BYTE] = planWriteByte
+	planScalarWriteOps[thrift.I16] = planWriteI16
+	planScalarWriteOps[thrift.I32] = planWriteI32
+	planScalarWriteOps[thrift.I64] = planWriteI64
+	planScalarWriteOps[thrift.DOUBLE] = planWriteDouble
+	planScalarWriteOps[thrift.STRING] = planWriteString
+
+	planScalarReadOps[thrift.BOOL] = planReadBool
+	planScalarReadOps[thrift.BYTE] = planReadByte
+	planScalarReadOps[thrift.I16] = planReadI16
+	planScalarReadOps[thrift.I32] = planReadI32
+	planScalarReadOps[thrift.I64] = planReadI64
+	planScalarReadOps[thrift.DOUBLE] = planReadDouble
+	planScalarReadOps[thrift.STRING] = planReadString
+}
+
+// CompileStruct compile struct descriptor into StructPlan,
+// nested struct descriptors are compiled as well.
+func CompileStruct(desc *StructDesc) (*StructPlan, error) {
+	c := planCompiler{plans: map[*StructDesc]*StructPlan{}}
+	return c.compileStruct(desc)
+}
+
+type planCompiler struct {
+	plans map[*StructDesc]*StructPlan
+}
+
+func (c *planCompiler) compileStruct(desc *StructDesc) (pl *StructPlan, err error) {
+	if pl, ok := c.plans[desc]; ok { // recursive struct
+		return pl, nil
+	}
+	if err = desc.Validate(); err != nil {
+		return
+	}
+	pl = &StructPlan{Desc: desc}
+	c.plans[desc] = pl
+	for _, f := range desc.Fields {
+		var op *planOp
+		if op, err = c.compileOp(f.Type, f.Required); err != nil {
+			return nil, fmt.Errorf("%s: field %d '%s': %w", desc.Name, f.ID, f.Name, err)
+		}
+		op.ID, op.Name = f.ID, f.Name
+		if err = op.compileDefault(f); err != nil {
+			return nil, fmt.Errorf("%s: field %d '%s' default: %w", desc.Name, f.ID, f.Name, err)
+		}
+		if op.def != nil && !op.Required {
+			pl.defaults = append(pl.defaults, op)
+		}
+		pl.ops = append(pl.ops, op)
+	}
+	pl.buildIndex()
+	return
+}
+
+func (op *planOp) compileDefault(f *FieldDesc) (err error) {
+	value := f.DefaultValue
+	if value == nil {
+		if f.Default == "" {
+			return nil
+		}
+		if value, err = EvalConst(f.Default, f.Type, nil); err != nil {
+			return
+		}
+	}
+	mb := thrift.NewTMemoryBuffer()
+	if err = writeConst(context.Background(), thrift.NewTBinaryProtocolConf(mb, nil), value, f.Type); err != nil {
+		return
+	}
+	op.def = &planDefault{bytes: mb.Bytes()}
+	switch op.Type {
+	case thrift.STRUCT, thrift.MAP, thrift.SET, thrift.LIST:
+		// nested plan may not be compiled yet, read on demand.
+	default:
+		op.def.value, err = op.readDefault(context.Background())
+	}
+	return
+}
+
+// readDefault get default value of field.
+func (op *planOp) readDefault(ctx context.Context) (value any, err error) {
+	if op.def.value != nil {
+		return op.def.value, nil
+	}
+	p := thrift.NewTBinaryProtocolConf(thrift.NewStreamTransportR(bytes.NewReader(op.def.bytes)), nil)
+	err = op.read(ctx, p, op, &value)
+	return
+}
+
+// isDefault report whether value is equal to default of field, scalar and enum
+// values are compared with the decoded default, others by their encoding.
+func (op *planOp) isDefault(ctx context.Context, value any) bool {
+	if op.def.value != nil {
+		return isDefaultScalar(op.def.value, value)
+	}
+	mb := thrift.NewTMemoryBufferLen(len(op.def.bytes))
+	if err := op.write(ctx, thrift.NewTBinaryProtocolConf(mb, nil), op, value); err != nil {
+		return false
+	}
+	return bytes.Equal(mb.Bytes(), op.def.bytes)
+}
+
+// isDefaultScalar report whether scalar value encodes the same as def, the decoded default.
+func isDefaultScalar(def, value any) bool {
+	switch d := def.(type) {
+	case TEnum:
+		if v, ok := value.(TEnum); ok {
+			return v.Value == d.Value
+		}
+		if d.Enum == nil {
+			break
+		}
+		e, err := d.Enum.Parse(value)
+		return err == nil && e.Value == d.Value
+	case float64:
+		v, ok := value.(float64)
+		return ok && math.Float64bits(v) == math.Float64bits(d)
+	case string:
+		if b, ok := value.([]byte); ok {
+			return string(b) == d
+		}
+	case []byte:
+		switch v := value.(type) {
+		case []byte:
+			return bytes.Equal(v, d)
+		case string:
+			return v == string(d)
+		}
+		return false
+	case int8, int16, int32, int64:
+		if value == def {
+			return true
+		}
+		if v, ok := value.(TEnum); ok {
+			value = v.Value
+		}
+		n, ok := buildInt(value)
+		m, _ := buildInt(def)
+		return ok && n == m
+	}
+	return value == def
+}
+
+// fillDefaults add fields missing from fields with their default value.
+func (pl *StructPlan) fillDefaults(ctx context.Context, fields []*TField) ([]*TField, error) {
+	for _, op := range pl.defaults {
+		found := false
+		for _, f := range fields {
+			if f.ID == op.ID {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		value, err := op.readDefault(ctx)
+		if err != nil {
+			return fields, err
+		}
+		fields = append(fields, NewTField(op.ID, op.Type, op.Name, op.Required).SetValue(value))
+	}
+	return fields, nil
+}
+
+func (pl *StructPlan) buildIndex() {
+	pl.index = nil
+	pl.byID = nil
+	for i, op := range pl.ops {
+		if op.ID >= 0 && op.ID < planIndexSize {
+			for int(op.ID) >= len(pl.index) {
+				pl.index = append(pl.index, -1)
+			}
+			pl.index[op.ID] = int16(i)
+			continue
+		}
+		if pl.byID == nil {
+			pl.byID = map[TFieldID]int{}
+		}
+		pl.byID[op.ID] = i
+	}
+}
+
+func (pl *StructPlan) lookup(id TFieldID) *planOp {
+	if id >= 0 && int(id) < len(pl.index) {
+		if i := pl.index[id]; i >= 0 {
+			return pl.ops[i]
+		}
+		return nil
+	}
+	if i, ok := pl.byID[id]; ok {
+		return pl.ops[i]
+	}
+	return nil
+}
+
+func (c *planCompiler) compileOp(desc *TypeDesc, required bool) (op *planOp, err error) {
+	op = &planOp{
+		Type:     desc.Type,
+		Required: required,
+		Desc:     desc,
+		write:    planWriteGeneric,
+		read:     planReadGeneric,
+	}
+	switch desc.Type {
+	case thrift.BOOL, thrift.BYTE, thrift.I16, thrift.I32, thrift.I64, thrift.DOUBLE, thrift.STRING:
+		op.write = planScalarWriteOps[desc.Type]
+		op.read = planScalarReadOps[desc.Type]
+		if desc.Enum != nil {
+			op.write, op.read = planWriteEnum, planReadEnum
+		}
+	case thrift.STRUCT:
+		if desc.Struct != nil {
+			if op.sub, err = c.compileStruct(desc.Struct); err != nil {
+				return
+			}
+			op.write = planWriteStruct
+			op.read = planReadStruct
+		}
+	case thrift.SET, thrift.LIST:
+		err = c.compileCollectionOp(op)
+	case thrift.MAP:
+		err = c.compileMapOp(op)
+	}
+	return
+}
+
+func (c *planCompiler) compileCollectionOp(op *planOp) error {
+	elemDesc := op.Desc.Value
+	switch elemDesc.Type {
+	case thrift.BOOL:
+		planCollectionOps(op, planElemBool)
+	case thrift.BYTE:
+		planCollectionOps(op, planElemByte)
+	case thrift.I16:
+		planCollectionOps(op, planElemI16)
+	case thrift.I32:
+		if elemDesc.Enum != nil {
+			planCollectionOps(op, planElemEnum(elemDesc.Enum))
+		} else {
+			planCollectionOps(op, planElemI32)
+		}
+	case thrift.I64:
+		planCollectionOps(op, planElemI64)
+	case thrift.DOUBLE:
+		planCollectionOps(op, planElemDouble)
+	case thrift.STRING:
+		planCollectionOps(op, planElemString)
+	case thrift.STRUCT:
+		elem, err := c.compileStructElem(elemDesc, op.Required)
+		if err != nil {
+			return err
+		}
+		planCollectionOps(op, elem)
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		elem, err := c.compileContainerElem(elemDesc, op.Required)
+		if err != nil {
+			return err
+		}
+		planCollectionOps(op, elem)
+	}
+	return nil
+}
+
+func (c *planCompiler) compileMapOp(op *planOp) error {
+	switch op.Desc.Key.Type {
+	case thrift.BOOL:
+		return planCompileMapOfKey(c, op, planElemBool)
+	case thrift.BYTE:
+		return planCompileMapOfKey(c, op, planElemByte)
+	case thrift.I16:
+		return planCompileMapOfKey(c, op, planElemI16)
+	case thrift.I32:
+		if op.Desc.Key.Enum != nil {
+			return planCompileMapOfKey(c, op, planElemEnum(op.Desc.Key.Enum))
+		}
+		return planCompileMapOfKey(c, op, planElemI32)
+	case thrift.I64:
+		return planCompileMapOfKey(c, op, planElemI64)
+	case thrift.DOUBLE:
+		return planCompileMapOfKey(c, op, planElemDouble)
+	case thrift.STRING:
+		return planCompileMapOfKey(c, op, planElemString)
+	}
+	// unhandled key type, left to the generic path.
+	return nil
+}
+
+func planCompileMapOfKey[K comparable](c *planCompiler, op *planOp, key planElem[K]) error {
+	valueDesc := op.Desc.Value
+	switch valueDesc.Type {
+	case thrift.BOOL:
+		planMapOps(op, key, planElemBool)
+	case thrift.BYTE:
+		planMapOps(op, key, planElemByte)
+	case thrift.I16:
+		planMapOps(op, key, planElemI16)
+	case thrift.I32:
+		if valueDesc.Enum != nil {
+			planMapOps(op, key, planElemEnum(valueDesc.Enum))
+		} else {
+			planMapOps(op, key, planElemI32)
+		}
+	case thrift.I64:
+		planMapOps(op, key, planElemI64)
+	case thrift.DOUBLE:
+		planMapOps(op, key, planElemDouble)
+	case thrift.STRING:
+		planMapOps(op, key, planElemString)
+	case thrift.STRUCT:
+		elem, err := c.compileStructElem(valueDesc, op.Required)
+		if err != nil {
+			return err
+		}
+		planMapOps(op, key, elem)
+	case thrift.MAP, thrift.SET, thrift.LIST:
+		elem, err := c.compileContainerElem(valueDesc, op.Required)
+		if err != nil {
+			return err
+		}
+		planMapOps(op, key, elem)
+	}
+	return nil
+}
+
+func (c *planCompiler) compileStructElem(desc *TypeDesc, required bool) (elem planElem[thrift.TStruct], err error) {
+	var sub *StructPlan
+	if desc.Struct != nil {
+		if sub, err = c.compileStruct(desc.Struct); err != nil {
+			return
+		}
+	}
+	elem.write = func(ctx context.Context, p thrift.TProtocol, v thrift.TStruct) error {
+		if st := planStructOf(v); st != nil && sub != nil {
+			return sub.Write(ctx, p, st)
+		}
+		if v == nil {
+			return (&RPCStruct{}).Write(ctx, p)
+		}
+		return v.Write(ctx, p)
+	}
+	elem.read = func(ctx context.Context, p thrift.TProtocol) (thrift.TStruct, error) {
+		if sub != nil {
+			return sub.readNew(ctx, p)
+		}
+		st := &RPCStruct{}
+		return st, st.Read(ctx, p)
+	}
+	return
+}
+
+func (c *planCompiler) compileContainerElem(desc *TypeDesc, required bool) (elem planElem[TypeContainerImplementer], err error) {
+	var op *planOp
+	if op, err = c.compileOp(desc, required); err != nil {
+		return
+	}
+	elem.write = func(ctx context.Context, p thrift.TProtocol, v TypeContainerImplementer) error {
+		return op.write(ctx, p, op, v)
+	}
+	elem.read = func(ctx context.Context, p thrift.TProtocol) (TypeContainerImplementer, error) {
+		var v any
+		err := op.read(ctx, p, op, &v)
+		typ, _ := v.(TypeContainerImplementer)
+		return typ, err
+	}
+	return
+}
+
+func planCap(size int) int {
+	if size > planPreallocSize {
+		return planPreallocSize
+	}
+	return size
+}
+
+func planCollectionOps[T Sliceable](op *planOp, elem planElem[T]) {
+	elemType := op.Desc.Value.Type
+	planDesc := op.Desc.ContainerDesc()
+	op.write = func(ctx context.Context, p thrift.TProtocol, op *planOp, value any) (err error) {
+		var values []T
+		switch value := value.(type) {
+		case *TypeContainerList[T]:
+			values = value.Value
+		case *TypeContainerSet[T]:
+			values = value.Value
+		default:
+			return planWriteGeneric(ctx, p, op, value)
+		}
+		if op.Type == thrift.SET && isCanonical(p) {
+			values = canonicalElems(values, true)
+		}
+		if op.Type == thrift.SET {
+			err = p.WriteSetBegin(ctx, elemType, len(values))
+		} else {
+			err = p.WriteListBegin(ctx, elemType, len(values))
+		}
+		if err != nil {
+			return
+		}
+		for i := range values {
+			if err = elem.write(ctx, p, values[i]); err != nil {
+				return
+			}
+		}
+		if op.Type == thrift.SET {
+			return p.WriteSetEnd(ctx)
+		}
+		return p.WriteListEnd(ctx)
+	}
+	op.read = func(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+		var (
+			wireType thrift.TType
+			size     int
+		)
+		if op.Type == thrift.SET {
+			wireType, size, err = p.ReadSetBegin(ctx)
+		} else {
+			wireType, size, err = p.ReadListBegin(ctx)
+		}
+		if err != nil {
+			return
+		}
+		desc := TypeContainerDesc{Value: wireType}
+		if wireType != elemType && size > 0 {
+			// wire data does not match the descriptor.
+			err = readContainerBody(ctx, p, op.Type, desc, size, op.Required, value)
+		} else {
+			values := make([]T, 0, planCap(size))
+			for i := 0; i < size; i++ {
+				var v T
+				if v, err = elem.read(ctx, p); err != nil {
+					return
+				}
+				values = append(values, v)
+			}
+			desc = planDesc
+			if op.Type == thrift.SET {
+				typ := NewTypeContainerSet[T](desc, op.Required)
+				typ.Size, typ.Value = size, values
+				*value = typ
+			} else {
+				typ := NewTypeContainerList[T](desc, op.Required)
+				typ.Size, typ.Value = size, values
+				*value = typ
+			}
+		}
+		if err != nil {
+			return
+		}
+		if op.Type == thrift.SET {
+			return p.ReadSetEnd(ctx)
+		}
+		return p.ReadListEnd(ctx)
+	}
+}
+
+func planMapOps[K comparable, V any](op *planOp, key planElem[K], val planElem[V]) {
+	keyType, valueType := op.Desc.Key.Type, op.Desc.Value.Type
+	planDesc := op.Desc.ContainerDesc()
+	op.write = func(ctx context.Context, p thrift.TProtocol, op *planOp, value any) (err error) {
+		switch value := value.(type) {
+		case *TypeContainerMap[K, V]:
+			entries := value.Value
+			if isCanonical(p) {
+				entries = canonicalEntries(entries)
+			}
+			if err = p.WriteMapBegin(ctx, keyType, valueType, len(entries)); err != nil {
+				return
+			}
+			for i := range entries {
+				if err = key.write(ctx, p, entries[i].Key); err != nil {
+					return
+				}
+				if err = val.write(ctx, p, entries[i].Value); err != nil {
+					return
+				}
+			}
+		case *TypeContainerMapUnordered[K, V]:
+			if err = p.WriteMapBegin(ctx, keyType, valueType, len(value.Value)); err != nil {
+				return
+			}
+			if isCanonical(p) {
+				for _, k := range canonicalKeys(value.Value) {
+					if err = key.write(ctx, p, k); err != nil {
+						return
+					}
+					if err = val.write(ctx, p, value.Value[k]); err != nil {
+						return
+					}
+				}
+				break
+			}
+			for k, v := range value.Value {
+				if err = key.write(ctx, p, k); err != nil {
+					return
+				}
+				if err = val.write(ctx, p, v); err != nil {
+					return
+				}
+			}
+		default:
+			return planWriteGeneric(ctx, p, op, value)
+		}
+		return p.WriteMapEnd(ctx)
+	}
+	op.read = func(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+		var (
+			desc TypeContainerDesc
+			size int
+		)
+		if desc.Key, desc.Value, size, err = p.ReadMapBegin(ctx); err != nil {
+			return
+		}
+		if (desc.Key != keyType || desc.Value != valueType) && size > 0 {
+			// wire data does not match the descriptor.
+			if err = readContainerBody(ctx, p, thrift.MAP, desc, size, op.Required, value); err != nil {
+				return
+			}
+			return p.ReadMapEnd(ctx)
+		}
+		typ := NewTypeContainerMap[K, V](planDesc, op.Required)
+		typ.Size = size
+		typ.Value = make([]TypeContainerMapItem[K, V], 0, planCap(size))
+		for i := 0; i < size; i++ {
+			var item TypeContainerMapItem[K, V]
+			if item.Key, err = key.read(ctx, p); err != nil {
+				return
+			}
+			if item.Value, err = val.read(ctx, p); err != nil {
+				return
+			}
+			typ.Value = append(typ.Value, item)
+		}
+		*value = typ
+		return p.ReadMapEnd(ctx)
+	}
+}
+
+// readContainerBody read container elements of the given wire description, container header is already read.
+func readContainerBody(ctx context.Context, p thrift.TProtocol, type_ thrift.TType, desc TypeContainerDesc, size int, required bool, value *any) (err error) {
+	var typ TypeContainerImplementer
+	switch type_ {
+	case thrift.MAP:
+		typ, err = NewTypeContainerMapOfTType(desc, required)
+	case thrift.SET:
+		typ, err = NewTypeContainerSetOfTType(desc, required)
+	case thrift.LIST:
+		typ, err = NewTypeContainerListOfTType(desc, required)
+	default:
+		err = fmt.Errorf("expected container, got %s", type_)
+	}
+	if err != nil {
+		skipInvalid(p, func() error { return skipElems(ctx, p, type_, desc, size) })
+		return
+	}
+	typ.SetSize(size)
+	if err = typ.Read(ctx, p); err != nil {
+		return
+	}
+	*value = typ
+	return
+}
+
+// Write writes fields to the wire, fields unknown to the plan are written by the generic path.
+func (pl *StructPlan) Write(ctx context.Context, p thrift.TProtocol, s *RPCStruct) (err error) {
+	var fieldId TFieldID
+	omit := len(pl.defaults) > 0 && isOmitDefaults(p)
+	fields := s.Fields
+	if isCanonical(p) {
+		fields = canonicalFields(fields)
+	}
+	if pl.Desc.Kind == StructKindUnion {
+		if err = validateUnion(pl.Desc.Name, s.Fields); err != nil {
+			return
+		}
+	}
+	if err = p.WriteStructBegin(ctx, pl.Desc.Name); err != nil {
+		goto WriteStructBeginError
+	}
+
+	for _, field := range fields {
+		fieldId = field.ID
+		op := pl.lookup(field.ID)
+		if op == nil || op.Type != field.Type {
+			if err = field.Write(ctx, p); err != nil {
+				goto WriteFieldError
+			}
+			continue
+		}
+		if field.Value == nil && !field.Required && !op.Required {
+			continue
+		}
+		if omit && op.def != nil && !field.Required && !op.Required && op.isDefault(ctx, field.Value) {
+			continue
+		}
+		if err = p.WriteFieldBegin(ctx, op.Name, op.Type, op.ID); err != nil {
+			goto WriteFieldError
+		}
+		if err = op.write(ctx, p, op, field.Value); err != nil {
+			goto WriteFieldError
+		}
+		if err = p.WriteFieldEnd(ctx); err != nil {
+			goto WriteFieldError
+		}
+	}
+
+	if err = p.WriteFieldStop(ctx); err != nil {
+		goto WriteFieldStopError
+	}
+	if err = p.WriteStructEnd(ctx); err != nil {
+		goto WriteStructEndError
+	}
+	return nil
+WriteStructBeginError:
+	return thrift.PrependError(fmt.Sprintf("%s write struct begin error: ", pl.Desc.Name), err)
+WriteFieldError:
+	return thrift.PrependError(fmt.Sprintf("%s write field %d error: ", pl.Desc.Name, fieldId), err)
+WriteFieldStopError:
+	return thrift.PrependError(fmt.Sprintf("%s write field stop error: ", pl.Desc.Name), err)
+WriteStructEndError:
+	return thrift.PrependError(fmt.Sprintf("%s write struct end error: ", pl.Desc.Name), err)
+}
+
+// Read reads fields from wire, fields unknown to the plan are read by the generic path.
+func (pl *StructPlan) Read(ctx context.Context, p thrift.TProtocol, s *RPCStruct) (err error) {
+	var (
+		fieldName   string
+		fieldTypeId thrift.TType
+		fieldId     TFieldID
+		field       *TField
+		vv          = s.Fields[:0]
+		depth       int
+	)
+
+	if _, err = p.ReadStructBegin(ctx); err != nil {
+		goto ReadStructBeginError
+	}
+	depth = decodeDepth(p)
+
+	for {
+		fieldName, fieldTypeId, fieldId, err = p.ReadFieldBegin(ctx)
+		if err != nil {
+			goto ReadFieldBeginError
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+
+		field = NewTField(fieldId, fieldTypeId, fieldName, false)
+		if op := pl.lookup(fieldId); op != nil && op.Type == fieldTypeId {
+			field.Name, field.Required = op.Name, op.Required
+			err = op.read(ctx, p, op, &field.Value)
+		} else {
+			err = field.Read(ctx, p)
+		}
+		if err != nil {
+			if !recoverField(p, depth, field, err) {
+				goto ReadFieldError
+			}
+			// invalid value is skipped in best-effort mode.
+			if err = p.ReadFieldEnd(ctx); err != nil {
+				goto ReadFieldEndError
+			}
+			continue
+		}
+
+		if err = p.ReadFieldEnd(ctx); err != nil {
+			goto ReadFieldEndError
+		}
+
+		vv = append(vv, field)
+	}
+	s.Fields = vv
+	if s.Name == "" {
+		s.Name = pl.Desc.Name
+	}
+	if len(pl.defaults) > 0 {
+		if s.Fields, err = pl.fillDefaults(ctx, s.Fields); err != nil {
+			goto ReadDefaultError
+		}
+	}
+
+	if err = p.ReadStructEnd(ctx); err != nil {
+		goto ReadStructEndError
+	}
+
+	if pl.Desc.Kind == StructKindUnion {
+		if err = validateUnion(pl.Desc.Name, s.Fields); err != nil {
+			skipInvalid(p, nil)
+		}
+		return err
+	}
+	return nil
+ReadStructBeginError:
+	s.Fields = vv
+	diagnoseField(p, 0, nil, err)
+	return thrift.PrependError(fmt.Sprintf("%s read struct begin error: ", pl.Desc.Name), err)
+ReadFieldBeginError:
+	s.Fields = vv
+	diagnoseField(p, 0, nil, err)
+	return thrift.PrependError(fmt.Sprintf("%s read field %d begin error: ", pl.Desc.Name, fieldId), err)
+ReadFieldError:
+	// keep partially read value for best-effort decoding.
+	if _, ok := field.Value.(thrift.TStruct); ok {
+		vv = append(vv, field)
+	}
+	s.Fields = vv
+	diagnoseField(p, fieldId, field, err)
+	return thrift.PrependError(fmt.Sprintf("%s read field %d '%s' (%d) error: ", pl.Desc.Name, fieldId, fieldName, fieldTypeId), err)
+ReadFieldEndError:
+	s.Fields = append(vv, field)
+	diagnoseField(p, 0, nil, err)
+	return thrift.PrependError(fmt.Sprintf("%s read field end error", pl.Desc.Name), err)
+ReadDefaultError:
+	return thrift.PrependError(fmt.Sprintf("%s read default error: ", pl.Desc.Name), err)
+ReadStructEndError:
+	diagnoseField(p, 0, nil, err)
+	return thrift.PrependError(fmt.Sprintf("%s read struct end error: ", pl.Desc.Name), err)
+}
+
+// Bind bind RPCStruct to the plan, the result implements thrift.TStruct
+// and can be used with Encoder, Decoder and thrift clients.
+func (pl *StructPlan) Bind(s *RPCStruct) *PlannedStruct {
+	return &PlannedStruct{RPCStruct: s, Plan: pl}
+}
+
+// PlannedStruct is RPCStruct encoded and decoded by StructPlan.
+type PlannedStruct struct {
+	*RPCStruct
+	Plan *StructPlan
+}
+
+func (s *PlannedStruct) Write(ctx context.Context, p thrift.TProtocol) error {
+	return s.Plan.Write(ctx, p, s.RPCStruct)
+}
+
+func (s *PlannedStruct) Read(ctx context.Context, p thrift.TProtocol) error {
+	return s.Plan.Read(ctx, p, s.RPCStruct)
+}
+
+//
+// op handlers.
+//
+
+func planWriteGeneric(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	return WriteDataGeneric(ctx, TDataSpec{
+		Type:      op.Type,
+		Required:  op.Required,
+		Protocol:  p,
+		Container: op.Desc.ContainerDesc(),
+	}, value)
+}
+
+func planReadGeneric(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) error {
+	return ReadDataGeneric(ctx, TDataSpec{
+		Type:      op.Type,
+		Required:  op.Required,
+		Protocol:  p,
+		Container: op.Desc.ContainerDesc(),
+	}, value)
+}
+
+func planWriteStruct(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if st := planStructOf(value); st != nil {
+		return op.sub.Write(ctx, p, st)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planReadStruct(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = op.sub.readNew(ctx, p) // partial on error
+	return
+}
+
+// planStructOf get RPCStruct of value to be written by plan, nil if value is not one.
+func planStructOf(value any) *RPCStruct {
+	switch v := value.(type) {
+	case *RPCStruct:
+		return v
+	case *RPCUnion:
+		return &v.RPCStruct
+	}
+	return nil
+}
+
+// readNew read new struct of the plan, RPCUnion if the plan describes union.
+func (pl *StructPlan) readNew(ctx context.Context, p thrift.TProtocol) (thrift.TStruct, error) {
+	if pl.Desc.Kind == StructKindUnion {
+		u := &RPCUnion{RPCStruct{Name: pl.Desc.Name}}
+		return u, pl.Read(ctx, p, &u.RPCStruct)
+	}
+	st := &RPCStruct{Name: pl.Desc.Name}
+	return st, pl.Read(ctx, p, st)
+}
+
+func planWriteBool(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(bool); ok {
+		return p.WriteBool(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteByte(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(int8); ok {
+		return p.WriteByte(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteI16(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(int16); ok {
+		return p.WriteI16(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteI32(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(int32); ok {
+		return p.WriteI32(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteI64(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(int64); ok {
+		return p.WriteI64(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteDouble(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if v, ok := value.(float64); ok {
+		return p.WriteDouble(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+func planWriteString(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	switch v := value.(type) {
+	case string:
+		return p.WriteBinary(ctx, String2bs(v))
+	case []byte:
+		return p.WriteBinary(ctx, v)
+	}
+	return planWriteGeneric(ctx, p, op, value)
+}
+
+// planWriteEnum write enum given by TEnum, symbolic name or number, validated against the enum.
+func planWriteEnum(ctx context.Context, p thrift.TProtocol, op *planOp, value any) error {
+	if value == nil {
+		return planWriteGeneric(ctx, p, op, value)
+	}
+	e, err := op.Desc.Enum.Parse(value)
+	if err != nil {
+		return err
+	}
+	return writeEnum(ctx, p, e)
+}
+
+func planReadBool(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadBool(ctx)
+	return
+}
+
+func planReadByte(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadByte(ctx)
+	return
+}
+
+func planReadI16(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadI16(ctx)
+	return
+}
+
+func planReadI32(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadI32(ctx)
+	return
+}
+
+func planReadI64(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadI64(ctx)
+	return
+}
+
+func planReadDouble(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = p.ReadDouble(ctx)
+	return
+}
+
+func planReadEnum(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = readEnum(ctx, p, op.Desc.Enum)
+	return
+}
+
+// planReadString read STRING as string, as the schema tells it is not a binary.
+func planReadString(ctx context.Context, p thrift.TProtocol, op *planOp, value *any) (err error) {
+	*value, err = readStringValue(ctx, p)
+	return
+}
+
+//
+// container element handlers.
+//
+
+var planElemBool = planElem[bool]{
+	write: func(ctx context.Context, p thrift.TProtocol, v bool) error { return p.WriteBool(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (bool, error) { return p.ReadBool(ctx) },
+}
+
+var planElemByte = planElem[int8]{
+	write: func(ctx context.Context, p thrift.TProtocol, v int8) error { return p.WriteByte(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (int8, error) { return p.ReadByte(ctx) },
+}
+
+var planElemI16 = planElem[int16]{
+	write: func(ctx context.Context, p thrift.TProtocol, v int16) error { return p.WriteI16(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (int16, error) { return p.ReadI16(ctx) },
+}
+
+var planElemI32 = planElem[int32]{
+	write: func(ctx context.Context, p thrift.TProtocol, v int32) error { return p.WriteI32(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (int32, error) { return p.ReadI32(ctx) },
+}
+
+var planElemI64 = planElem[int64]{
+	write: func(ctx context.Context, p thrift.TProtocol, v int64) error { return p.WriteI64(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (int64, error) { return p.ReadI64(ctx) },
+}
+
+var planElemDouble = planElem[float64]{
+	write: func(ctx context.Context, p thrift.TProtocol, v float64) error { return p.WriteDouble(ctx, v) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (float64, error) { return p.ReadDouble(ctx) },
+}
+
+var planElemString = planElem[string]{
+	write: func(ctx context.Context, p thrift.TProtocol, v string) error { return p.WriteBinary(ctx, String2bs(v)) },
+	read:  func(ctx context.Context, p thrift.TProtocol) (string, error) { return readStringValue(ctx, p) },
+}
+
+func planElemEnum(desc *EnumDesc) planElem[TEnum] {
+	return planElem[TEnum]{
+		write: func(ctx context.Context, p thrift.TProtocol, v TEnum) error {
+			e, err := desc.Parse(v)
+			if err != nil {
+				return err
+			}
+			return writeEnum(ctx, p, e)
+		},
+		read: func(ctx context.Context, p thrift.TProtocol) (TEnum, error) { return readEnum(ctx, p, desc) },
+	}
+}
@@ -0,0 +1,300 @@
+package thrift_dyn
+
+import (
+	"context"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/ii64/go-thrift-dyn/internal/test/base"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func testModelStructDesc() *StructDesc {
+	return NewStructDesc("Model",
+		NewFieldDesc(1, NewTypeDesc(thrift.STRING), "abc", true),
+		NewFieldDesc(4, NewTypeDesc(thrift.I64), "sd", true),
+		NewFieldDesc(9, NewTypeDesc(thrift.DOUBLE), "f64", true),
+		NewFieldDesc(10, NewTypeDescList(NewTypeDesc(thrift.I64)), "listI64", false),
+		NewFieldDesc(11, NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDesc(thrift.I64)), "mapI64", false),
+		NewFieldDesc(12, NewTypeDescMap(NewTypeDesc(thrift.I32), NewTypeDesc(thrift.I32)), "mapI32", false),
+	)
+}
+
+func testRequestStructDesc() *StructDesc {
+	model := NewTypeDescStruct(testModelStructDesc())
+	return NewStructDesc("Request",
+		NewFieldDesc(6, model, "model", true),
+		NewFieldDesc(7, model, "model2", false),
+		NewFieldDesc(44, NewTypeDescList(model), "models", true),
+		NewFieldDesc(31, NewTypeDescMap(NewTypeDesc(thrift.I64), model), "modelById", true),
+		NewFieldDesc(56, NewTypeDescSet(model), "modset", false),
+		NewFieldDesc(88, NewTypeDescMap(NewTypeDesc(thrift.I64), NewTypeDescList(model)), "modelByTime", true),
+	)
+}
+
+func TestStructPlanRoundTrip(t *testing.T) {
+	var err error
+	pl, err := CompileStruct(testRequestStructDesc())
+	require.NoError(t, err)
+
+	expected := base.Request{
+		Model: &base.Model{Abc: "hello", Sd: 0xcafe, F64: 1.05, ListI64: []int64{1, 2, 3}, MapI64: map[int64]int64{1: 2}},
+		Models: []*base.Model{
+			base.NewModel(),
+		},
+		ModelById: map[int64]*base.Model{
+			1234: base.NewModel(),
+		},
+		ModelByTime: map[int64][]*base.Model{
+			567: {base.NewModel()},
+		},
+		Modset: []*base.Model{base.NewModel()},
+	}
+
+	for _, proto := range defaultTestTProtocols {
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		enc := NewEncoder(pf)
+		dec := NewDecoder(pf)
+
+		bb, err := enc.Encode(&expected)
+		require.NoError(t, err)
+
+		var st RPCStruct
+		err = dec.Decode(bb, pl.Bind(&st))
+		require.NoError(t, err)
+		require.Equal(t, "Request", st.Name)
+		require.Equal(t, "model", st.Fields[0].Name)
+		model := st.Fields[0].Value.(*RPCStruct)
+		require.Equal(t, "Model", model.Name)
+		require.Equal(t, "listI64", model.Fields[3].Name)
+		require.Equal(t, []int64{1, 2, 3}, model.Fields[3].Value.(*TypeContainerList[int64]).Value)
+		models := st.Fields[1].Value.(*TypeContainerList[thrift.TStruct])
+		require.Equal(t, "Model", models.Value[0].(*RPCStruct).Name)
+
+		actual, err := enc.Encode(pl.Bind(&st))
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+
+		// plan and generic path agree.
+		var st2 RPCStruct
+		err = dec.Decode(bb, &st2)
+		require.NoError(t, err)
+		actual, err = enc.Encode(pl.Bind(&st2))
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+		actual, err = enc.Encode(&st)
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+	}
+}
+
+func TestStructPlanUnknownField(t *testing.T) {
+	var err error
+	desc := testModelStructDesc()
+	desc.Fields = desc.Fields[:2]
+	desc.Fields[1].Type = NewTypeDesc(thrift.I32) // wire has i64
+	pl, err := CompileStruct(desc)
+	require.NoError(t, err)
+
+	m := base.Model{Abc: "hello", Sd: 0xcafe, F64: 1.05, MapI32: map[int32]int32{3: 4}}
+	for _, proto := range defaultTestTProtocols {
+		pf := ProtocolFactory(proto, defaultTestTConfiguration)
+		enc := NewEncoder(pf)
+		dec := NewDecoder(pf)
+		bb, err := enc.Encode(&m)
+		require.NoError(t, err)
+
+		var st RPCStruct
+		err = dec.Decode(bb, pl.Bind(&st))
+		require.NoError(t, err)
+		require.Len(t, st.Fields, 4)
+		require.Equal(t, "abc", st.Fields[0].Name)
+		require.Equal(t, "", st.Fields[1].Name)
+		require.Equal(t, int64(0xcafe), st.Fields[1].Value)
+
+		actual, err := enc.Encode(pl.Bind(&st))
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+	}
+}
+
+func TestStructPlanInvalidDesc(t *testing.T) {
+	_, err := CompileStruct(NewStructDesc("Invalid",
+		NewFieldDesc(1, NewTypeDesc(thrift.I64), "a", false),
+		NewFieldDesc(1, NewTypeDesc(thrift.I64), "b", false),
+	))
+	require.Error(t, err)
+	_, err = CompileStruct(NewStructDesc("Invalid",
+		NewFieldDesc(1, &TypeDesc{Type: thrift.LIST}, "a", false),
+	))
+	require.Error(t, err)
+}
+
+func TestStructPlanRecursive(t *testing.T) {
+	node := NewStructDesc("Node")
+	node.AddField(
+		NewFieldDesc(1, NewTypeDesc(thrift.I32), "value", true),
+		NewFieldDesc(2, NewTypeDescList(NewTypeDescStruct(node)), "children", false),
+	)
+	pl, err := CompileStruct(node)
+	require.NoError(t, err)
+
+	leaf := &RPCStruct{Name: "Node"}
+	leaf.AddField(NewTField(1, thrift.I32, "value", true).SetValue(int32(2)))
+	children := NewTypeContainerList[thrift.TStruct](TypeContainerDesc{Value: thrift.STRUCT}, false)
+	children.Add(leaf)
+	var root RPCStruct
+	root.AddField(
+		NewTField(1, thrift.I32, "value", true).SetValue(int32(1)),
+		NewTField(2, thrift.LIST, "children", false).SetValue(children),
+	)
+
+	enc := NewEncoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration))
+	dec := NewDecoder(ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration))
+	bb, err := enc.Encode(pl.Bind(&root))
+	require.NoError(t, err)
+	expected, err := enc.Encode(&root)
+	require.NoError(t, err)
+	require.Equal(t, expected, bb)
+
+	var actual RPCStruct
+	err = dec.Decode(bb, pl.Bind(&actual))
+	require.NoError(t, err)
+	child := actual.Fields[1].Value.(*TypeContainerList[thrift.TStruct]).Value[0].(*RPCStruct)
+	require.Equal(t, "Node", child.Name)
+	require.Equal(t, "value", child.Fields[0].Name)
+	require.Equal(t, int32(2), child.Fields[0].Value)
+}
+
+func TestStructPlanDefaults(t *testing.T) {
+	schema := MustParseIDL(`
+enum Mode { FAST, SAFE }
+const i32 DefaultRetries = 3
+struct Backoff {
+  1: i64 initialMs = 100
+  2: double factor = 2
+}
+struct Options {
+  1: i32 retries = DefaultRetries
+  2: optional string name = "unnamed"
+  3: Mode mode = Mode.SAFE
+  4: list<string> tags = ["a", "b"]
+  5: Backoff backoff = {"initialMs": 50}
+  6: required bool strict = true
+  7: i64 timeout
+}
+`)
+	require.Equal(t, int64(3), schema.Struct("Options").FieldByID(1).DefaultValue)
+	pl, err := CompileStruct(schema.Struct("Options"))
+	require.NoError(t, err)
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+
+	// missing fields are filled with defaults.
+	bb, err := enc.Encode((&RPCStruct{}).AddField(
+		NewTField(2, thrift.STRING, "", false).SetValue("job"),
+		NewTField(6, thrift.BOOL, "", false).SetValue(false),
+	))
+	require.NoError(t, err)
+	var st RPCStruct
+	require.NoError(t, dec.Decode(bb, pl.Bind(&st)))
+	require.Equal(t, int32(3), testField(&st, 1).Value)
+	require.Equal(t, "job", testField(&st, 2).Value)
+	require.Equal(t, "SAFE", testField(&st, 3).Value.(TEnum).Name)
+	require.Equal(t, []string{"a", "b"}, testField(&st, 4).Value.(*TypeContainerList[string]).Value)
+	backoff := testField(&st, 5).Value.(*RPCStruct)
+	require.Equal(t, int64(50), testField(backoff, 1).Value)
+	require.Equal(t, 2.0, testField(backoff, 2).Value) // default of nested struct
+	require.Equal(t, false, testField(&st, 6).Value)
+	require.Nil(t, testField(&st, 7).Value)
+
+	// every read gets its own copy of containers.
+	var st2 RPCStruct
+	require.NoError(t, dec.Decode(bb, pl.Bind(&st2)))
+	require.NotSame(t, testField(&st, 4).Value, testField(&st2, 4).Value)
+
+	// fields equal to their default are left out, required ones are kept.
+	full, err := enc.Encode(pl.Bind(&st))
+	require.NoError(t, err)
+	enc.SetOmitDefaults(true)
+	require.True(t, enc.OmitDefaults())
+	omitted, err := enc.Encode(pl.Bind(&st))
+	require.NoError(t, err)
+	require.Less(t, len(omitted), len(full))
+	var raw RPCStruct
+	require.NoError(t, dec.Decode(omitted, &raw))
+	var ids []TFieldID
+	for _, f := range raw.Fields {
+		ids = append(ids, f.ID)
+	}
+	require.Equal(t, []TFieldID{2, 6, 5}, ids) // backoff read has defaults of its own fields too
+
+	// scalar and enum values are compared without encoding them.
+	ctx := context.Background()
+	for id, values := range map[TFieldID][]any{
+		1: {int32(3), 3, TEnum{Value: 3}},
+		3: {testField(&st, 3).Value, "SAFE", int32(testField(&st, 3).Value.(TEnum).Value)},
+	} {
+		op := pl.lookup(id)
+		for _, v := range values {
+			require.True(t, op.isDefault(ctx, v), v)
+			require.Zero(t, testing.AllocsPerRun(10, func() { op.isDefault(ctx, v) }), v)
+		}
+		require.False(t, op.isDefault(ctx, int32(4)))
+	}
+	require.True(t, isDefaultScalar(math.NaN(), math.NaN()))
+	require.False(t, isDefaultScalar(0.0, math.Copysign(0, -1)))
+	require.True(t, isDefaultScalar("job", []byte("job")))
+
+	// value given in another form than decoded is still left out.
+	testField(&st, 3).SetValue("SAFE")
+	testField(&st, 5).SetValue((&RPCStruct{}).AddField(NewTField(1, thrift.I64, "", false).SetValue(int64(50))))
+	omitted, err = enc.Encode(pl.Bind(&st))
+	require.NoError(t, err)
+	raw = RPCStruct{}
+	require.NoError(t, dec.Decode(omitted, &raw))
+	require.Len(t, raw.Fields, 2)
+
+	_, err = CompileStruct(NewStructDesc("Bad", &FieldDesc{ID: 1, Name: "n", Type: NewTypeDesc(thrift.BYTE), Default: "300"}))
+	require.EqualError(t, err, "Bad: field 1 'n' default: invalid byte value 300")
+}
+
+func TestNestedContainerDesc(t *testing.T) {
+	desc := testRequestStructDesc()
+	pl, err := CompileStruct(desc)
+	require.NoError(t, err)
+	fd := desc.FieldByName("modelByTime")
+
+	c, err := NewTypeContainerOf(fd.Type, true)
+	require.NoError(t, err)
+	m := c.(*TypeContainerMap[int64, TypeContainerImplementer])
+	require.Equal(t, "list<Model>", m.Desc.ValueDesc.String())
+	m.AddKV(1, nil)
+	m.AddKV(2, NewTypeContainerList[Container](TypeContainerDesc{}, true))
+
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		enc := NewEncoder(pf)
+		dec := NewDecoder(pf)
+		bb, err := enc.Encode((&RPCStruct{}).AddField(&TField{ID: fd.ID, Type: thrift.MAP, Value: m}))
+		require.NoError(t, err)
+
+		// empty inner lists carry their element type.
+		var st RPCStruct
+		require.NoError(t, dec.Decode(bb, &st))
+		for _, item := range testField(&st, fd.ID).Value.(*TypeContainerMap[int64, TypeContainerImplementer]).Value {
+			require.Equal(t, thrift.TType(thrift.STRUCT), item.Value.(*TypeContainerList[thrift.TStruct]).Desc.Value)
+		}
+
+		// nested descriptors survive a planned round trip.
+		var planned RPCStruct
+		require.NoError(t, dec.Decode(bb, pl.Bind(&planned)))
+		got := testField(&planned, fd.ID).Value.(*TypeContainerMap[int64, TypeContainerImplementer])
+		require.Equal(t, "list<Model>", got.Desc.ValueDesc.String())
+		require.Len(t, got.Value, 2)
+		inner := got.Value[0].Value.(*TypeContainerList[thrift.TStruct])
+		require.Equal(t, "Model", inner.Desc.ValueDesc.String())
+		actual, err := enc.Encode(pl.Bind(&planned))
+		require.NoError(t, err)
+		require.Equal(t, bb, actual)
+	})
+}
@@ -0,0 +1,570 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrTaggedStruct = errors.New("invalid tagged struct")
+
+// TaggedStruct is plain Go struct encoded and decoded by reflection,
+// fields are described by `thrift` tags of the form `thrift:"name,id"`,
+// optionally followed by `required` or `optional`, and `set` for slices
+// encoded as SET. Fields without tag are ignored, e.g.
+//
+//	type Job struct {
+//		Name   string   `thrift:"name,1,required"`
+//		Count  *int64   `thrift:"count,2"`
+//		Tags   []string `thrift:"tags,3,set"`
+//		Parent *Job     `thrift:"parent,4"`
+//	}
+//
+// Go types map to bool, byte (int8, uint8), i16, i32, i64 (int64, int), double,
+// string (string, []byte), list (slice, array), map and struct. Nil pointer,
+// slice and map fields are not written unless required, optional fields are
+// not written when zero. Encoder and Decoder wrap plain Go structs implicitly.
+type TaggedStruct struct {
+	value reflect.Value
+	info  *taggedStructInfo
+}
+
+// NewTaggedStruct create new TaggedStruct of struct or pointer to struct v,
+// decoding requires a pointer.
+func NewTaggedStruct(v any) (*TaggedStruct, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrTaggedStruct, v)
+	}
+	info, err := taggedStructOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &TaggedStruct{value: rv, info: info}, nil
+}
+
+func (s *TaggedStruct) Write(ctx context.Context, p thrift.TProtocol) error {
+	return s.info.write(ctx, p, s.value)
+}
+
+func (s *TaggedStruct) Read(ctx context.Context, p thrift.TProtocol) error {
+	if !s.value.CanSet() {
+		return fmt.Errorf("%w: cannot decode into %s, not a pointer", ErrTaggedStruct, s.value.Type())
+	}
+	return s.info.read(ctx, p, s.value)
+}
+
+// structOf get value as thrift.TStruct, plain Go structs are wrapped by TaggedStruct.
+func structOf(value any) (thrift.TStruct, error) {
+	if st, ok := value.(thrift.TStruct); ok {
+		return st, nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+	return NewTaggedStruct(value)
+}
+
+type taggedStructInfo struct {
+	name    string
+	fields  []taggedField
+	byID    map[TFieldID]*taggedField
+	ordered []*taggedField // fields in field ID order
+	err     error          // compile error
+}
+
+type taggedField struct {
+	index    []int
+	id       TFieldID
+	name     string
+	required bool
+	optional bool
+	codec    *taggedCodec
+}
+
+// taggedCodec write and read value of Go type as thrift type.
+type taggedCodec struct {
+	ttype thrift.TType
+	key   *taggedCodec      // MAP key
+	elem  *taggedCodec      // MAP value, SET and LIST element
+	info  *taggedStructInfo // STRUCT of tagged Go struct
+	write func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error
+	read  func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error // rv is settable
+}
+
+var (
+	taggedStructMu    sync.Mutex // held while compiling
+	taggedStructs     = map[reflect.Type]*taggedStructInfo{}
+	taggedStructCache sync.Map // reflect.Type of compiled struct -> *taggedStructInfo
+)
+
+// taggedStructOf get compiled struct type t, compile errors are cached too.
+func taggedStructOf(t reflect.Type) (*taggedStructInfo, error) {
+	if v, ok := taggedStructCache.Load(t); ok {
+		info := v.(*taggedStructInfo)
+		return info, info.err
+	}
+	taggedStructMu.Lock()
+	defer taggedStructMu.Unlock()
+	info := compileTaggedStruct(t)
+	taggedStructCache.Store(t, info)
+	return info, info.err
+}
+
+// compileTaggedStruct compile struct type, taggedStructMu must be held.
+func compileTaggedStruct(t reflect.Type) *taggedStructInfo {
+	if info, ok := taggedStructs[t]; ok {
+		return info
+	}
+	info := &taggedStructInfo{name: t.Name(), byID: map[TFieldID]*taggedField{}}
+	taggedStructs[t] = info // struct referring to itself is compiled against the same info.
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("thrift")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		f, set, err := parseThriftTag(sf, tag)
+		if err == nil {
+			f.codec, err = compileTaggedCodec(sf.Type, set)
+		}
+		if err == nil && info.byID[f.id] != nil {
+			err = fmt.Errorf("duplicate field id %d", f.id)
+		}
+		if err != nil {
+			info.err = fmt.Errorf("%w: %s.%s: %s", ErrTaggedStruct, t, sf.Name, err)
+			return info
+		}
+		info.byID[f.id] = &f
+		info.fields = append(info.fields, f)
+	}
+	for i := range info.fields {
+		info.byID[info.fields[i].id] = &info.fields[i]
+		info.ordered = append(info.ordered, &info.fields[i])
+	}
+	sort.Slice(info.ordered, func(i, j int) bool { return info.ordered[i].id < info.ordered[j].id })
+	return info
+}
+
+// parseThriftTag parse `name,id[,required|optional][,set]`, name defaults to Go field name.
+func parseThriftTag(sf reflect.StructField, tag string) (f taggedField, set bool, err error) {
+	parts := strings.Split(tag, ",")
+	if len(parts) < 2 {
+		return f, false, fmt.Errorf("thrift tag %q without field id", tag)
+	}
+	f.index, f.name = sf.Index, parts[0]
+	if f.name == "" {
+		f.name = sf.Name
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 16)
+	if err != nil {
+		return f, false, fmt.Errorf("thrift tag %q: invalid field id %s", tag, parts[1])
+	}
+	f.id = TFieldID(id)
+	for _, opt := range parts[2:] {
+		switch opt {
+		case "required":
+			f.required = true
+		case "optional":
+			f.optional = true
+		case "set":
+			set = true
+		default:
+			return f, false, fmt.Errorf("thrift tag %q: unknown option %s", tag, opt)
+		}
+	}
+	return
+}
+
+var (
+	tstructType = reflect.TypeOf((*thrift.TStruct)(nil)).Elem()
+	tenumType   = reflect.TypeOf(TEnum{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// compileTaggedCodec compile codec of Go type t, slice of set is encoded as SET.
+func compileTaggedCodec(t reflect.Type, set bool) (c *taggedCodec, err error) {
+	switch {
+	case t == tenumType:
+		return &taggedCodec{
+			ttype: thrift.I32,
+			write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				return WriteDataGeneric(ctx, TDataSpec{Type: thrift.I32, Protocol: p}, rv.Interface())
+			},
+			read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				return ReadData(ctx, TData[TEnum]{TDataSpec: TDataSpec{Type: thrift.I32, Protocol: p}, Value: rv.Addr().Interface().(*TEnum)})
+			},
+		}, nil
+	case reflect.PtrTo(t).Implements(tstructType) && t.Kind() == reflect.Struct:
+		return &taggedCodec{
+			ttype: thrift.STRUCT,
+			write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				if !rv.CanAddr() {
+					v := reflect.New(t).Elem()
+					v.Set(rv)
+					rv = v
+				}
+				return rv.Addr().Interface().(thrift.TStruct).Write(ctx, p)
+			},
+			read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				return rv.Addr().Interface().(thrift.TStruct).Read(ctx, p)
+			},
+		}, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &taggedCodec{
+			ttype: thrift.STRING,
+			write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				return WriteDataGeneric(ctx, TDataSpec{Type: thrift.STRING, Required: true, Protocol: p}, rv.Bytes()) // named byte element type included
+			},
+			read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+				var v []byte
+				err := ReadData(ctx, TData[[]byte]{TDataSpec: TDataSpec{Type: thrift.STRING, Protocol: p}, Value: &v})
+				rv.SetBytes(v)
+				return err
+			},
+		}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return taggedScalar(thrift.BOOL, func(rv reflect.Value) any { return rv.Bool() },
+			func(rv reflect.Value, v bool) { rv.SetBool(v) }), nil
+	case reflect.Int8:
+		return taggedScalar(thrift.BYTE, func(rv reflect.Value) any { return int8(rv.Int()) },
+			func(rv reflect.Value, v int8) { rv.SetInt(int64(v)) }), nil
+	case reflect.Uint8:
+		return taggedScalar(thrift.BYTE, func(rv reflect.Value) any { return int8(rv.Uint()) },
+			func(rv reflect.Value, v int8) { rv.SetUint(uint64(uint8(v))) }), nil
+	case reflect.Int16:
+		return taggedScalar(thrift.I16, func(rv reflect.Value) any { return int16(rv.Int()) },
+			func(rv reflect.Value, v int16) { rv.SetInt(int64(v)) }), nil
+	case reflect.Int32:
+		return taggedScalar(thrift.I32, func(rv reflect.Value) any { return int32(rv.Int()) },
+			func(rv reflect.Value, v int32) { rv.SetInt(int64(v)) }), nil
+	case reflect.Int64, reflect.Int:
+		return taggedScalar(thrift.I64, func(rv reflect.Value) any { return rv.Int() },
+			func(rv reflect.Value, v int64) { rv.SetInt(v) }), nil
+	case reflect.Float64, reflect.Float32:
+		return taggedScalar(thrift.DOUBLE, func(rv reflect.Value) any { return rv.Float() },
+			func(rv reflect.Value, v float64) { rv.SetFloat(v) }), nil
+	case reflect.String:
+		return taggedScalar(thrift.STRING, func(rv reflect.Value) any { return rv.String() },
+			func(rv reflect.Value, v string) { rv.SetString(v) }), nil
+	case reflect.Ptr:
+		return compileTaggedPtr(t, set)
+	case reflect.Slice, reflect.Array:
+		return compileTaggedList(t, set)
+	case reflect.Map:
+		return compileTaggedMap(t)
+	case reflect.Struct:
+		info := compileTaggedStruct(t)
+		if info.err != nil {
+			return nil, info.err
+		}
+		return &taggedCodec{
+			ttype: thrift.STRUCT,
+			info:  info,
+			write: info.write,
+			read:  info.read,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func taggedScalar[T any](ttype thrift.TType, get func(rv reflect.Value) any, set func(rv reflect.Value, v T)) *taggedCodec {
+	return &taggedCodec{
+		ttype: ttype,
+		write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+			return WriteDataGeneric(ctx, TDataSpec{Type: ttype, Protocol: p}, get(rv))
+		},
+		read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+			var v T
+			err := ReadData(ctx, TData[T]{TDataSpec: TDataSpec{Type: ttype, Protocol: p}, Value: &v})
+			set(rv, v)
+			return err
+		},
+	}
+}
+
+// compileTaggedPtr compile codec of pointer, nil is written as zero value.
+func compileTaggedPtr(t reflect.Type, set bool) (*taggedCodec, error) {
+	elem, err := compileTaggedCodec(t.Elem(), set)
+	if err != nil {
+		return nil, err
+	}
+	return &taggedCodec{
+		ttype: elem.ttype,
+		key:   elem.key,
+		elem:  elem.elem,
+		info:  elem.info,
+		write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+			if rv.IsNil() {
+				return elem.write(ctx, p, reflect.New(t.Elem()).Elem())
+			}
+			return elem.write(ctx, p, rv.Elem())
+		},
+		read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) error {
+			if rv.IsNil() {
+				rv.Set(reflect.New(t.Elem()))
+			}
+			return elem.read(ctx, p, rv.Elem())
+		},
+	}, nil
+}
+
+func compileTaggedList(t reflect.Type, set bool) (*taggedCodec, error) {
+	elem, err := compileTaggedCodec(t.Elem(), false)
+	if err != nil {
+		return nil, err
+	}
+	c := &taggedCodec{ttype: thrift.LIST, elem: elem}
+	if set {
+		c.ttype = thrift.SET
+	}
+	c.write = func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+		n := rv.Len()
+		var order []int
+		if set && isCanonical(p) {
+			values := make([]any, n)
+			for i := range values {
+				values[i] = rv.Index(i).Interface()
+			}
+			if order = mapKeySorter(values, true); order != nil {
+				n = len(order)
+			}
+		}
+		if set {
+			err = p.WriteSetBegin(ctx, elem.ttype, n)
+		} else {
+			err = p.WriteListBegin(ctx, elem.ttype, n)
+		}
+		if err != nil {
+			return
+		}
+		for i := 0; i < n; i++ {
+			j := i
+			if order != nil {
+				j = order[i]
+			}
+			if err = elem.write(ctx, p, rv.Index(j)); err != nil {
+				return
+			}
+		}
+		if set {
+			return p.WriteSetEnd(ctx)
+		}
+		return p.WriteListEnd(ctx)
+	}
+	c.read = func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+		var (
+			elemType thrift.TType
+			size     int
+		)
+		if set {
+			elemType, size, err = p.ReadSetBegin(ctx)
+		} else {
+			elemType, size, err = p.ReadListBegin(ctx)
+		}
+		if err != nil {
+			return
+		}
+		if elemType != elem.ttype && size > 0 {
+			return fmt.Errorf("cannot read %s elements into %s", elemType, t)
+		}
+		if err = checkDeclaredSize(p, size); err != nil {
+			return
+		}
+		if t.Kind() == reflect.Array {
+			if size > t.Len() {
+				return fmt.Errorf("cannot read %d elements into %s", size, t)
+			}
+			rv.Set(reflect.Zero(t))
+		} else if rv.Cap() >= size {
+			rv.SetLen(size)
+		} else {
+			rv.Set(reflect.MakeSlice(t, size, size))
+		}
+		for i := 0; i < size; i++ {
+			if err = elem.read(ctx, p, rv.Index(i)); err != nil {
+				return
+			}
+		}
+		if set {
+			return p.ReadSetEnd(ctx)
+		}
+		return p.ReadListEnd(ctx)
+	}
+	return c, nil
+}
+
+func compileTaggedMap(t reflect.Type) (*taggedCodec, error) {
+	key, err := compileTaggedCodec(t.Key(), false)
+	if err != nil {
+		return nil, err
+	}
+	value, err := compileTaggedCodec(t.Elem(), false)
+	if err != nil {
+		return nil, err
+	}
+	return &taggedCodec{
+		ttype: thrift.MAP,
+		key:   key,
+		elem:  value,
+		write: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+			if err = p.WriteMapBegin(ctx, key.ttype, value.ttype, rv.Len()); err != nil {
+				return
+			}
+			for _, k := range canonicalValues(rv.MapKeys(), false) {
+				if err = key.write(ctx, p, k); err != nil {
+					return
+				}
+				if err = value.write(ctx, p, rv.MapIndex(k)); err != nil {
+					return
+				}
+			}
+			return p.WriteMapEnd(ctx)
+		},
+		read: func(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+			keyType, valueType, size, err := p.ReadMapBegin(ctx)
+			if err != nil {
+				return
+			}
+			if (keyType != key.ttype || valueType != value.ttype) && size > 0 {
+				return fmt.Errorf("cannot read map<%s,%s> into %s", keyType, valueType, t)
+			}
+			if err = checkDeclaredSize(p, size); err != nil {
+				return
+			}
+			rv.Set(reflect.MakeMapWithSize(t, size))
+			k, v := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			for i := 0; i < size; i++ {
+				k.Set(reflect.Zero(t.Key()))
+				v.Set(reflect.Zero(t.Elem()))
+				if err = key.read(ctx, p, k); err != nil {
+					return
+				}
+				if err = value.read(ctx, p, v); err != nil {
+					return
+				}
+				rv.SetMapIndex(k, v)
+			}
+			return p.ReadMapEnd(ctx)
+		},
+	}, nil
+}
+
+// isNilValue report whether field value is nil pointer, slice or map.
+func isNilValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func (info *taggedStructInfo) write(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+	var fieldId TFieldID
+	canonical := isCanonical(p)
+	if err = p.WriteStructBegin(ctx, info.name); err != nil {
+		goto WriteStructBeginError
+	}
+
+	for i := range info.fields {
+		f := &info.fields[i]
+		if canonical {
+			f = info.ordered[i]
+		}
+		fv := rv.FieldByIndex(f.index)
+		if !f.required && (isNilValue(fv) || f.optional && fv.IsZero()) {
+			continue
+		}
+		fieldId = f.id
+		if err = p.WriteFieldBegin(ctx, f.name, f.codec.ttype, f.id); err != nil {
+			goto WriteFieldError
+		}
+		if err = f.codec.write(ctx, p, fv); err != nil {
+			goto WriteFieldError
+		}
+		if err = p.WriteFieldEnd(ctx); err != nil {
+			goto WriteFieldError
+		}
+	}
+
+	if err = p.WriteFieldStop(ctx); err != nil {
+		goto WriteFieldStopError
+	}
+	if err = p.WriteStructEnd(ctx); err != nil {
+		goto WriteStructEndError
+	}
+	return nil
+WriteStructBeginError:
+	return thrift.PrependError(fmt.Sprintf("%s write struct begin error: ", info.name), err)
+WriteFieldError:
+	return thrift.PrependError(fmt.Sprintf("%s write field %d error: ", info.name, fieldId), err)
+WriteFieldStopError:
+	return thrift.PrependError(fmt.Sprintf("%s write field stop error: ", info.name), err)
+WriteStructEndError:
+	return thrift.PrependError(fmt.Sprintf("%s write struct end error: ", info.name), err)
+}
+
+func (info *taggedStructInfo) read(ctx context.Context, p thrift.TProtocol, rv reflect.Value) (err error) {
+	var (
+		fieldName   string
+		fieldTypeId thrift.TType
+		fieldId     TFieldID
+	)
+
+	if _, err = p.ReadStructBegin(ctx); err != nil {
+		goto ReadStructBeginError
+	}
+
+	for {
+		fieldName, fieldTypeId, fieldId, err = p.ReadFieldBegin(ctx)
+		if err != nil {
+			goto ReadFieldBeginError
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+
+		if f := info.byID[fieldId]; f != nil && f.codec.ttype == fieldTypeId {
+			fieldName = f.name
+			if err = f.codec.read(ctx, p, rv.FieldByIndex(f.index)); err != nil {
+				goto ReadFieldError
+			}
+		} else if err = p.Skip(ctx, fieldTypeId); err != nil {
+			goto ReadFieldError
+		}
+
+		if err = p.ReadFieldEnd(ctx); err != nil {
+			goto ReadFieldEndError
+		}
+	}
+
+	if err = p.ReadStructEnd(ctx); err != nil {
+		goto ReadStructEndError
+	}
+	return nil
+ReadStructBeginError:
+	return thrift.PrependError(fmt.Sprintf("%s read struct begin error: ", info.name), err)
+ReadFieldBeginError:
+	return thrift.PrependError(fmt.Sprintf("%s read field %d begin error: ", info.name, fieldId), err)
+ReadFieldError:
+	return thrift.PrependError(fmt.Sprintf("%s read field %d '%s' (%d) error: ", info.name, fieldId, fieldName, fieldTypeId), err)
+ReadFieldEndError:
+	return thrift.PrependError(fmt.Sprintf("%s read field end error", info.name), err)
+ReadStructEndError:
+	return thrift.PrependError(fmt.Sprintf("%s read struct end error: ", info.name), err)
+}
@@ -0,0 +1,124 @@
+package thrift_dyn
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type taggedJob struct {
+	Name    string           `thrift:"name,1,required"`
+	Count   *int64           `thrift:"count,2"`
+	Tags    []string         `thrift:"tags,3,set"`
+	Scores  map[string]int32 `thrift:"scores,4"`
+	Parent  *taggedJob       `thrift:"parent,5"`
+	Data    []byte           `thrift:"data,6"`
+	Retries int16            `thrift:"retries,7,optional"`
+	Status  TEnum            `thrift:"status,8"`
+	Extra   *RPCStruct       `thrift:"extra,9"`
+	Ignored string
+}
+
+func TestTaggedStruct(t *testing.T) {
+	for _, pt := range defaultTestTProtocols {
+		pf := ProtocolFactory(pt, defaultTestTConfiguration)
+		enc, dec := NewEncoder(pf), NewDecoder(pf)
+
+		count := int64(3)
+		job := taggedJob{
+			Name:    "build",
+			Count:   &count,
+			Tags:    []string{"a", "b"},
+			Scores:  map[string]int32{"y": 2, "x": 1},
+			Parent:  &taggedJob{Name: "root"},
+			Data:    []byte{1, 2},
+			Status:  TEnum{Value: 5},
+			Extra:   NewStruct("Extra").I64(1, "id", 7).MustBuild(),
+			Ignored: "x",
+		}
+		bb, err := enc.Encode(&job)
+		require.NoError(t, err)
+		bb2, err := enc.Encode(job)
+		require.NoError(t, err)
+		require.Equal(t, bb, bb2)
+
+		// the same payload read without schema.
+		var st RPCStruct
+		require.NoError(t, dec.Decode(bb, &st))
+		require.Equal(t, []TFieldID{1, 2, 3, 4, 5, 6, 8, 9}, fieldIDs(&st))
+		require.Equal(t, thrift.TType(thrift.SET), testField(&st, 3).Type)
+
+		var job2 taggedJob
+		require.NoError(t, dec.Decode(bb, &job2))
+		job.Ignored = ""
+		require.Equal(t, job.Extra.Fields[0].Value, testField(job2.Extra, 1).Value)
+		job.Extra, job2.Extra = nil, nil
+		require.Equal(t, job, job2)
+
+		// unknown fields are skipped.
+		var sub struct {
+			Name string `thrift:"name,1"`
+		}
+		require.NoError(t, dec.Decode(bb, &sub))
+		require.Equal(t, "build", sub.Name)
+	}
+}
+
+func fieldIDs(st *RPCStruct) (ids []TFieldID) {
+	for _, f := range st.Fields {
+		ids = append(ids, f.ID)
+	}
+	return
+}
+
+func TestTaggedStructError(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+
+	_, err := enc.Encode(42)
+	require.EqualError(t, err, "unsupported type int")
+	_, err = enc.Encode(struct {
+		A uint32 `thrift:"a,1"`
+	}{})
+	require.ErrorIs(t, err, ErrTaggedStruct)
+	require.EqualError(t, err, "invalid tagged struct: struct { A uint32 \"thrift:\\\"a,1\\\"\" }.A: unsupported type uint32")
+	_, err = enc.Encode(struct {
+		A int64 `thrift:"a"`
+	}{})
+	require.ErrorIs(t, err, ErrTaggedStruct)
+	_, err = enc.Encode(struct {
+		A int64 `thrift:"a,1"`
+		B int64 `thrift:"b,1"`
+	}{})
+	require.ErrorContains(t, err, "duplicate field id 1")
+
+	bb, err := enc.Encode(taggedJob{Name: "x"})
+	require.NoError(t, err)
+	require.ErrorIs(t, dec.Decode(bb, taggedJob{}), ErrTaggedStruct)
+	var wrong struct {
+		Name []int64 `thrift:"name,1"`
+	}
+	require.NoError(t, dec.Decode(bb, &wrong)) // mismatched type is skipped.
+	require.Nil(t, wrong.Name)
+}
+
+type taggedByte uint8
+
+func TestTaggedStructNamedByteSlice(t *testing.T) {
+	type blob struct {
+		Data []taggedByte `thrift:"data,1"`
+	}
+	withProtocols(t, defaultTestTProtocols, nil, func(pf thrift.TProtocolFactory) {
+		bb, err := NewEncoder(pf).Encode(blob{Data: []taggedByte{1, 2, 0xff}})
+		require.NoError(t, err)
+		var st RPCStruct
+		require.NoError(t, NewDecoder(pf).Decode(bb, &st))
+		data, err := testField(&st, 1).GetBinary()
+		require.NoError(t, err)
+		require.Equal(t, []byte{1, 2, 0xff}, data)
+
+		var out blob
+		require.NoError(t, NewDecoder(pf).Decode(bb, &out))
+		require.Equal(t, []taggedByte{1, 2, 0xff}, out.Data)
+	})
+}
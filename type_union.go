@@ -0,0 +1,89 @@
+package thrift_dyn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+var (
+	ErrInvalidUnion = errors.New("invalid union")
+)
+
+// RPCUnion is RPCStruct with union semantics, exactly one field is set.
+// Fields of nil value are not set, as they are not written.
+type RPCUnion struct {
+	RPCStruct
+}
+
+// NewRPCUnion create new RPCUnion, field is the one set if any.
+func NewRPCUnion(name string, field *TField) *RPCUnion {
+	u := &RPCUnion{RPCStruct{Name: name}}
+	if field != nil {
+		u.Set(field)
+	}
+	return u
+}
+
+// Set replace value of union by field.
+func (u *RPCUnion) Set(field *TField) *RPCUnion {
+	u.Fields = append(u.Fields[:0], field)
+	return u
+}
+
+// Get get the set field, nil if none or many fields are set.
+func (u *RPCUnion) Get() (field *TField) {
+	for _, f := range u.Fields {
+		if f.Value == nil {
+			continue
+		}
+		if field != nil {
+			return nil
+		}
+		field = f
+	}
+	return
+}
+
+// Which get ID of the set field, 0 if none or many fields are set.
+func (u *RPCUnion) Which() TFieldID {
+	if f := u.Get(); f != nil {
+		return f.ID
+	}
+	return 0
+}
+
+// Validate check exactly one field is set.
+func (u *RPCUnion) Validate() error {
+	return validateUnion(u.Name, u.Fields)
+}
+
+func validateUnion(name string, fields []*TField) error {
+	n := 0
+	for _, f := range fields {
+		if f.Value != nil {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("%w %s: %d fields set, expected exactly one", ErrInvalidUnion, name, n)
+	}
+	return nil
+}
+
+// Write writes the set field to the wire, error if not exactly one field is set.
+func (u *RPCUnion) Write(ctx context.Context, p thrift.TProtocol) (err error) {
+	if err = u.Validate(); err != nil {
+		return
+	}
+	return u.RPCStruct.Write(ctx, p)
+}
+
+// Read reads fields from wire, error if not exactly one field is set.
+func (u *RPCUnion) Read(ctx context.Context, p thrift.TProtocol) (err error) {
+	if err = u.RPCStruct.Read(ctx, p); err != nil {
+		return
+	}
+	return u.Validate()
+}
@@ -0,0 +1,130 @@
+package thrift_dyn
+
+import (
+	"errors"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRPCUnion(t *testing.T) {
+	pf := ProtocolFactory(ProtocolType_Binary, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+
+	u := NewRPCUnion("Value", NewTField(2, thrift.STRING, "str", false).SetValue("hello"))
+	require.Equal(t, TFieldID(2), u.Which())
+	require.Equal(t, "hello", u.Get().Value)
+	bb, err := enc.Encode(u)
+	require.NoError(t, err)
+
+	var u2 RPCUnion
+	require.NoError(t, dec.Decode(bb, &u2))
+	require.Equal(t, TFieldID(2), u2.Which())
+	u2.Set(NewTField(1, thrift.I64, "num", false).SetValue(int64(1)))
+	require.Equal(t, TFieldID(1), u2.Which())
+
+	// exactly one field is written.
+	_, err = enc.Encode(NewRPCUnion("Value", nil))
+	require.ErrorIs(t, err, ErrInvalidUnion)
+	require.EqualError(t, err, "invalid union Value: 0 fields set, expected exactly one")
+	u.AddField(NewTField(1, thrift.I64, "num", false).SetValue(int64(1)))
+	require.Zero(t, u.Which())
+	require.Nil(t, u.Get())
+	_, err = enc.Encode(u)
+	require.ErrorIs(t, err, ErrInvalidUnion)
+
+	// ... and read.
+	st := (&RPCStruct{Name: "Value"}).AddField(
+		NewTField(1, thrift.I64, "num", false).SetValue(int64(1)),
+		NewTField(2, thrift.STRING, "str", false).SetValue("hello"),
+	)
+	bb, err = enc.Encode(st)
+	require.NoError(t, err)
+	u2 = RPCUnion{}
+	require.ErrorIs(t, dec.Decode(bb, &u2), ErrInvalidUnion)
+	bb, err = enc.Encode(&RPCStruct{})
+	require.NoError(t, err)
+	require.ErrorIs(t, dec.Decode(bb, &u2), ErrInvalidUnion)
+}
+
+func TestRPCUnionPlan(t *testing.T) {
+	schema := MustParseIDL(`
+union Value {
+  1: i64 num
+  2: string str
+}
+struct Entry {
+  1: Value value
+  2: list<Value> values
+}
+`)
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	enc, dec := NewEncoder(pf), NewDecoder(pf)
+	pl, err := CompileStruct(schema.Struct("Entry"))
+	require.NoError(t, err)
+
+	values := NewTypeContainerList[thrift.TStruct](TypeContainerDesc{Value: thrift.STRUCT}, false)
+	values.Add(NewRPCUnion("", NewTField(1, thrift.I64, "", false).SetValue(int64(7))))
+	entry := (&RPCStruct{}).AddField(
+		NewTField(1, thrift.STRUCT, "", false).SetValue(NewRPCUnion("", NewTField(2, thrift.STRING, "", false).SetValue("hello"))),
+		NewTField(2, thrift.LIST, "", false).SetValue(values),
+	)
+	bb, err := enc.Encode(pl.Bind(entry))
+	require.NoError(t, err)
+
+	var st RPCStruct
+	require.NoError(t, dec.Decode(bb, pl.Bind(&st)))
+	value := testField(&st, 1).Value.(*RPCUnion)
+	require.Equal(t, "Value", value.Name)
+	require.Equal(t, "str", value.Get().Name)
+	require.Equal(t, TFieldID(1), testField(&st, 2).Value.(*TypeContainerList[thrift.TStruct]).Value[0].(*RPCUnion).Which())
+
+	// schema says union, a plain struct of two fields is rejected both ways.
+	bad := (&RPCStruct{}).AddField(
+		NewTField(1, thrift.I64, "", false).SetValue(int64(1)),
+		NewTField(2, thrift.STRING, "", false).SetValue("hello"),
+	)
+	entry.Fields[0].SetValue(bad)
+	_, err = enc.Encode(pl.Bind(entry))
+	require.ErrorContains(t, err, "invalid union Value: 2 fields set")
+
+	bb, err = enc.Encode(entry)
+	require.NoError(t, err)
+	st = RPCStruct{}
+	require.ErrorContains(t, dec.Decode(bb, pl.Bind(&st)), "invalid union Value: 2 fields set")
+}
+
+func TestRPCUnionPlanBestEffort(t *testing.T) {
+	schema := MustParseIDL(`
+union Value {
+  1: i64 num
+  2: string str
+}
+struct Entry {
+  1: Value value
+  2: i64 n
+}
+`)
+	pf := ProtocolFactory(ProtocolType_Compact, defaultTestTConfiguration)
+	pl, err := CompileStruct(schema.Struct("Entry"))
+	require.NoError(t, err)
+	bb, err := NewEncoder(pf).Encode((&RPCStruct{}).AddField(
+		NewTField(1, thrift.STRUCT, "", false).SetValue((&RPCStruct{}).AddField(
+			NewTField(1, thrift.I64, "", false).SetValue(int64(1)),
+			NewTField(2, thrift.STRING, "", false).SetValue("hello"),
+		)),
+		NewTField(2, thrift.I64, "", false).SetValue(int64(7)),
+	))
+	require.NoError(t, err)
+
+	// invalid union is skipped, the next field is decoded.
+	var st RPCStruct
+	err = NewDecoder(pf).SetBestEffort(true).Decode(bb, pl.Bind(&st))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags), err)
+	require.Len(t, diags, 1)
+	require.Equal(t, "1", diags[0].Path)
+	require.ErrorIs(t, diags[0].Err, ErrInvalidUnion)
+	require.Equal(t, []TFieldID{2}, fieldIDs(&st))
+	require.Equal(t, int64(7), testField(&st, 2).Value)
+}